@@ -0,0 +1,27 @@
+package proxyproto
+
+import "slices"
+
+// ErrCommandNotAllowed is returned when a connection receives a header with
+// a command it doesn't allow, see WithAllowedCommands.
+var ErrCommandNotAllowed = newError(ErrCodePolicyReject, "proxyproto: proxy protocol command not allowed")
+
+// WithAllowedCommands restricts the commands of the header of a connection
+// to cmds when passed as option to NewConn(), e.g. to PROXY so that
+// upstreams can't hide the client behind LOCAL, or to LOCAL on a listener
+// which only serves health checks. A version 1 header with the UNKNOWN
+// transport carries the LOCAL command. A header with another command is
+// refused with ErrCommandNotAllowed. No commands means any supported one.
+func WithAllowedCommands(cmds ...ProtocolVersionAndCommand) func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.commands = cmds
+	}
+}
+
+// checkCommand checks the command of header against the allowed commands.
+func checkCommand(header *Header, cmds []ProtocolVersionAndCommand) error {
+	if !slices.Contains(cmds, header.Command) {
+		return ErrCommandNotAllowed
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllowedCommands(t *testing.T) {
+	local, err := (&Header{Version: 2, Command: LOCAL, TransportProtocol: UNSPEC}).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	proxy := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+	unknown := []byte("PROXY UNKNOWN\r\n")
+
+	tests := []struct {
+		name   string
+		header []byte
+		cmds   []ProtocolVersionAndCommand
+		err    error
+	}{
+		{"local with any", local, nil, nil},
+		{"proxy with any", proxy, nil, nil},
+		{"local with proxy", local, []ProtocolVersionAndCommand{PROXY}, ErrCommandNotAllowed},
+		{"v1 unknown with proxy", unknown, []ProtocolVersionAndCommand{PROXY}, ErrCommandNotAllowed},
+		{"proxy with proxy", proxy, []ProtocolVersionAndCommand{PROXY}, nil},
+		{"local with local", local, []ProtocolVersionAndCommand{LOCAL}, nil},
+		{"proxy with local", proxy, []ProtocolVersionAndCommand{LOCAL}, ErrCommandNotAllowed},
+		{"proxy with both", proxy, []ProtocolVersionAndCommand{LOCAL, PROXY}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write(append(tt.header, "ping"...))
+
+			conn := NewConn(server, WithAllowedCommands(tt.cmds...))
+			defer conn.Close()
+			if _, err := conn.Read(make([]byte, 4)); err != tt.err {
+				t.Fatalf("bad: %v", err)
+			}
+			if tt.err != nil && conn.ErrorCode() != ErrCodePolicyReject {
+				t.Fatalf("bad: %v", conn.ErrorCode())
+			}
+		})
+	}
+}
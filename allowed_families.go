@@ -0,0 +1,47 @@
+package proxyproto
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ErrFamilyNotAllowed is wrapped by the FamilyError returned when a
+// connection receives a header with an address family and protocol it
+// doesn't allow, see WithAllowedFamilies.
+var ErrFamilyNotAllowed = newError(ErrCodePolicyReject, "proxyproto: address family and protocol not allowed")
+
+// FamilyError is returned when the header of a connection is refused by
+// WithAllowedFamilies. It wraps ErrFamilyNotAllowed.
+type FamilyError struct {
+	TransportProtocol AddressFamilyAndProtocol
+}
+
+func (e *FamilyError) Error() string {
+	return fmt.Sprintf("%v: 0x%02x", ErrFamilyNotAllowed, byte(e.TransportProtocol))
+}
+
+func (e *FamilyError) Unwrap() error {
+	return ErrFamilyNotAllowed
+}
+
+// WithAllowedFamilies restricts the address family and protocol of the
+// header of a connection to families when passed as option to NewConn(),
+// e.g. to TCPv4 and TCPv6 on a TCP listener, where Unix and datagram
+// addresses make no sense. A header with another family is refused with a
+// *FamilyError, whose code is ErrCodePolicyReject. Headers with the LOCAL
+// command are exempt, as their addresses aren't used: restrict them with
+// WithAllowedCommands. No families means any.
+func WithAllowedFamilies(families ...AddressFamilyAndProtocol) func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.families = families
+	}
+}
+
+// checkFamily checks the address family and protocol of header against the
+// allowed families.
+func checkFamily(header *Header, families []AddressFamilyAndProtocol) error {
+	if header.Command.IsLocal() || slices.Contains(families, header.TransportProtocol) {
+		return nil
+	}
+	return &FamilyError{TransportProtocol: header.TransportProtocol}
+}
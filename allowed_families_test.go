@@ -0,0 +1,104 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAllowedFamilies(t *testing.T) {
+	tcp := []AddressFamilyAndProtocol{TCPv4, TCPv6}
+	tests := []struct {
+		name     string
+		header   *Header
+		families []AddressFamilyAndProtocol
+		err      bool
+	}{
+		{"tcp4 with any", HeaderProxyFromAddrs(2,
+			&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		), nil, false},
+		{"tcp4 with tcp", HeaderProxyFromAddrs(2,
+			&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		), tcp, false},
+		{"udp4 with tcp", HeaderProxyFromAddrs(2,
+			&net.UDPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			&net.UDPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		), tcp, true},
+		{"unix with tcp", HeaderProxyFromAddrs(2,
+			&net.UnixAddr{Net: "unix", Name: "/tmp/src.sock"},
+			&net.UnixAddr{Net: "unix", Name: "/tmp/dst.sock"},
+		), tcp, true},
+		{"local with tcp", &Header{Version: 2, Command: LOCAL, TransportProtocol: UNSPEC}, tcp, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.header.Format()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write(append(raw, "ping"...))
+
+			conn := NewConn(server, WithAllowedFamilies(tt.families...))
+			defer conn.Close()
+			_, err = conn.Read(make([]byte, 4))
+			if !tt.err {
+				if err != nil {
+					t.Fatalf("err: %v", err)
+				}
+				return
+			}
+			var familyErr *FamilyError
+			if !errors.As(err, &familyErr) || familyErr.TransportProtocol != tt.header.TransportProtocol {
+				t.Fatalf("bad: %v", err)
+			}
+			if !errors.Is(err, ErrFamilyNotAllowed) || conn.ErrorCode() != ErrCodePolicyReject {
+				t.Fatalf("bad: %v, %v", err, conn.ErrorCode())
+			}
+		})
+	}
+}
+
+func TestListenerAllowedFamiliesOnError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	reports := make(chan error, 1)
+	pl := &Listener{
+		Listener:        l,
+		AllowedFamilies: []AddressFamilyAndProtocol{TCPv4, TCPv6},
+		OnError: func(addr net.Addr, err error) {
+			reports <- err
+		},
+	}
+	defer pl.Close()
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	if _, err := HeaderProxyFromAddrs(2,
+		&net.UDPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.UDPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	).WriteTo(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrFamilyNotAllowed) {
+		t.Fatalf("bad: %v", err)
+	}
+	var familyErr *FamilyError
+	if err := <-reports; !errors.As(err, &familyErr) || familyErr.TransportProtocol != UDPv4 {
+		t.Fatalf("bad: %v", err)
+	}
+}
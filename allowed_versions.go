@@ -0,0 +1,38 @@
+package proxyproto
+
+// ErrVersionNotAllowed is returned when a connection receives a header of
+// a protocol version it doesn't allow, see WithAllowedVersions.
+var ErrVersionNotAllowed = newError(ErrCodePolicyReject, "proxyproto: proxy protocol version not allowed")
+
+// Versions is a set of protocol versions. The zero value allows both.
+type Versions uint8
+
+const (
+	// Version1 is the text protocol version.
+	Version1 Versions = 1 << iota
+	// Version2 is the binary protocol version.
+	Version2
+)
+
+// allows tells whether the set allows the given protocol version.
+func (v Versions) allows(version byte) bool {
+	switch version {
+	case 1:
+		return v == 0 || v&Version1 != 0
+	case 2:
+		return v == 0 || v&Version2 != 0
+	}
+	return false
+}
+
+// WithAllowedVersions restricts the protocol versions of the header of a
+// connection when passed as option to NewConn(), e.g. to Version2 for
+// fleets where upstreams only send binary headers. Unlike DisableV1 and
+// DisableV2, a header of another version is recognized and refused with
+// ErrVersionNotAllowed whatever the policy, rather than handled as if no
+// header was sent, so that it can't reach the application as data.
+func WithAllowedVersions(v Versions) func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.versions = v
+	}
+}
@@ -0,0 +1,48 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAllowedVersions(t *testing.T) {
+	v2, err := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	v1 := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+
+	tests := []struct {
+		name     string
+		header   []byte
+		versions Versions
+		err      error
+	}{
+		{"v1 with both allowed", v1, 0, nil},
+		{"v2 with both allowed", v2, Version1 | Version2, nil},
+		{"v1 with v1 allowed", v1, Version1, nil},
+		{"v2 with v1 allowed", v2, Version1, ErrVersionNotAllowed},
+		{"v1 with v2 allowed", v1, Version2, ErrVersionNotAllowed},
+		{"v2 with v2 allowed", v2, Version2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write(append(tt.header, "ping"...))
+
+			// Refused even though the header is optional
+			conn := NewConn(server, WithAllowedVersions(tt.versions), WithPolicy(USE))
+			defer conn.Close()
+			if _, err := conn.Read(make([]byte, 4)); err != tt.err {
+				t.Fatalf("bad: %v", err)
+			}
+			if tt.err != nil && conn.ErrorCode() != ErrCodePolicyReject {
+				t.Fatalf("bad: %v", conn.ErrorCode())
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package proxyproto
+
+// ALPN returns the value of the PP2_TYPE_ALPN TLV of the header, the
+// application protocol negotiated by the client, e.g. "h2", and whether
+// there is one.
+func (header *Header) ALPN() (string, bool) {
+	value, ok := header.tlvValue(PP2_TYPE_ALPN)
+	return string(value), ok
+}
+
+// SetALPN sets the PP2_TYPE_ALPN TLV of the header to proto, replacing the
+// previous one if any and keeping the other TLVs. An empty proto removes the
+// TLV.
+func (header *Header) SetALPN(proto string) error {
+	return header.replaceTLV(PP2_TYPE_ALPN, []byte(proto))
+}
@@ -0,0 +1,37 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestALPN(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if _, ok := header.ALPN(); ok {
+		t.Fatalf("bad: unexpected ALPN")
+	}
+	if err := header.SetAuthority("example.org"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := header.SetALPN("h2"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	parsed, _, err := ExtractHeader(raw)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if proto, ok := parsed.ALPN(); !ok || proto != "h2" {
+		t.Fatalf("bad: %q, %v", proto, ok)
+	}
+	if authority, _ := parsed.Authority(); authority != "example.org" {
+		t.Fatalf("bad: %q", authority)
+	}
+}
@@ -0,0 +1,96 @@
+package proxyproto
+
+import "net"
+
+// ErrSpoofedSource is returned by the validator of ClaimedSourceValidator
+// when an upstream claims a source address it isn't allowed to.
+var ErrSpoofedSource = newError(ErrCodeValidatorReject, "proxyproto: upstream not allowed to claim the header's source address")
+
+// UpstreamValidator receives the address of the upstream connection along
+// with the header it sent, and decides whether the header is valid for it.
+// In case the header is not deemed valid it should return an error.
+type UpstreamValidator func(upstream net.Addr, header *Header) error
+
+// ValidateUpstreamHeader adds given upstream validator for proxy headers to a connection when passed as option to NewConn()
+func ValidateUpstreamHeader(v UpstreamValidator) func(*Conn) {
+	return func(c *Conn) {
+		if v != nil {
+			c.ValidateUpstream = v
+		}
+	}
+}
+
+// ClaimedSourceValidator returns an UpstreamValidator restricting the
+// source addresses each upstream may claim. The keys of allowed are the IP
+// addresses or ranges of the upstreams, e.g. the load balancers of a
+// region, and the values the IP addresses or ranges of the clients they may
+// claim. An upstream matching several keys may claim any of their ranges,
+// and one matching none may not claim any address.
+//
+// Headers without an IP source address, e.g. LOCAL ones sent by health
+// checks, are accepted. If one of the provided IP addresses or IP ranges is
+// invalid it will return an error instead of an UpstreamValidator.
+func ClaimedSourceValidator(allowed map[string][]string) (UpstreamValidator, error) {
+	type rule struct {
+		upstream []func(net.IP) bool
+		claimed  []func(net.IP) bool
+	}
+
+	rules := make([]rule, 0, len(allowed))
+	for upstream, claimed := range allowed {
+		upstreamFrom, err := parse([]string{upstream})
+		if err != nil {
+			return nil, err
+		}
+		claimedFrom, err := parse(claimed)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule{upstream: upstreamFrom, claimed: claimedFrom})
+	}
+
+	return func(upstream net.Addr, header *Header) error {
+		if header.Command.IsLocal() {
+			return nil
+		}
+		sourceIP, _, ok := header.IPs()
+		if !ok {
+			return nil
+		}
+
+		upstreamIP, err := ipFromAddr(upstream)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range rules {
+			if !matchIP(r.upstream, upstreamIP) {
+				continue
+			}
+			if matchIP(r.claimed, sourceIP) {
+				return nil
+			}
+		}
+		return ErrSpoofedSource
+	}, nil
+}
+
+// MustClaimedSourceValidator returns a ClaimedSourceValidator but will panic
+// if one of the provided IP addresses or IP ranges is invalid.
+func MustClaimedSourceValidator(allowed map[string][]string) UpstreamValidator {
+	v, err := ClaimedSourceValidator(allowed)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+func matchIP(allowed []func(net.IP) bool, ip net.IP) bool {
+	for _, allowFrom := range allowed {
+		if allowFrom(ip) {
+			return true
+		}
+	}
+	return false
+}
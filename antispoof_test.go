@@ -0,0 +1,91 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClaimedSourceValidator(t *testing.T) {
+	validate := MustClaimedSourceValidator(map[string][]string{
+		"10.0.0.0/24": {"192.0.2.0/24"},
+		"10.0.1.0/24": {"198.51.100.0/24", "2001:db8::/32"},
+		"10.0.0.5":    {"203.0.113.7"},
+	})
+
+	header := func(source string) *Header {
+		return HeaderProxyFromAddrs(2,
+			&net.TCPAddr{IP: net.ParseIP(source), Port: 1000},
+			&net.TCPAddr{IP: net.ParseIP("10.9.9.9"), Port: 443},
+		)
+	}
+	upstream := func(ip string) net.Addr {
+		return &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}
+	}
+
+	tests := []struct {
+		upstream string
+		source   string
+		err      error
+	}{
+		{"10.0.0.1", "192.0.2.10", nil},
+		{"10.0.0.1", "198.51.100.10", ErrSpoofedSource},
+		{"10.0.1.1", "198.51.100.10", nil},
+		{"10.0.1.1", "2001:db8::1", nil},
+		{"10.0.0.5", "203.0.113.7", nil},
+		{"10.0.0.5", "192.0.2.10", nil},
+		{"10.0.2.1", "192.0.2.10", ErrSpoofedSource},
+	}
+	for _, tt := range tests {
+		if err := validate(upstream(tt.upstream), header(tt.source)); err != tt.err {
+			t.Errorf("%s claiming %s: expected %v, got %v", tt.upstream, tt.source, tt.err, err)
+		}
+	}
+
+	// Health checks carry no address to check
+	if err := validate(upstream("10.0.2.1"), &Header{Version: 2, Command: LOCAL}); err != nil {
+		t.Errorf("err: %v", err)
+	}
+}
+
+func TestClaimedSourceValidatorInvalidRange(t *testing.T) {
+	if _, err := ClaimedSourceValidator(map[string][]string{"10.0.0.0/33": {"192.0.2.0/24"}}); err == nil {
+		t.Fatal("expected an error for an invalid upstream range")
+	}
+	if _, err := ClaimedSourceValidator(map[string][]string{"10.0.0.0/24": {"nope"}}); err == nil {
+		t.Fatal("expected an error for an invalid claimed address")
+	}
+}
+
+func TestConnRejectsSpoofedSource(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{
+		Listener:               l,
+		ValidateUpstreamHeader: MustClaimedSourceValidator(map[string][]string{"127.0.0.1": {"10.1.1.0/24"}}),
+	}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("PROXY TCP4 10.2.2.2 20.2.2.2 1000 2000\r\n"))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 1)); err != ErrSpoofedSource {
+		t.Fatalf("bad: expected %v, got %v", ErrSpoofedSource, err)
+	}
+	if code := conn.(*Conn).ErrorCode(); code != ErrCodeValidatorReject {
+		t.Fatalf("bad: %v", code)
+	}
+}
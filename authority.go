@@ -0,0 +1,63 @@
+package proxyproto
+
+import "strings"
+
+// ErrAuthorityNotAllowed is returned by the validator of AuthorityValidator
+// for PROXY headers whose authority isn't allowed.
+var ErrAuthorityNotAllowed = newError(ErrCodeValidatorReject, "proxyproto: PROXY header authority not allowed")
+
+// Authority returns the value of the PP2_TYPE_AUTHORITY TLV of the header,
+// the host name the client asked for, usually its TLS SNI, and whether
+// there is one.
+func (header *Header) Authority() (string, bool) {
+	value, ok := header.tlvValue(PP2_TYPE_AUTHORITY)
+	return string(value), ok
+}
+
+// SetAuthority sets the PP2_TYPE_AUTHORITY TLV of the header to authority,
+// replacing the previous one if any and keeping the other TLVs. An empty
+// authority removes the TLV.
+func (header *Header) SetAuthority(authority string) error {
+	return header.replaceTLV(PP2_TYPE_AUTHORITY, []byte(authority))
+}
+
+// AuthorityValidator returns a Validator refusing PROXY headers whose
+// authority doesn't match one of hosts with ErrAuthorityNotAllowed,
+// including headers without an authority. Host names are compared without
+// regard to case, and a "*." prefix matches any single label, so that
+// "*.example.org" matches "www.example.org" but neither "example.org" nor
+// "a.b.example.org". Headers of other commands pass.
+func AuthorityValidator(hosts ...string) Validator {
+	exact := make(map[string]struct{}, len(hosts))
+	var wildcards []string
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if suffix, ok := strings.CutPrefix(host, "*"); ok && strings.HasPrefix(suffix, ".") {
+			wildcards = append(wildcards, suffix)
+			continue
+		}
+		exact[host] = struct{}{}
+	}
+
+	return func(header *Header) error {
+		if header.Command != PROXY {
+			return nil
+		}
+		authority, _ := header.Authority()
+		authority = strings.ToLower(authority)
+		if authority == "" {
+			return ErrAuthorityNotAllowed
+		}
+		if _, ok := exact[authority]; ok {
+			return nil
+		}
+		if dot := strings.IndexByte(authority, '.'); dot > 0 {
+			for _, suffix := range wildcards {
+				if authority[dot:] == suffix {
+					return nil
+				}
+			}
+		}
+		return ErrAuthorityNotAllowed
+	}
+}
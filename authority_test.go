@@ -0,0 +1,62 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAuthority(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if _, ok := header.Authority(); ok {
+		t.Fatalf("bad: unexpected authority")
+	}
+	if err := header.SetAuthority("example.org"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if authority, ok := header.Authority(); !ok || authority != "example.org" {
+		t.Fatalf("bad: %q, %v", authority, ok)
+	}
+	if err := header.SetAuthority(""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := header.Authority(); ok {
+		t.Fatalf("bad: authority not removed")
+	}
+}
+
+func TestAuthorityValidator(t *testing.T) {
+	validate := AuthorityValidator("example.org", "*.example.net")
+
+	tests := []struct {
+		authority string
+		want      error
+	}{
+		{"example.org", nil},
+		{"EXAMPLE.org", nil},
+		{"www.example.org", ErrAuthorityNotAllowed},
+		{"www.example.net", nil},
+		{"example.net", ErrAuthorityNotAllowed},
+		{"a.b.example.net", ErrAuthorityNotAllowed},
+		{"", ErrAuthorityNotAllowed},
+	}
+	for _, tt := range tests {
+		header := HeaderProxyFromAddrs(2,
+			&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		)
+		if err := header.SetAuthority(tt.authority); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if err := validate(header); err != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.authority, err, tt.want)
+		}
+	}
+
+	// LOCAL headers carry no authority
+	if err := validate(HeaderProxyFromAddrs(2, nil, nil)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
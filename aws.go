@@ -0,0 +1,83 @@
+package proxyproto
+
+// Amazon's application specific TLV, sent by NLB for connections through
+// VPC endpoint services (PrivateLink). Its value is a subtype byte followed
+// by the data, the VPC endpoint ID for PP2_SUBTYPE_AWS_VPCE_ID. See also
+// package tlvparse.
+const (
+	pp2TypeAWS          PP2Type = 0xEA
+	pp2SubtypeAWSVPCEID byte    = 0x01
+)
+
+// AWSVPCEndpointID returns the VPC endpoint ID of the connection sent by an
+// AWS Network Load Balancer, e.g. "vpce-08d2bf15fac5001c9", and whether
+// there is a well-formed one.
+func (header *Header) AWSVPCEndpointID() (string, bool) {
+	tlvs, err := header.TLVs()
+	if err != nil {
+		return "", false
+	}
+	for _, tlv := range tlvs {
+		if id, err := ParseAWSVPCEndpointID(tlv); err == nil && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// SetAWSVPCEndpointID sets the AWS VPC endpoint ID TLV of the header to id,
+// replacing the previous one if any and keeping the other TLVs, including
+// AWS TLVs of other subtypes. An empty id removes the TLV. It fails with
+// ErrMalformedTLV if id contains anything else than ASCII letters, digits
+// and dashes.
+func (header *Header) SetAWSVPCEndpointID(id string) error {
+	if !isVPCEndpointID([]byte(id)) {
+		return ErrMalformedTLV
+	}
+	tlvs, err := header.TLVs()
+	if err != nil {
+		return err
+	}
+
+	kept := tlvs[:0]
+	for _, tlv := range tlvs {
+		if !isAWSVPCEndpointID(tlv) {
+			kept = append(kept, tlv)
+		}
+	}
+	if id != "" {
+		value := append([]byte{pp2SubtypeAWSVPCEID}, id...)
+		kept = append(kept, TLV{Type: pp2TypeAWS, Value: value})
+	}
+	return header.SetTLVs(kept)
+}
+
+// ParseAWSVPCEndpointID returns the VPC endpoint ID carried by tlv. It fails
+// with ErrIncompatibleTLV if tlv is not an AWS TLV of the VPC endpoint ID
+// subtype, and with ErrMalformedTLV if the ID contains anything else than
+// ASCII letters, digits and dashes.
+func ParseAWSVPCEndpointID(tlv TLV) (string, error) {
+	if !isAWSVPCEndpointID(tlv) {
+		return "", ErrIncompatibleTLV
+	}
+	id := tlv.Value[1:]
+	if !isVPCEndpointID(id) {
+		return "", ErrMalformedTLV
+	}
+	return string(id), nil
+}
+
+func isAWSVPCEndpointID(tlv TLV) bool {
+	return tlv.Type == pp2TypeAWS && len(tlv.Value) > 0 && tlv.Value[0] == pp2SubtypeAWSVPCEID
+}
+
+func isVPCEndpointID(b []byte) bool {
+	for _, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAWSVPCEndpointID(t *testing.T) {
+	// Header sent by NLB, see tlvparse
+	raw := []byte{
+		0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a,
+		0x21, 0x11, 0x00, 0x2d,
+		0xac, 0x1f, 0x07, 0x71, 0xac, 0x1f, 0x0a, 0x1f, 0xc8, 0xf2, 0x00, 0x50,
+		0x03, 0x00, 0x04, 0xe8, 0xd6, 0x89, 0x2d,
+		0xea, 0x00, 0x17, 0x01,
+		'v', 'p', 'c', 'e', '-', '0', '8', 'd', '2', 'b', 'f', '1', '5', 'f', 'a', 'c', '5', '0', '0', '1', 'c', '9',
+	}
+	header, _, err := ExtractHeader(raw)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if id, ok := header.AWSVPCEndpointID(); !ok || id != "vpce-08d2bf15fac5001c9" {
+		t.Fatalf("bad: %q, %v", id, ok)
+	}
+
+	header = HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	// Another AWS subtype is kept
+	other := TLV{Type: pp2TypeAWS, Value: []byte{0x02, 'x'}}
+	if err := header.SetTLVs([]TLV{other}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := header.AWSVPCEndpointID(); ok {
+		t.Fatalf("bad: unexpected VPC endpoint ID")
+	}
+	for _, id := range []string{"vpce-1", "vpce-2"} {
+		if err := header.SetAWSVPCEndpointID(id); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if id, ok := header.AWSVPCEndpointID(); !ok || id != "vpce-2" {
+		t.Fatalf("bad: %q, %v", id, ok)
+	}
+	if tlvs, _ := header.TLVs(); len(tlvs) != 2 || tlvs[0].Value[0] != 0x02 {
+		t.Fatalf("bad: %+v", tlvs)
+	}
+
+	if err := header.SetAWSVPCEndpointID("vpce_1"); err != ErrMalformedTLV {
+		t.Fatalf("bad: %v", err)
+	}
+}
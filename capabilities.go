@@ -0,0 +1,58 @@
+package proxyproto
+
+import (
+	"runtime"
+	"time"
+)
+
+// Capabilities describes the platform optimizations in effect for this
+// binary on the current host, e.g. to log them at startup.
+type Capabilities struct {
+	// OS and Arch are the platform the binary was built for.
+	OS   string
+	Arch string
+	// ArchProfile is the tuning profile in use: "amd64", "arm64" or
+	// "generic".
+	ArchProfile string
+
+	// ZeroCopyBackend is the compiled-in zero-copy implementation:
+	// "splice", "epoll", "netpoll" or "none".
+	ZeroCopyBackend string
+	// ZeroCopy is true if the backend is usable on this host, see
+	// ZeroCopyAvailable.
+	ZeroCopy bool
+
+	// OptimalBufferSize is the value returned by GetOptimalBufferSize.
+	OptimalBufferSize int
+
+	// The settings OptimizeConn applies to TCP connections, see
+	// DefaultConnTuning. Zero values mean the OS defaults are kept.
+	NoDelay         bool
+	ReadBufferSize  int
+	WriteBufferSize int
+	KeepAlive       bool
+	KeepAlivePeriod time.Duration
+	// QuickAck is true if TCP_QUICKACK is requested on accepted
+	// connections, which is only effective on Linux.
+	QuickAck bool
+}
+
+// GetCapabilities returns the platform optimizations in effect. The first
+// call probes the zero-copy backend, see ZeroCopyAvailable.
+func GetCapabilities() Capabilities {
+	tuning := DefaultConnTuning()
+	return Capabilities{
+		OS:                runtime.GOOS,
+		Arch:              runtime.GOARCH,
+		ArchProfile:       archProfile,
+		ZeroCopyBackend:   zeroCopyBackend,
+		ZeroCopy:          ZeroCopyAvailable(),
+		OptimalBufferSize: GetOptimalBufferSize(),
+		NoDelay:           tuning.NoDelay,
+		ReadBufferSize:    tuning.ReadBuffer,
+		WriteBufferSize:   tuning.WriteBuffer,
+		KeepAlive:         tuning.KeepAlive,
+		KeepAlivePeriod:   tuning.KeepAlivePeriod,
+		QuickAck:          tuning.QuickAck && OSIsLinux,
+	}
+}
@@ -0,0 +1,26 @@
+package proxyproto
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	caps := GetCapabilities()
+
+	if caps.OS != runtime.GOOS || caps.Arch != runtime.GOARCH {
+		t.Fatalf("bad: %s/%s", caps.OS, caps.Arch)
+	}
+	if caps.ArchProfile == "" || caps.ZeroCopyBackend == "" {
+		t.Fatalf("bad: %+v", caps)
+	}
+	if caps.ZeroCopy != ZeroCopyAvailable() {
+		t.Fatalf("bad: zero-copy reported as %v", caps.ZeroCopy)
+	}
+	if caps.ZeroCopyBackend == "none" && caps.ZeroCopy {
+		t.Fatal("bad: zero-copy available without a backend")
+	}
+	if caps.OptimalBufferSize != GetOptimalBufferSize() {
+		t.Fatalf("bad: buffer size %d", caps.OptimalBufferSize)
+	}
+}
@@ -0,0 +1,130 @@
+package proxyproto
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used for header read timeouts, minimum header
+// rates, idle timeouts and failure bans. Injecting a fake clock lets tests
+// and simulations drive those deterministically, without real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has elapsed, unless the
+	// returned timer is stopped first.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer started by Clock.AfterFunc.
+type ClockTimer interface {
+	// Stop prevents the timer from firing. It returns false if the timer
+	// already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock is the Clock reading the system time, used when none is set.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// WithClock sets the clock measuring the header read timeout and minimum
+// rate of a connection when passed as option to NewConn().
+func WithClock(clock Clock) func(*Conn) {
+	return func(c *Conn) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// clockOrSystem returns clock, or SystemClock if nil.
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// aLongTimeAgo is a deadline in the past, expiring pending I/O immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// clockDeadline arms a connection deadline measured with a clock. The system
+// clock sets the deadline on the connection directly, other clocks start a
+// timer which expires the connection's deadline once it fires.
+type clockDeadline struct {
+	clock       Clock
+	setDeadline func(time.Time) error
+
+	mu         sync.Mutex
+	timer      ClockTimer
+	generation uint64
+}
+
+func newReadDeadline(clock Clock, conn net.Conn) *clockDeadline {
+	d := &clockDeadline{}
+	d.init(clock, conn.SetReadDeadline)
+	return d
+}
+
+func newWriteDeadline(clock Clock, conn net.Conn) *clockDeadline {
+	d := &clockDeadline{}
+	d.init(clock, conn.SetWriteDeadline)
+	return d
+}
+
+func (d *clockDeadline) init(clock Clock, setDeadline func(time.Time) error) {
+	d.clock = clockOrSystem(clock)
+	d.setDeadline = setDeadline
+}
+
+// arm arms the deadline at t, as measured by the clock. A zero t disarms it.
+func (d *clockDeadline) arm(t time.Time) error {
+	if _, ok := d.clock.(systemClock); ok {
+		return d.setDeadline(t)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopLocked()
+	// A previous timer may have expired the connection's deadline already
+	if err := d.setDeadline(time.Time{}); err != nil || t.IsZero() {
+		return err
+	}
+
+	generation := d.generation
+	d.timer = d.clock.AfterFunc(t.Sub(d.clock.Now()), func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// Don't expire a deadline which was moved or disarmed meanwhile
+		if d.generation == generation {
+			d.setDeadline(aLongTimeAgo)
+		}
+	})
+	return nil
+}
+
+// disarm disarms the deadline without touching the connection, which is left
+// for the caller to restore.
+func (d *clockDeadline) disarm() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopLocked()
+}
+
+func (d *clockDeadline) stopLocked() {
+	d.generation++
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
@@ -0,0 +1,174 @@
+package proxyproto
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock only moving forward when advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	when    time.Time
+	f       func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers due meanwhile.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case !t.when.After(c.now):
+			t.stopped = true
+			due = append(due, t.f)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+// Pending returns the number of timers which haven't fired or been stopped.
+func (c *fakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
+
+// waitPending waits for a timer to be armed on clock.
+func waitPending(t *testing.T, clock *fakeClock) {
+	t.Helper()
+	for i := 0; clock.Pending() == 0; i++ {
+		if i == 1000 {
+			t.Fatal("bad: no timer armed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClockReadHeaderTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	clock := newFakeClock()
+	conn := NewConn(server, WithPolicy(REQUIRE), SetReadHeaderTimeout(time.Hour), WithClock(clock))
+	defer conn.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		errc <- err
+	}()
+
+	waitPending(t, clock)
+	clock.Advance(59 * time.Minute)
+	select {
+	case err := <-errc:
+		t.Fatalf("bad: header read ended early: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case err := <-errc:
+		if err != ErrNoProxyProtocol {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: header read didn't time out")
+	}
+	if code := conn.ErrorCode(); code != ErrCodeTimeout {
+		t.Fatalf("bad: %v", code)
+	}
+}
+
+func TestClockFailureLimiterCooldown(t *testing.T) {
+	clock := newFakeClock()
+	l := NewFailureLimiter(1, time.Minute, time.Hour)
+	l.Clock = clock
+	upstream := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+
+	l.Failure(upstream)
+	clock.Advance(59 * time.Minute)
+	if !l.Banned(upstream) {
+		t.Fatal("bad: ban lifted before the cooldown")
+	}
+	clock.Advance(time.Minute)
+	if l.Banned(upstream) {
+		t.Fatal("bad: ban not lifted after the cooldown")
+	}
+}
+
+func TestClockDuplexIdleTimeout(t *testing.T) {
+	a, peerA := net.Pipe()
+	b, peerB := net.Pipe()
+	defer peerA.Close()
+	defer peerB.Close()
+
+	clock := newFakeClock()
+	resultc := make(chan DuplexResult, 1)
+	go func() {
+		resultc <- CopyDuplex(a, b, DuplexOptions{IdleTimeout: time.Minute, Clock: clock})
+	}()
+
+	waitPending(t, clock)
+	clock.Advance(time.Minute)
+
+	select {
+	case result := <-resultc:
+		if result.Err != ErrIdleTimeout {
+			t.Fatalf("bad: %v", result.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: relay didn't time out")
+	}
+}
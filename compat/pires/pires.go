@@ -0,0 +1,191 @@
+// Package proxyproto mirrors the API of github.com/pires/go-proxyproto on top
+// of this module, so that projects can switch by changing their import path
+// only:
+//
+//	import proxyproto "github.com/iqhive/go-proxyproto/compat/pires"
+//
+// Types are aliases and functions call the ones of the main package, so
+// values can be mixed freely with the ones of the main package. Variables
+// can't be aliased though: they are copies initialized from the main
+// package, and assigning to them has no effect. Set DefaultReadHeaderTimeout
+// on the main package instead. Package tlvparse mirrors the one of
+// github.com/pires/go-proxyproto likewise.
+package proxyproto
+
+import (
+	"bufio"
+	"net"
+	"time"
+
+	proxyproto "github.com/iqhive/go-proxyproto"
+)
+
+// Types
+type (
+	AddressFamilyAndProtocol  = proxyproto.AddressFamilyAndProtocol
+	Conn                      = proxyproto.Conn
+	ConnPolicyFunc            = proxyproto.ConnPolicyFunc
+	ConnPolicyOptions         = proxyproto.ConnPolicyOptions
+	Header                    = proxyproto.Header
+	Listener                  = proxyproto.Listener
+	PP2Type                   = proxyproto.PP2Type
+	Policy                    = proxyproto.Policy
+	PolicyFunc                = proxyproto.PolicyFunc
+	ProtocolVersionAndCommand = proxyproto.ProtocolVersionAndCommand
+	TLV                       = proxyproto.TLV
+	Validator                 = proxyproto.Validator
+)
+
+// Version and command
+const (
+	LOCAL = proxyproto.LOCAL
+	PROXY = proxyproto.PROXY
+)
+
+// Address family and transport protocol
+const (
+	UNSPEC       = proxyproto.UNSPEC
+	TCPv4        = proxyproto.TCPv4
+	UDPv4        = proxyproto.UDPv4
+	TCPv6        = proxyproto.TCPv6
+	UDPv6        = proxyproto.UDPv6
+	UnixStream   = proxyproto.UnixStream
+	UnixDatagram = proxyproto.UnixDatagram
+)
+
+// Policies
+const (
+	USE     = proxyproto.USE
+	IGNORE  = proxyproto.IGNORE
+	REJECT  = proxyproto.REJECT
+	REQUIRE = proxyproto.REQUIRE
+	SKIP    = proxyproto.SKIP
+)
+
+// TLV types
+const (
+	PP2_TYPE_ALPN           = proxyproto.PP2_TYPE_ALPN
+	PP2_TYPE_AUTHORITY      = proxyproto.PP2_TYPE_AUTHORITY
+	PP2_TYPE_CRC32C         = proxyproto.PP2_TYPE_CRC32C
+	PP2_TYPE_NOOP           = proxyproto.PP2_TYPE_NOOP
+	PP2_TYPE_UNIQUE_ID      = proxyproto.PP2_TYPE_UNIQUE_ID
+	PP2_TYPE_SSL            = proxyproto.PP2_TYPE_SSL
+	PP2_SUBTYPE_SSL_VERSION = proxyproto.PP2_SUBTYPE_SSL_VERSION
+	PP2_SUBTYPE_SSL_CN      = proxyproto.PP2_SUBTYPE_SSL_CN
+	PP2_SUBTYPE_SSL_CIPHER  = proxyproto.PP2_SUBTYPE_SSL_CIPHER
+	PP2_SUBTYPE_SSL_SIG_ALG = proxyproto.PP2_SUBTYPE_SSL_SIG_ALG
+	PP2_SUBTYPE_SSL_KEY_ALG = proxyproto.PP2_SUBTYPE_SSL_KEY_ALG
+	PP2_TYPE_NETNS          = proxyproto.PP2_TYPE_NETNS
+	PP2_TYPE_MIN_CUSTOM     = proxyproto.PP2_TYPE_MIN_CUSTOM
+	PP2_TYPE_MAX_CUSTOM     = proxyproto.PP2_TYPE_MAX_CUSTOM
+	PP2_TYPE_MIN_EXPERIMENT = proxyproto.PP2_TYPE_MIN_EXPERIMENT
+	PP2_TYPE_MAX_EXPERIMENT = proxyproto.PP2_TYPE_MAX_EXPERIMENT
+	PP2_TYPE_MIN_FUTURE     = proxyproto.PP2_TYPE_MIN_FUTURE
+	PP2_TYPE_MAX_FUTURE     = proxyproto.PP2_TYPE_MAX_FUTURE
+)
+
+// Variables, copied from the main package. The errors are the same values,
+// so they can be compared with the ones returned by the functions.
+var (
+	SIGV1 = proxyproto.SIGV1
+	SIGV2 = proxyproto.SIGV2
+
+	DefaultReadHeaderTimeout = proxyproto.DefaultReadHeaderTimeout
+
+	ErrCantReadVersion1Header               = proxyproto.ErrCantReadVersion1Header
+	ErrVersion1HeaderTooLong                = proxyproto.ErrVersion1HeaderTooLong
+	ErrLineMustEndWithCrlf                  = proxyproto.ErrLineMustEndWithCrlf
+	ErrCantReadProtocolVersionAndCommand    = proxyproto.ErrCantReadProtocolVersionAndCommand
+	ErrCantReadAddressFamilyAndProtocol     = proxyproto.ErrCantReadAddressFamilyAndProtocol
+	ErrCantReadLength                       = proxyproto.ErrCantReadLength
+	ErrCantResolveSourceUnixAddress         = proxyproto.ErrCantResolveSourceUnixAddress
+	ErrCantResolveDestinationUnixAddress    = proxyproto.ErrCantResolveDestinationUnixAddress
+	ErrNoProxyProtocol                      = proxyproto.ErrNoProxyProtocol
+	ErrUnknownProxyProtocolVersion          = proxyproto.ErrUnknownProxyProtocolVersion
+	ErrUnsupportedProtocolVersionAndCommand = proxyproto.ErrUnsupportedProtocolVersionAndCommand
+	ErrUnsupportedAddressFamilyAndProtocol  = proxyproto.ErrUnsupportedAddressFamilyAndProtocol
+	ErrInvalidLength                        = proxyproto.ErrInvalidLength
+	ErrInvalidAddress                       = proxyproto.ErrInvalidAddress
+	ErrInvalidPortNumber                    = proxyproto.ErrInvalidPortNumber
+	ErrSuperfluousProxyHeader               = proxyproto.ErrSuperfluousProxyHeader
+	ErrInvalidUpstream                      = proxyproto.ErrInvalidUpstream
+	ErrTruncatedTLV                         = proxyproto.ErrTruncatedTLV
+	ErrMalformedTLV                         = proxyproto.ErrMalformedTLV
+	ErrIncompatibleTLV                      = proxyproto.ErrIncompatibleTLV
+)
+
+// Read is proxyproto.Read.
+func Read(reader *bufio.Reader) (*Header, error) {
+	return proxyproto.Read(reader)
+}
+
+// ReadTimeout is proxyproto.ReadTimeout.
+func ReadTimeout(reader *bufio.Reader, timeout time.Duration) (*Header, error) {
+	return proxyproto.ReadTimeout(reader, timeout)
+}
+
+// HeaderProxyFromAddrs is proxyproto.HeaderProxyFromAddrs.
+func HeaderProxyFromAddrs(version byte, sourceAddr, destAddr net.Addr) *Header {
+	return proxyproto.HeaderProxyFromAddrs(version, sourceAddr, destAddr)
+}
+
+// NewConn is proxyproto.NewConn.
+func NewConn(conn net.Conn, opts ...func(*Conn)) *Conn {
+	return proxyproto.NewConn(conn, opts...)
+}
+
+// WithPolicy is proxyproto.WithPolicy.
+func WithPolicy(p Policy) func(*Conn) {
+	return proxyproto.WithPolicy(p)
+}
+
+// ValidateHeader is proxyproto.ValidateHeader.
+func ValidateHeader(v Validator) func(*Conn) {
+	return proxyproto.ValidateHeader(v)
+}
+
+// SetReadHeaderTimeout is proxyproto.SetReadHeaderTimeout.
+func SetReadHeaderTimeout(t time.Duration) func(*Conn) {
+	return proxyproto.SetReadHeaderTimeout(t)
+}
+
+// SplitTLVs is proxyproto.SplitTLVs.
+func SplitTLVs(raw []byte) ([]TLV, error) {
+	return proxyproto.SplitTLVs(raw)
+}
+
+// JoinTLVs is proxyproto.JoinTLVs.
+func JoinTLVs(tlvs []TLV) ([]byte, error) {
+	return proxyproto.JoinTLVs(tlvs)
+}
+
+// LaxWhiteListPolicy is proxyproto.LaxWhiteListPolicy.
+func LaxWhiteListPolicy(allowed []string) (PolicyFunc, error) {
+	return proxyproto.LaxWhiteListPolicy(allowed)
+}
+
+// MustLaxWhiteListPolicy is proxyproto.MustLaxWhiteListPolicy.
+func MustLaxWhiteListPolicy(allowed []string) PolicyFunc {
+	return proxyproto.MustLaxWhiteListPolicy(allowed)
+}
+
+// StrictWhiteListPolicy is proxyproto.StrictWhiteListPolicy.
+func StrictWhiteListPolicy(allowed []string) (PolicyFunc, error) {
+	return proxyproto.StrictWhiteListPolicy(allowed)
+}
+
+// MustStrictWhiteListPolicy is proxyproto.MustStrictWhiteListPolicy.
+func MustStrictWhiteListPolicy(allowed []string) PolicyFunc {
+	return proxyproto.MustStrictWhiteListPolicy(allowed)
+}
+
+// SkipProxyHeaderForCIDR is proxyproto.SkipProxyHeaderForCIDR.
+func SkipProxyHeaderForCIDR(skipHeaderCIDR *net.IPNet, def Policy) PolicyFunc {
+	return proxyproto.SkipProxyHeaderForCIDR(skipHeaderCIDR, def)
+}
+
+// IgnoreProxyHeaderNotOnInterface is
+// proxyproto.IgnoreProxyHeaderNotOnInterface.
+func IgnoreProxyHeaderNotOnInterface(allowedIP net.IP) ConnPolicyFunc {
+	return proxyproto.IgnoreProxyHeaderNotOnInterface(allowedIP)
+}
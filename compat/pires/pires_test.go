@@ -0,0 +1,32 @@
+package proxyproto_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	upstream "github.com/iqhive/go-proxyproto"
+	proxyproto "github.com/iqhive/go-proxyproto/compat/pires"
+)
+
+func TestCompatValuesAreShared(t *testing.T) {
+	_, err := proxyproto.Read(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n")))
+	if err != proxyproto.ErrNoProxyProtocol || err != upstream.ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Values of both packages are interchangeable
+	var header *upstream.Header = proxyproto.HeaderProxyFromAddrs(1,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if header.TransportProtocol != proxyproto.TCPv4 {
+		t.Fatalf("bad: %v", header.TransportProtocol)
+	}
+
+	var l net.Listener = &proxyproto.Listener{Policy: proxyproto.MustLaxWhiteListPolicy([]string{"10.0.0.0/8"})}
+	if _, ok := l.(*upstream.Listener); !ok {
+		t.Fatal("bad: listener types differ")
+	}
+}
@@ -0,0 +1,74 @@
+// Package tlvparse mirrors the API of
+// github.com/pires/go-proxyproto/tlvparse on top of the tlvparse package of
+// this module, like its parent package does for the main one:
+//
+//	import "github.com/iqhive/go-proxyproto/compat/pires/tlvparse"
+package tlvparse
+
+import (
+	proxyproto "github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/tlvparse"
+)
+
+// Types
+type (
+	PP2SSL = tlvparse.PP2SSL
+)
+
+// TLV types and subtypes
+const (
+	PP2_TYPE_AWS            = tlvparse.PP2_TYPE_AWS
+	PP2_SUBTYPE_AWS_VPCE_ID = tlvparse.PP2_SUBTYPE_AWS_VPCE_ID
+
+	PP2_TYPE_AZURE                           = tlvparse.PP2_TYPE_AZURE
+	PP2_SUBTYPE_AZURE_PRIVATEENDPOINT_LINKID = tlvparse.PP2_SUBTYPE_AZURE_PRIVATEENDPOINT_LINKID
+
+	PP2_TYPE_GCP = tlvparse.PP2_TYPE_GCP
+)
+
+// pp2_tlv_ssl.client bit fields
+const (
+	PP2_BITFIELD_CLIENT_SSL       = tlvparse.PP2_BITFIELD_CLIENT_SSL
+	PP2_BITFIELD_CLIENT_CERT_CONN = tlvparse.PP2_BITFIELD_CLIENT_CERT_CONN
+	PP2_BITFIELD_CLIENT_CERT_SESS = tlvparse.PP2_BITFIELD_CLIENT_CERT_SESS
+)
+
+// IsAWSVPCEndpointID is tlvparse.IsAWSVPCEndpointID.
+func IsAWSVPCEndpointID(tlv proxyproto.TLV) bool {
+	return tlvparse.IsAWSVPCEndpointID(tlv)
+}
+
+// AWSVPCEndpointID is tlvparse.AWSVPCEndpointID.
+func AWSVPCEndpointID(tlv proxyproto.TLV) (string, error) {
+	return tlvparse.AWSVPCEndpointID(tlv)
+}
+
+// FindAWSVPCEndpointID is tlvparse.FindAWSVPCEndpointID.
+func FindAWSVPCEndpointID(tlvs []proxyproto.TLV) string {
+	return tlvparse.FindAWSVPCEndpointID(tlvs)
+}
+
+// FindAzurePrivateEndpointLinkID is tlvparse.FindAzurePrivateEndpointLinkID.
+func FindAzurePrivateEndpointLinkID(tlvs []proxyproto.TLV) (uint32, bool) {
+	return tlvparse.FindAzurePrivateEndpointLinkID(tlvs)
+}
+
+// ExtractPSCConnectionID is tlvparse.ExtractPSCConnectionID.
+func ExtractPSCConnectionID(tlvs []proxyproto.TLV) (uint64, bool) {
+	return tlvparse.ExtractPSCConnectionID(tlvs)
+}
+
+// IsSSL is tlvparse.IsSSL.
+func IsSSL(t proxyproto.TLV) bool {
+	return tlvparse.IsSSL(t)
+}
+
+// SSL is tlvparse.SSL.
+func SSL(t proxyproto.TLV) (PP2SSL, error) {
+	return tlvparse.SSL(t)
+}
+
+// FindSSL is tlvparse.FindSSL.
+func FindSSL(tlvs []proxyproto.TLV) (PP2SSL, bool) {
+	return tlvparse.FindSSL(tlvs)
+}
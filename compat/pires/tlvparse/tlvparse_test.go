@@ -0,0 +1,24 @@
+package tlvparse_test
+
+import (
+	"testing"
+
+	proxyproto "github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/compat/pires/tlvparse"
+)
+
+func TestCompatTLVParse(t *testing.T) {
+	tlvs := []proxyproto.TLV{
+		{Type: tlvparse.PP2_TYPE_AWS, Value: append([]byte{tlvparse.PP2_SUBTYPE_AWS_VPCE_ID}, "vpce-08d2bf15fac5001c9"...)},
+		{Type: tlvparse.PP2_TYPE_AZURE, Value: []byte{tlvparse.PP2_SUBTYPE_AZURE_PRIVATEENDPOINT_LINKID, 0x01, 0x00, 0x00, 0x00}},
+	}
+	if id := tlvparse.FindAWSVPCEndpointID(tlvs); id != "vpce-08d2bf15fac5001c9" {
+		t.Fatalf("bad: %q", id)
+	}
+	if id, ok := tlvparse.FindAzurePrivateEndpointLinkID(tlvs); !ok || id != 1 {
+		t.Fatalf("bad: %v %v", id, ok)
+	}
+	if _, err := tlvparse.AWSVPCEndpointID(tlvs[1]); err != proxyproto.ErrIncompatibleTLV {
+		t.Fatalf("err: %v", err)
+	}
+}
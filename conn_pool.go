@@ -0,0 +1,59 @@
+package proxyproto
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// pooledConns counts the pooled Conns in use
+var pooledConns atomic.Int64
+
+var connPool = sync.Pool{
+	New: func() interface{} {
+		return new(Conn)
+	},
+}
+
+// NewPooledConn acts as NewConn, but takes the Conn from a pool it returns
+// to once closed, saving an allocation per connection for servers accepting
+// very high rates of short-lived connections. See Listener.PoolConns.
+//
+// Use it ONLY if the Conn is never used once Close has returned, not even to
+// call Close again or read its header: it may then be wrapping another
+// connection already. This rules out handing the Conn to code which doesn't
+// guarantee it, such as net/http. A detached Conn isn't recycled.
+//
+// Listener.Serve lifts this restriction for its handlers: the Conns it hands
+// them are only recycled by Serve once the handler has returned, so that
+// they may be closed by the handler as well.
+func NewPooledConn(conn net.Conn, opts ...func(*Conn)) *Conn {
+	pConn := initConn(connPool.Get().(*Conn), conn, opts)
+	pConn.pooled = true
+	pooledConns.Add(1)
+	return pConn
+}
+
+// closeServed closes a Conn once its Serve handler has returned and, for a
+// pooled Conn, recycles it. Close doesn't recycle such a Conn, so that the
+// handler closing it too is harmless. A Conn still being read by a goroutine
+// the handler left behind is left to the garbage collector instead.
+func (p *Conn) closeServed() {
+	p.Close()
+	if !p.recycledByServe || p.detached.Load() {
+		return
+	}
+	if p.readerRefs.Load() == 0 {
+		p.recycle()
+	} else {
+		pooledConns.Add(-1)
+	}
+}
+
+// recycle resets p and returns it to the pool, once its reader has been
+// released by Close.
+func (p *Conn) recycle() {
+	*p = Conn{}
+	connPool.Put(p)
+	pooledConns.Add(-1)
+}
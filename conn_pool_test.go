@@ -0,0 +1,53 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPooledConn(t *testing.T) {
+	tests := []struct {
+		header string
+		remote string
+	}{
+		{"PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", "10.1.1.1:1000"},
+		{"PROXY TCP4 10.3.3.3 20.2.2.2 3000 2000\r\n", "10.3.3.3:3000"},
+	}
+	for _, tt := range tests {
+		header := tt.header
+		server, client := net.Pipe()
+		go client.Write([]byte(header))
+
+		conn := NewPooledConn(server, WithPolicy(USE))
+		if conn.ProxyHeader() == nil {
+			t.Fatalf("bad: no header for %q", header)
+		}
+		remote := conn.RemoteAddr().String()
+		if err := conn.Close(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		client.Close()
+
+		// Whether or not the previous Conn was reused, nothing leaks
+		if remote != tt.remote {
+			t.Fatalf("bad: %s for %q", remote, header)
+		}
+	}
+}
+
+func TestPooledConnReset(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+	conn := NewPooledConn(server, WithPolicy(REQUIRE))
+	conn.ProxyHeader()
+	conn.finishHeader()
+	conn.closed.Store(true)
+	conn.releaseReader()
+
+	// The Conn has been reset before going back to the pool
+	if conn.header != nil || conn.conn != nil || conn.ProxyHeaderPolicy != USE || conn.pooled {
+		t.Fatalf("bad: %+v", conn)
+	}
+}
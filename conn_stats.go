@@ -0,0 +1,37 @@
+package proxyproto
+
+import "time"
+
+// ConnStats describes how handling the proxy header of a connection went.
+type ConnStats struct {
+	// HeaderReadDuration is the time spent reading, parsing and validating
+	// the header, including waiting for it to arrive. A slow upstream load
+	// balancer shows up here.
+	HeaderReadDuration time.Duration
+	// ErrorCode classifies the header error, ErrCodeNone if there was none.
+	ErrorCode ErrorCode
+}
+
+// HeaderReadHook receives the stats of a connection once its header has been
+// handled, e.g. to feed metrics. See WithHeaderReadHook.
+type HeaderReadHook func(conn *Conn, stats ConnStats)
+
+// WithHeaderReadHook sets a hook called once the header of a connection has
+// been handled, successfully or not, when passed as option to NewConn().
+func WithHeaderReadHook(hook HeaderReadHook) func(*Conn) {
+	return func(c *Conn) {
+		if hook != nil {
+			c.headerReadHook = hook
+		}
+	}
+}
+
+// Stats reads the header if needed, then returns the stats of the
+// connection.
+func (p *Conn) Stats() ConnStats {
+	p.readHeaderOnce()
+	return ConnStats{
+		HeaderReadDuration: p.headerReadDuration,
+		ErrorCode:          p.readErrCode,
+	}
+}
@@ -0,0 +1,56 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnStats(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	clock := newFakeClock()
+	hooked := make(chan ConnStats, 1)
+	conn := NewConn(server, WithClock(clock), WithHeaderReadHook(func(c *Conn, stats ConnStats) {
+		// The connection can be used from the hook
+		if c.ProxyHeader() == nil {
+			t.Error("bad: no header in hook")
+		}
+		hooked <- stats
+	}))
+	defer conn.Close()
+
+	header, err := (&Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	go func() {
+		client.Write(header[:10])
+		// The write returned once the read started, the header is late
+		clock.Advance(250 * time.Millisecond)
+		client.Write(header[10:])
+	}()
+
+	stats := conn.Stats()
+	if stats.ErrorCode != ErrCodeNone {
+		t.Fatalf("bad: %v", stats.ErrorCode)
+	}
+	if stats.HeaderReadDuration != 250*time.Millisecond {
+		t.Fatalf("bad: %v", stats.HeaderReadDuration)
+	}
+	select {
+	case hookStats := <-hooked:
+		if hookStats != stats {
+			t.Fatalf("bad: %+v", hookStats)
+		}
+	default:
+		t.Fatal("bad: hook not called")
+	}
+}
@@ -0,0 +1,62 @@
+package proxyproto
+
+import "net"
+
+// Detach reads the proxy header if needed, then hands over the underlying
+// connection along with the bytes buffered past the header, including those
+// of WithPrefix, which must be processed before anything read from the
+// connection. The pooled resources of p are released, and the caller becomes
+// the owner of the connection, e.g. to pass its file descriptor to another
+// process.
+//
+// The header remains available through ProxyHeader, but p must not be used
+// otherwise: reads return io.EOF and Close doesn't close the connection
+// anymore. If the header can't be read, its error is returned and p keeps
+// the connection. Detaching a closed connection fails with net.ErrClosed.
+func (p *Conn) Detach() (net.Conn, []byte, error) {
+	if !p.acquireReader() {
+		return nil, nil, net.ErrClosed
+	}
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		p.releaseReader()
+		return nil, nil, p.readErr
+	}
+
+	var buffered []byte
+	if n := p.bufReader.Buffered(); n > 0 {
+		b, _ := p.bufReader.Peek(n)
+		buffered = make([]byte, n)
+		copy(buffered, b)
+		p.bufReader.Discard(n)
+	}
+	if p.prefix.pending() {
+		buffered = append(buffered, p.prefix.rest...)
+		p.prefix.rest = nil
+	}
+
+	// Take the place of Close so that it doesn't close the connection,
+	// unless it got there first
+	p.detached.Store(true)
+	if !p.closed.CompareAndSwap(false, true) {
+		p.detached.Store(false)
+		p.releaseReader()
+		return nil, nil, net.ErrClosed
+	}
+	if p.registry != nil {
+		p.registry.remove(p)
+	}
+	if p.tracked {
+		p.listener.untrack(p)
+	}
+	// Drop both the reference taken above and the one of the connection
+	p.releaseReader()
+	p.releaseReader()
+	// A detached Conn is never recycled, it isn't in use by the pool anymore
+	if p.pooled {
+		pooledConns.Add(-1)
+	}
+
+	return p.conn, buffered, nil
+}
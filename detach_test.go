@@ -0,0 +1,80 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestConnDetach(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nbuffered"))
+
+	conn := NewConn(server)
+	raw, buffered, err := conn.Detach()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer raw.Close()
+	if raw != server || string(buffered) != "buffered" {
+		t.Fatalf("bad: %v, %q", raw, buffered)
+	}
+	if conn.ProxyHeader() == nil {
+		t.Fatal("bad: header lost")
+	}
+
+	// The Conn doesn't own the connection anymore
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	go client.Write([]byte("more"))
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(raw, b); err != nil || string(b) != "more" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+}
+
+func TestConnDetachErrors(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 invalid\r\n"))
+
+	conn := NewConn(server, WithPolicy(REQUIRE))
+	if _, _, err := conn.Detach(); err == nil {
+		t.Fatal("bad: detached despite an invalid header")
+	}
+	conn.Close()
+	if _, _, err := conn.Detach(); err != net.ErrClosed {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestPooledConnDetach(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+	before := GetPoolStats().PooledConns
+	conn := NewPooledConn(server)
+	if n := GetPoolStats().PooledConns; n != before+1 {
+		t.Fatalf("bad: %d pooled conns", n)
+	}
+	raw, _, err := conn.Detach()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer raw.Close()
+	if n := GetPoolStats().PooledConns; n != before {
+		t.Fatalf("bad: %d pooled conns after detach", n)
+	}
+
+	// Closing the detached Conn doesn't count it again
+	conn.Close()
+	if n := GetPoolStats().PooledConns; n != before {
+		t.Fatalf("bad: %d pooled conns after close", n)
+	}
+}
@@ -0,0 +1,112 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// ProxyDialer dials connections and writes a PROXY header on them before
+// handing them to the caller, for clients of servers which require the
+// PROXY protocol. The zero value dials with the zero net.Dialer and sends
+// version 2 LOCAL headers.
+//
+// Names resolving to both IPv6 and IPv4 addresses are dialed as net.Dialer
+// does, racing the address families as of RFC 6555, see
+// net.Dialer.FallbackDelay. The header is only written on the connection
+// which wins, once established, so that the losing one never reaches the
+// server's PROXY protocol handling.
+//
+//	d := &proxyproto.ProxyDialer{Version: 2}
+//	conn, err := d.DialFrom(ctx, inbound, "tcp", "backend:443")
+type ProxyDialer struct {
+	// Dialer dials the connections, if set.
+	Dialer *net.Dialer
+	// Version is the version of the headers derived from addresses, see
+	// DialFrom. Zero means version 2.
+	Version byte
+	// Header, if set, is written by Dial and DialContext. Otherwise, they
+	// write a LOCAL header, carrying no address.
+	Header *Header
+	// UniqueID, if set, generates the PP2_TYPE_UNIQUE_ID TLV added to the
+	// version 2 header of each connection, e.g. RandomUniqueID, so that both
+	// ends can tell it apart in their logs.
+	UniqueID func() ([]byte, error)
+	// Transparent, if set, also dials from the client address of the
+	// header, in addition to sending it, see DialTransparent. Dialing fails
+	// with ErrTransparentNoSource for headers without one, e.g. LOCAL ones.
+	Transparent bool
+}
+
+// Dial acts as DialContext with the background context.
+func (d *ProxyDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext dials addr and writes the header of the dialer on the new
+// connection. The deadline of ctx, if any, applies to writing the header.
+func (d *ProxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	header := d.Header
+	if header == nil {
+		header = HeaderProxyFromAddrs(d.Version, nil, nil)
+	}
+	return d.dial(ctx, network, addr, header)
+}
+
+// DialFrom dials addr and writes a header carrying the addresses of src,
+// as seen by this host: its remote address as source and its local address
+// as destination. When src is a Conn, its addresses are the ones of the
+// header it received, if any, which is forwarded this way.
+func (d *ProxyDialer) DialFrom(ctx context.Context, src net.Conn, network, addr string) (net.Conn, error) {
+	return d.dial(ctx, network, addr, HeaderProxyFromAddrs(d.Version, src.RemoteAddr(), src.LocalAddr()))
+}
+
+// dial dials addr and writes header on the connection, unless nil.
+func (d *ProxyDialer) dial(ctx context.Context, network, addr string, header *Header) (net.Conn, error) {
+	dialer := d.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	if d.Transparent && header != nil {
+		var err error
+		if dialer, err = transparentDialer(dialer, header); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return conn, nil
+	}
+	if d.UniqueID != nil && header.Version == 2 {
+		if header, err = d.withUniqueID(header); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	if _, err := header.WriteTo(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// withUniqueID returns a copy of header with a generated unique ID.
+func (d *ProxyDialer) withUniqueID(header *Header) (*Header, error) {
+	id, err := d.UniqueID()
+	if err != nil {
+		return nil, err
+	}
+	withID := *header
+	if err := withID.SetUniqueID(id); err != nil {
+		return nil, err
+	}
+	return &withID, nil
+}
@@ -0,0 +1,185 @@
+package proxyproto
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestProxyDialer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+	defer pl.Close()
+
+	inbound := &fakeAddrConn{
+		remote: &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		local:  &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	fixed := HeaderProxyFromAddrs(1,
+		&net.TCPAddr{IP: net.ParseIP("10.3.3.3"), Port: 3000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+
+	tests := []struct {
+		name string
+		dial func() (net.Conn, error)
+		want *Header
+	}{
+		{"from", func() (net.Conn, error) {
+			d := &ProxyDialer{Version: 1}
+			return d.DialFrom(context.Background(), inbound, "tcp", pl.Addr().String())
+		}, HeaderProxyFromAddrs(1, inbound.remote, inbound.local)},
+		{"fixed", func() (net.Conn, error) {
+			d := &ProxyDialer{Header: fixed}
+			return d.Dial("tcp", pl.Addr().String())
+		}, fixed},
+		{"local", func() (net.Conn, error) {
+			d := &ProxyDialer{}
+			return d.Dial("tcp", pl.Addr().String())
+		}, HeaderProxyFromAddrs(2, nil, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := tt.dial()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer client.Close()
+
+			conn, err := pl.Accept()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer conn.Close()
+			if got := conn.(*Conn).ProxyHeader(); !got.EqualsTo(tt.want) {
+				t.Fatalf("bad: %+v", got)
+			}
+		})
+	}
+}
+
+type fakeAddrConn struct {
+	net.Conn
+	remote, local net.Addr
+}
+
+func (c *fakeAddrConn) RemoteAddr() net.Addr { return c.remote }
+func (c *fakeAddrConn) LocalAddr() net.Addr  { return c.local }
+
+func TestProxyDialerDualStack(t *testing.T) {
+	l4, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l4}
+	defer pl.Close()
+	port := l4.Addr().(*net.TCPAddr).Port
+
+	// Whatever the loser of the race got, if it got that far, must not
+	// carry a header
+	loser := make(chan []byte, 4)
+	if l6, err := net.Listen("tcp6", net.JoinHostPort("::1", strconv.Itoa(port))); err == nil {
+		defer l6.Close()
+		go func() {
+			for {
+				conn, err := l6.Accept()
+				if err != nil {
+					return
+				}
+				b, _ := io.ReadAll(conn)
+				loser <- b
+				conn.Close()
+			}
+		}()
+	}
+
+	d := &ProxyDialer{
+		Dialer: &net.Dialer{
+			FallbackDelay: 10 * time.Millisecond,
+			Resolver: &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+					server, client := net.Pipe()
+					go serveDualStackDNS(server)
+					return client, nil
+				},
+			},
+		},
+		Header: HeaderProxyFromAddrs(2,
+			&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := d.DialContext(ctx, "tcp", net.JoinHostPort("backend.test", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+
+	if client.RemoteAddr().(*net.TCPAddr).IP.To4() != nil {
+		conn, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+		if got := conn.(*Conn).ProxyHeader(); !got.EqualsTo(d.Header) {
+			t.Fatalf("bad: %+v", got)
+		}
+	}
+	client.Close()
+	select {
+	case b := <-loser:
+		if client.RemoteAddr().(*net.TCPAddr).IP.To4() != nil && len(b) != 0 {
+			t.Fatalf("bad: the losing connection got %q", b)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// serveDualStackDNS answers the queries of conn, framed as over TCP, with
+// the IPv6 and IPv4 loopback addresses.
+func serveDualStackDNS(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil || len(msg.Questions) != 1 {
+			return
+		}
+		q := msg.Questions[0]
+		msg.Header.Response, msg.Header.Authoritative = true, true
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+		switch q.Type {
+		case dnsmessage.TypeA:
+			msg.Answers = []dnsmessage.Resource{{Header: rh, Body: &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}}}
+		case dnsmessage.TypeAAAA:
+			msg.Answers = []dnsmessage.Resource{{Header: rh, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}}}
+		}
+		answer, err := msg.Pack()
+		if err != nil {
+			return
+		}
+		binary.BigEndian.PutUint16(length[:], uint16(len(answer)))
+		if _, err := conn.Write(append(length[:], answer...)); err != nil {
+			return
+		}
+	}
+}
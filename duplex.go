@@ -0,0 +1,191 @@
+package proxyproto
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdleTimeout is returned by CopyDuplex when no data moved in either
+// direction for longer than the idle timeout.
+var ErrIdleTimeout = newError(ErrCodeTimeout, "proxyproto: connection pair idle for too long")
+
+// DuplexSide identifies a connection passed to CopyDuplex.
+type DuplexSide int
+
+const (
+	// SideNone means no error occurred.
+	SideNone DuplexSide = iota
+	// SideA is the first connection passed to CopyDuplex.
+	SideA
+	// SideB is the second connection passed to CopyDuplex.
+	SideB
+)
+
+// DuplexOptions configures CopyDuplex.
+type DuplexOptions struct {
+	// IdleTimeout, if > 0, ends the relay once no data has moved in
+	// either direction for that long. Activity in one direction keeps the
+	// other one alive.
+	IdleTimeout time.Duration
+	// BufferSize is the size of the buffer used by each direction,
+	// 32KB if unset.
+	BufferSize int
+	// Clock, if set, measures the idle timeout instead of the system clock.
+	Clock Clock
+}
+
+// DuplexResult describes how a CopyDuplex relay went.
+type DuplexResult struct {
+	// AToB is the number of bytes read from a and written to b, BToA the
+	// other way around.
+	AToB int64
+	BToA int64
+	// Err is the first error met in either direction, nil if both ended
+	// with EOF. ErrSide is the connection it occurred on.
+	Err     error
+	ErrSide DuplexSide
+}
+
+// CopyDuplex relays data between a and b in both directions until both are
+// done, then closes them. When a direction reaches EOF, the write side of
+// its destination is closed if supported, e.g. by *net.TCPConn, and the
+// other direction carries on. Any error ends both directions.
+//
+// Proxied connections may be passed, in which case their header is read
+// first. As the relay tracks activity, data goes through userspace: use
+// ZeroCopy for each direction to have the kernel move it instead.
+func CopyDuplex(a, b net.Conn, opts DuplexOptions) DuplexResult {
+	d := &duplex{a: a, b: b, opts: opts, clock: clockOrSystem(opts.Clock)}
+	if d.opts.BufferSize <= 0 {
+		d.opts.BufferSize = 32 * 1024
+	}
+	d.touch()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.result.AToB = d.copy(b, SideB, a, SideA)
+	}()
+	go func() {
+		defer wg.Done()
+		d.result.BToA = d.copy(a, SideA, b, SideB)
+	}()
+	wg.Wait()
+
+	a.Close()
+	b.Close()
+	return d.result
+}
+
+type duplex struct {
+	a, b         net.Conn
+	opts         DuplexOptions
+	clock        Clock
+	lastActivity atomic.Int64 // unix nanoseconds
+
+	mu     sync.Mutex
+	result DuplexResult
+	failed bool
+}
+
+func (d *duplex) touch() {
+	d.lastActivity.Store(d.clock.Now().UnixNano())
+}
+
+// idle reports whether nothing moved in either direction for the idle timeout
+func (d *duplex) idle() bool {
+	return d.clock.Now().Sub(time.Unix(0, d.lastActivity.Load())) >= d.opts.IdleTimeout
+}
+
+// fail records the first error and closes both connections to stop the
+// other direction.
+func (d *duplex) fail(err error, side DuplexSide) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failed {
+		return
+	}
+	d.failed = true
+	d.result.Err = err
+	d.result.ErrSide = side
+	d.a.Close()
+	d.b.Close()
+}
+
+func (d *duplex) copy(dst net.Conn, dstSide DuplexSide, src net.Conn, srcSide DuplexSide) int64 {
+	buf := make([]byte, d.opts.BufferSize)
+	var total int64
+
+	readDeadline := newReadDeadline(d.clock, src)
+	writeDeadline := newWriteDeadline(d.clock, dst)
+	defer readDeadline.disarm()
+	defer writeDeadline.disarm()
+
+	for {
+		if d.opts.IdleTimeout > 0 {
+			readDeadline.arm(d.clock.Now().Add(d.opts.IdleTimeout))
+		}
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			d.touch()
+			if d.opts.IdleTimeout > 0 {
+				writeDeadline.arm(d.clock.Now().Add(d.opts.IdleTimeout))
+			}
+			nw, werr := dst.Write(buf[:nr])
+			total += int64(nw)
+			if werr == nil && nw < nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				if isTimeout(werr) {
+					werr = ErrIdleTimeout
+				}
+				d.fail(werr, dstSide)
+				return total
+			}
+			d.touch()
+		}
+
+		if rerr == nil {
+			continue
+		}
+		if rerr == io.EOF {
+			closeWrite(dst)
+			return total
+		}
+		if isTimeout(rerr) && d.opts.IdleTimeout > 0 {
+			// The other direction may have been busy meanwhile
+			if !d.idle() {
+				continue
+			}
+			rerr = ErrIdleTimeout
+		}
+		d.fail(rerr, srcSide)
+		return total
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// closeWrite shuts down the writing side of conn, if it supports it. The
+// lazy header of a proxied connection is written first if nothing was, so
+// that the peer still gets it.
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(*Conn); ok {
+		if c.flushLazyHeader() != nil {
+			return
+		}
+		conn = c.conn
+	}
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	}
+}
@@ -0,0 +1,162 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCopyDuplex(t *testing.T) {
+	a, aPeer := net.Pipe()
+	b, bPeer := net.Pipe()
+
+	done := make(chan DuplexResult, 1)
+	go func() {
+		done <- CopyDuplex(a, b, DuplexOptions{IdleTimeout: time.Second})
+	}()
+
+	if _, err := aPeer.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	recv := make([]byte, 4)
+	if _, err := io.ReadFull(bPeer, recv); err != nil || string(recv) != "ping" {
+		t.Fatalf("bad: %q, %v", recv, err)
+	}
+	if _, err := bPeer.Write([]byte("pong!")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	recv = make([]byte, 5)
+	if _, err := io.ReadFull(aPeer, recv); err != nil || string(recv) != "pong!" {
+		t.Fatalf("bad: %q, %v", recv, err)
+	}
+
+	aPeer.Close()
+	bPeer.Close()
+
+	result := <-done
+	if result.AToB != 4 || result.BToA != 5 {
+		t.Fatalf("bad: %+v", result)
+	}
+	if result.Err != nil || result.ErrSide != SideNone {
+		t.Fatalf("bad: %+v", result)
+	}
+}
+
+func TestCopyDuplexHalfClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	dial := func() (net.Conn, net.Conn) {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server, err := l.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return client, server
+	}
+	client, a := dial()
+	b, backend := dial()
+	defer client.Close()
+	defer backend.Close()
+
+	done := make(chan DuplexResult, 1)
+	go func() {
+		done <- CopyDuplex(a, b, DuplexOptions{})
+	}()
+
+	// The client finishes its request, the backend sees EOF and can still
+	// answer
+	if _, err := client.Write([]byte("request")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	client.(*net.TCPConn).CloseWrite()
+
+	request, err := io.ReadAll(backend)
+	if err != nil || string(request) != "request" {
+		t.Fatalf("bad: %q, %v", request, err)
+	}
+	if _, err := backend.Write([]byte("response")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	backend.Close()
+
+	response, err := io.ReadAll(client)
+	if err != nil || string(response) != "response" {
+		t.Fatalf("bad: %q, %v", response, err)
+	}
+
+	if result := <-done; result.Err != nil || result.AToB != 7 || result.BToA != 8 {
+		t.Fatalf("bad: %+v", result)
+	}
+}
+
+func TestCopyDuplexIdleTimeout(t *testing.T) {
+	a, aPeer := net.Pipe()
+	b, bPeer := net.Pipe()
+	defer aPeer.Close()
+	defer bPeer.Close()
+
+	start := time.Now()
+	result := CopyDuplex(a, b, DuplexOptions{IdleTimeout: 50 * time.Millisecond})
+	if result.Err != ErrIdleTimeout {
+		t.Fatalf("bad: expected %v, got %v", ErrIdleTimeout, result.Err)
+	}
+	if result.ErrSide != SideA && result.ErrSide != SideB {
+		t.Fatalf("bad: side %v", result.ErrSide)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("bad: idle timeout took %v", elapsed)
+	}
+}
+
+func TestCopyDuplexReportsProxyHeaderError(t *testing.T) {
+	a, aPeer := net.Pipe()
+	b, bPeer := net.Pipe()
+	defer bPeer.Close()
+
+	go func() {
+		_, _ = aPeer.Write([]byte("PROXY garbage\r\n"))
+		aPeer.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(io.Discard, bPeer)
+	}()
+
+	result := CopyDuplex(NewConn(a), b, DuplexOptions{})
+	if result.Err == nil || result.ErrSide != SideA {
+		t.Fatalf("bad: %+v", result)
+	}
+}
+
+func TestCopyDuplexLazyHeader(t *testing.T) {
+	a, aPeer := net.Pipe()
+	b, bPeer := net.Pipe()
+	defer bPeer.Close()
+
+	header := HeaderProxyFromAddrs(1,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	lazyB := NewConn(b, WithLazyHeader(header), WithPolicy(SKIP))
+	done := make(chan DuplexResult, 1)
+	go func() {
+		done <- CopyDuplex(a, lazyB, DuplexOptions{})
+	}()
+
+	// The client leaves without sending anything: the backend still gets
+	// the header before the connection ends
+	aPeer.Close()
+	proxied := NewConn(bPeer, WithPolicy(REQUIRE))
+	if got := proxied.ProxyHeader(); !got.EqualsTo(header) {
+		t.Fatalf("bad: %+v", got)
+	}
+	lazyB.Close()
+	<-done
+}
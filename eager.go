@@ -0,0 +1,106 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// eagerAccept reads the headers of the accepted connections in the
+// background for Accept, see Listener.EagerHeaders.
+type eagerAccept struct {
+	ready chan acceptResult
+	// closing is closed along with the listener, done once the accept loop
+	// has stopped, after setting err
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+// acceptResult is a connection whose header was read, or an accept error.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// acceptEager returns the next connection whose header was read and
+// validated. A connection slow to send its header doesn't hold up the
+// others. Accept errors, including the ones of the policy, are returned in
+// turn.
+//
+// At most EagerHeaders connections have their header read or wait for
+// Accept at once, further ones wait in the backlog of the underlying
+// listener.
+func (p *Listener) acceptEager() (net.Conn, error) {
+	p.eagerOnce.Do(func() {
+		e := &eagerAccept{
+			ready:   make(chan acceptResult),
+			closing: make(chan struct{}),
+			done:    make(chan struct{}),
+		}
+		p.eager.Store(e)
+		go e.run(p)
+	})
+	e := p.eager.Load()
+
+	select {
+	case r := <-e.ready:
+		return r.conn, r.err
+	case <-e.done:
+		return nil, e.err
+	}
+}
+
+// run accepts connections until the listener is closed.
+func (e *eagerAccept) run(p *Listener) {
+	slots := make(chan struct{}, p.EagerHeaders)
+	for {
+		slots <- struct{}{}
+		conn, err := p.acceptConn()
+		if err != nil {
+			<-slots
+			if errors.Is(err, net.ErrClosed) {
+				e.err = err
+				close(e.done)
+				// The underlying listener may have been closed directly
+				e.close()
+				return
+			}
+			// Leave it to the caller of Accept to retry or give up
+			select {
+			case e.ready <- acceptResult{err: err}:
+			case <-e.closing:
+			}
+			continue
+		}
+		go e.handle(conn, slots)
+	}
+}
+
+// handle reads the header of conn and hands it to Accept if successful.
+func (e *eagerAccept) handle(conn net.Conn, slots chan struct{}) {
+	defer func() { <-slots }()
+
+	// Connections skipping the PROXY protocol are ready as they are
+	if proxyConn, ok := conn.(*Conn); ok {
+		proxyConn.readHeaderOnce()
+		if proxyConn.readErr != nil {
+			proxyConn.Close()
+			return
+		}
+	}
+
+	select {
+	case e.ready <- acceptResult{conn: conn}:
+	case <-e.closing:
+		conn.Close()
+	}
+}
+
+// close makes the connections waiting for Accept close.
+func (e *eagerAccept) close() {
+	e.closeOnce.Do(func() {
+		close(e.closing)
+	})
+}
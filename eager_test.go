@@ -0,0 +1,101 @@
+package proxyproto
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenerEagerHeaders(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, EagerHeaders: 4}
+	defer pl.Close()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return conn
+	}
+
+	// A client slow to send its header doesn't hold up the next ones, and
+	// an invalid header never makes it out of Accept
+	slow := dial()
+	defer slow.Close()
+	time.Sleep(10 * time.Millisecond)
+	invalid := dial()
+	defer invalid.Close()
+	invalid.Write([]byte("PROXY TCP4 10.1.1.1\r\n"))
+	time.Sleep(10 * time.Millisecond)
+	fast := dial()
+	defer fast.Close()
+	fast.Write([]byte("PROXY TCP4 10.2.2.2 20.2.2.2 2000 3000\r\n"))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != "10.2.2.2:2000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+	if _, err := invalid.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+
+	slow.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 3000\r\n"))
+	conn, err = pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+}
+
+func TestListenerEagerHeadersClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, EagerHeaders: 2}
+
+	header := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+	first, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer first.Close()
+	first.Write(header)
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn.Close()
+
+	// A connection left waiting for Accept is closed along with the
+	// listener
+	waiting, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer waiting.Close()
+	waiting.Write(header)
+	time.Sleep(20 * time.Millisecond)
+	pl.Close()
+
+	waiting.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := waiting.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := pl.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package proxyproto
+
+// Enricher computes extra data about a connection from its proxy header,
+// e.g. GeoIP or ASN details of the claimed client address. It is called
+// once per connection, when the header has been read and accepted, and only
+// if the connection carries one. The result is available from
+// Conn.Enrichment.
+type Enricher func(header *Header) map[string]any
+
+// WithEnricher adds given enricher to a connection when passed as option to NewConn()
+func WithEnricher(e Enricher) func(*Conn) {
+	return func(c *Conn) {
+		if e != nil {
+			c.Enricher = e
+		}
+	}
+}
+
+// Enrichment returns the data computed by the connection's Enricher, reading
+// the proxy header first if needed. It is nil without an enricher, or if the
+// connection carries no valid header.
+func (p *Conn) Enrichment() map[string]any {
+	p.readHeaderOnce()
+	return p.enrichment
+}
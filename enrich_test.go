@@ -0,0 +1,77 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenerEnricher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	calls := 0
+	pl := &Listener{Listener: l, Enricher: func(h *Header) map[string]any {
+		calls++
+		return map[string]any{"client": h.SourceAddr.String()}
+	}}
+	defer pl.Close()
+
+	cliResult := make(chan error)
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			cliResult <- err
+			return
+		}
+		defer conn.Close()
+
+		if _, err := conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping")); err != nil {
+			cliResult <- err
+			return
+		}
+		close(cliResult)
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	recv := make([]byte, 4)
+	if _, err := conn.Read(recv); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := <-cliResult; err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	enrichment := conn.(*Conn).Enrichment()
+	if enrichment["client"] != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", enrichment)
+	}
+	conn.(*Conn).Enrichment()
+	if calls != 1 {
+		t.Fatalf("bad: enricher called %d times", calls)
+	}
+}
+
+func TestEnricherNotCalledWithoutHeader(t *testing.T) {
+	server, client := net.Pipe()
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+		client.Close()
+	}()
+
+	conn := NewConn(server, WithEnricher(func(*Header) map[string]any {
+		t.Error("enricher called without a header")
+		return nil
+	}))
+	defer conn.Close()
+
+	if enrichment := conn.Enrichment(); enrichment != nil {
+		t.Fatalf("bad: %v", enrichment)
+	}
+}
@@ -0,0 +1,81 @@
+package proxyproto
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// headerCancel lets EnsureHeader interrupt the read of the header.
+type headerCancel struct {
+	mu   sync.Mutex
+	done bool  // the read is over, the deadline is the user's again
+	err  error // the error of the context which interrupted the read
+}
+
+// EnsureHeader reads the proxy header if needed and returns it, like
+// ProxyHeader, along with the error met while reading it. The read is
+// interrupted once ctx is done, in which case the error of ctx is returned,
+// here and by Read, and the connection must be closed.
+//
+// The header is nil without an error if the connection doesn't carry one
+// and isn't required to. If the header is being read by another goroutine,
+// e.g. in Read, EnsureHeader waits for it, and interrupts it as well once
+// ctx is done.
+func (p *Conn) EnsureHeader(ctx context.Context) (*Header, error) {
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() {
+			p.cancelHeader(ctx.Err())
+		})
+		defer stop()
+	}
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		return nil, p.readErr
+	}
+	return p.header, nil
+}
+
+// cancelHeader interrupts the read of the header with err, unless it is
+// over already.
+func (p *Conn) cancelHeader(err error) {
+	p.cancel.mu.Lock()
+	defer p.cancel.mu.Unlock()
+
+	if p.cancel.done || p.cancel.err != nil {
+		return
+	}
+	p.cancel.err = err
+	p.conn.SetReadDeadline(aLongTimeAgo)
+}
+
+// setHeaderReadDeadline sets the read deadline of the connection while the
+// header is read, keeping it expired once the read was interrupted.
+func (p *Conn) setHeaderReadDeadline(t time.Time) error {
+	p.cancel.mu.Lock()
+	defer p.cancel.mu.Unlock()
+
+	if p.cancel.err != nil {
+		t = aLongTimeAgo
+	}
+	return p.conn.SetReadDeadline(t)
+}
+
+// endHeaderRead marks the read of the header as over, so that it can't be
+// interrupted anymore. If it was, the deadline set by the user is restored
+// and the error of the context returned.
+func (p *Conn) endHeaderRead() error {
+	p.cancel.mu.Lock()
+	defer p.cancel.mu.Unlock()
+
+	p.cancel.done = true
+	if p.cancel.err != nil {
+		var deadline time.Time
+		if stored := p.readDeadline.Load(); stored != nil {
+			deadline = stored.(time.Time)
+		}
+		p.conn.SetReadDeadline(deadline)
+	}
+	return p.cancel.err
+}
@@ -0,0 +1,92 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEnsureHeader(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+	conn := NewConn(server)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	header, err := conn.EnsureHeader(ctx)
+	if err != nil || header == nil || header.SourceAddr.String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v, %v", header, err)
+	}
+
+	// Canceling once the header has been read leaves the connection alone
+	cancel()
+	go client.Write([]byte("ping"))
+	b := make([]byte, 4)
+	if _, err := conn.Read(b); err != nil || string(b) != "ping" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+}
+
+func TestEnsureHeaderError(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1\r\n"))
+
+	conn := NewConn(server)
+	defer conn.Close()
+	if header, err := conn.EnsureHeader(context.Background()); err == nil || header != nil {
+		t.Fatalf("bad: %v, %v", header, err)
+	}
+}
+
+func TestEnsureHeaderCanceled(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		timeout time.Duration
+		policy  Policy
+	}{
+		{"optional header", 0, USE},
+		{"required header", 0, REQUIRE},
+		{"read header timeout", time.Minute, USE},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+
+			// The upstream doesn't send anything
+			conn := NewConn(server, WithPolicy(tc.policy))
+			conn.readHeaderTimeout = tc.timeout
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if header, err := conn.EnsureHeader(ctx); err != context.DeadlineExceeded || header != nil {
+				t.Fatalf("bad: %v, %v", header, err)
+			}
+			if _, err := conn.Read(make([]byte, 1)); err != context.DeadlineExceeded {
+				t.Fatalf("bad: %v", err)
+			}
+			if conn.ErrorCode() != ErrCodeTimeout {
+				t.Fatalf("bad: %v", conn.ErrorCode())
+			}
+		})
+	}
+}
+
+func TestEnsureHeaderAlreadyCanceled(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConn(server)
+	conn.readHeaderTimeout = time.Minute
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.EnsureHeader(ctx); err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package proxyproto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// ErrorCode is a stable identifier for a class of failures, meant for
+// telemetry: the codes and their names never change meaning, unlike the
+// error messages.
+type ErrorCode uint16
+
+const (
+	// ErrCodeNone means there was no error.
+	ErrCodeNone ErrorCode = iota
+	// ErrCodeUnknown is any error not covered by another code.
+	ErrCodeUnknown
+	// ErrCodeBadSignature means the proxy protocol signature is missing.
+	ErrCodeBadSignature
+	// ErrCodeBadVersion means the version or command is unknown or unsupported.
+	ErrCodeBadVersion
+	// ErrCodeBadFamily means the address family or transport protocol is
+	// unknown or unsupported.
+	ErrCodeBadFamily
+	// ErrCodeBadLength means the header length is invalid.
+	ErrCodeBadLength
+	// ErrCodeBadAddress means an address or port couldn't be parsed.
+	ErrCodeBadAddress
+	// ErrCodeMalformedHeader means a v1 header line couldn't be read or
+	// isn't terminated properly.
+	ErrCodeMalformedHeader
+	// ErrCodeTruncatedTLV means a TLV is cut short.
+	ErrCodeTruncatedTLV
+	// ErrCodeMalformedTLV means a TLV value is invalid for its type.
+	ErrCodeMalformedTLV
+	// ErrCodeOverflow means a size limit was exceeded, e.g. a v1 header
+	// longer than 107 bytes or a header too large to format.
+	ErrCodeOverflow
+	// ErrCodeTimeout means the header wasn't received in time, or a relay
+	// was idle for too long.
+	ErrCodeTimeout
+	// ErrCodeTooSlow means the header wasn't received at the minimum rate.
+	ErrCodeTooSlow
+	// ErrCodePolicyReject means the policy refused the connection or its
+	// header, including well-formed headers of a version, command or family
+	// the connection doesn't allow.
+	ErrCodePolicyReject
+	// ErrCodeValidatorReject means the header validator refused the header.
+	ErrCodeValidatorReject
+	// ErrCodeClosed means the connection was closed before the header was
+	// read.
+	ErrCodeClosed
+	// ErrCodeInvalidUse means the API was misused, e.g. by writing a nil
+	// header.
+	ErrCodeInvalidUse
+	// ErrCodeUnsupported means the platform lacks a feature.
+	ErrCodeUnsupported
+	// ErrCodeTooManyTLVs means a header carries more TLVs than allowed.
+	ErrCodeTooManyTLVs
+)
+
+var errorCodeNames = [...]string{
+	ErrCodeNone:            "none",
+	ErrCodeUnknown:         "unknown",
+	ErrCodeBadSignature:    "bad_signature",
+	ErrCodeBadVersion:      "bad_version",
+	ErrCodeBadFamily:       "bad_family",
+	ErrCodeBadLength:       "bad_length",
+	ErrCodeBadAddress:      "bad_address",
+	ErrCodeMalformedHeader: "malformed_header",
+	ErrCodeTruncatedTLV:    "truncated_tlv",
+	ErrCodeMalformedTLV:    "malformed_tlv",
+	ErrCodeOverflow:        "overflow",
+	ErrCodeTimeout:         "timeout",
+	ErrCodeTooSlow:         "too_slow",
+	ErrCodePolicyReject:    "policy_reject",
+	ErrCodeValidatorReject: "validator_reject",
+	ErrCodeClosed:          "closed",
+	ErrCodeInvalidUse:      "invalid_use",
+	ErrCodeUnsupported:     "unsupported",
+	ErrCodeTooManyTLVs:     "too_many_tlvs",
+}
+
+// String returns the stable name of the code, suitable as a metric label.
+func (c ErrorCode) String() string {
+	if int(c) < len(errorCodeNames) {
+		return errorCodeNames[c]
+	}
+	return errorCodeNames[ErrCodeUnknown]
+}
+
+// Error is the type of the errors of the package, exposing their code:
+//
+//	var perr *proxyproto.Error
+//	if errors.As(err, &perr) {
+//		failures.WithLabelValues(perr.Code.String()).Inc()
+//	}
+//
+// The errors are still the values declared by the package, to be compared
+// with == or errors.Is.
+type Error struct {
+	Code ErrorCode
+	msg  string
+}
+
+func newError(code ErrorCode, msg string) error {
+	return &Error{Code: code, msg: msg}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// errorCodes maps the errors of other packages to their code.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{context.DeadlineExceeded, ErrCodeTimeout},
+	{io.EOF, ErrCodeClosed},
+	{net.ErrClosed, ErrCodeClosed},
+}
+
+// CodeOf returns the code classifying err. Errors returned by validators
+// can't be told apart from other errors by their value, use Conn.ErrorCode
+// to classify the error of a connection.
+func CodeOf(err error) ErrorCode {
+	if err == nil {
+		return ErrCodeNone
+	}
+	// Timeouts come first, they may be wrapped by a parse error
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrCodeTimeout
+	}
+	var perr *Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return ErrCodeUnknown
+}
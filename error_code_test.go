@@ -0,0 +1,162 @@
+package proxyproto
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		code ErrorCode
+	}{
+		{nil, ErrCodeNone},
+		{errors.New("other"), ErrCodeUnknown},
+		{ErrNoProxyProtocol, ErrCodeBadSignature},
+		{ErrUnsupportedAddressFamilyAndProtocol, ErrCodeBadFamily},
+		{ErrTruncatedTLV, ErrCodeTruncatedTLV},
+		{ErrTooManyTLVs, ErrCodeTooManyTLVs},
+		{ErrVersion1HeaderTooLong, ErrCodeOverflow},
+		{ErrSuperfluousProxyHeader, ErrCodePolicyReject},
+		{fmt.Errorf("wrapped: %w", ErrInvalidUpstream), ErrCodePolicyReject},
+		{os.ErrDeadlineExceeded, ErrCodeTimeout},
+		{fmt.Errorf("%w: %w", ErrCantReadVersion1Header, os.ErrDeadlineExceeded), ErrCodeTimeout},
+		{ErrCommandNotAllowed, ErrCodePolicyReject},
+		{ErrVersionNotAllowed, ErrCodePolicyReject},
+		{ErrFamilyNotAllowed, ErrCodePolicyReject},
+		{ErrTooManyConns, ErrCodePolicyReject},
+		{ErrIdleTimeout, ErrCodeTimeout},
+		{ErrNilHeader, ErrCodeInvalidUse},
+		{ErrTransparentUnsupported, ErrCodeUnsupported},
+	}
+
+	for _, tt := range tests {
+		if code := CodeOf(tt.err); code != tt.code {
+			t.Errorf("CodeOf(%v): expected %v, got %v", tt.err, tt.code, code)
+		}
+	}
+}
+
+// TestErrorCodesComplete checks that every exported error of the package is
+// an *Error with a code, from the sources as the errors can't be listed.
+func TestErrorCodesComplete(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	declared := 0
+	for _, file := range pkgs["proxyproto"].Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if !strings.HasPrefix(name.Name, "Err") || !name.IsExported() {
+						continue
+					}
+					declared++
+					call, ok := vs.Values[i].(*ast.CallExpr)
+					if id, isIdent := call.Fun.(*ast.Ident); !ok || !isIdent || id.Name != "newError" {
+						t.Errorf("%s isn't declared with newError", name.Name)
+					}
+				}
+			}
+		}
+	}
+	if declared == 0 {
+		t.Fatal("bad: no errors found")
+	}
+}
+
+func TestErrorAs(t *testing.T) {
+	var perr *Error
+	if !errors.As(fmt.Errorf("wrapped: %w", ErrTruncatedTLV), &perr) || perr.Code != ErrCodeTruncatedTLV {
+		t.Fatalf("bad: %v", perr)
+	}
+	if !errors.As(&FamilyError{TransportProtocol: UDPv4}, &perr) || perr.Code != ErrCodePolicyReject {
+		t.Fatalf("bad: %v", perr)
+	}
+	if errors.As(io.EOF, &perr) {
+		t.Fatalf("bad: %v", perr)
+	}
+}
+
+func TestErrorCodeString(t *testing.T) {
+	if ErrCodeValidatorReject.String() != "validator_reject" {
+		t.Fatalf("bad: %v", ErrCodeValidatorReject.String())
+	}
+	if ErrCodeTooManyTLVs.String() != "too_many_tlvs" {
+		t.Fatalf("bad: %v", ErrCodeTooManyTLVs.String())
+	}
+	if ErrorCode(1000).String() != "unknown" {
+		t.Fatalf("bad: %v", ErrorCode(1000).String())
+	}
+}
+
+func TestConnErrorCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		opts    []func(*Conn)
+		code    ErrorCode
+	}{
+		{
+			name:    "valid header",
+			payload: "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n",
+			code:    ErrCodeNone,
+		},
+		{
+			name:    "validator reject",
+			payload: "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n",
+			opts:    []func(*Conn){ValidateHeader(func(*Header) error { return errors.New("no") })},
+			code:    ErrCodeValidatorReject,
+		},
+		{
+			name:    "policy reject",
+			payload: "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n",
+			opts:    []func(*Conn){WithPolicy(REJECT)},
+			code:    ErrCodePolicyReject,
+		},
+		{
+			name:    "timeout",
+			payload: "",
+			opts:    []func(*Conn){WithPolicy(REQUIRE), SetReadHeaderTimeout(50 * time.Millisecond)},
+			code:    ErrCodeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go func() {
+				if tt.payload != "" {
+					_, _ = client.Write([]byte(tt.payload))
+				}
+			}()
+
+			conn := NewConn(server, tt.opts...)
+			defer conn.Close()
+			if code := conn.ErrorCode(); code != tt.code {
+				t.Fatalf("bad: expected %v, got %v", tt.code, code)
+			}
+		})
+	}
+}
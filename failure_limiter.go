@@ -0,0 +1,156 @@
+package proxyproto
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrSourceBanned is reported to Listener.OnError for the connections closed
+// by Accept because their source is banned by the FailureLimiter.
+var ErrSourceBanned = newError(ErrCodePolicyReject, "proxyproto: source banned for failing too often")
+
+// failureLimiterSweepSize is the number of tracked sources above which
+// expired records are swept before tracking a new one.
+const failureLimiterSweepSize = 1024
+
+// FailureLimiter tracks PROXY header failures per upstream IP address and
+// temporarily bans sources which fail too often, see Listener.FailureLimiter.
+//
+// A failure is any error returned while reading the header: a malformed or
+// superfluous header, a missing one under the REQUIRE policy or a header
+// rejected by the validator. A successfully read header clears the failures
+// recorded for its source.
+type FailureLimiter struct {
+	// Clock, if set, measures windows and bans instead of the system clock.
+	// It must be set before the limiter is used.
+	Clock Clock
+
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu      sync.Mutex
+	sources map[string]*failureRecord
+}
+
+type failureRecord struct {
+	failures    int
+	windowStart time.Time
+	bannedUntil time.Time
+}
+
+// NewFailureLimiter returns a FailureLimiter banning a source for cooldown
+// once threshold failures have been recorded for it within window. A window
+// <= 0 never forgets failures until a header succeeds, and a threshold <= 0
+// disables banning altogether.
+func NewFailureLimiter(threshold int, window, cooldown time.Duration) *FailureLimiter {
+	return &FailureLimiter{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		sources:   make(map[string]*failureRecord),
+	}
+}
+
+// Banned reports whether connections from upstream are currently refused.
+func (l *FailureLimiter) Banned(upstream net.Addr) bool {
+	key, ok := failureKey(upstream)
+	if !ok {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.sources[key]
+	if !ok || record.bannedUntil.IsZero() {
+		return false
+	}
+	if clockOrSystem(l.Clock).Now().Before(record.bannedUntil) {
+		return true
+	}
+
+	// The ban is over, start counting from scratch
+	delete(l.sources, key)
+	return false
+}
+
+// Failure records a failure for upstream, banning it once the threshold is
+// reached.
+func (l *FailureLimiter) Failure(upstream net.Addr) {
+	if l.threshold <= 0 {
+		return
+	}
+	key, ok := failureKey(upstream)
+	if !ok {
+		return
+	}
+
+	now := clockOrSystem(l.Clock).Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, ok := l.sources[key]
+	if !ok {
+		if len(l.sources) >= failureLimiterSweepSize {
+			l.sweep(now)
+		}
+		record = &failureRecord{windowStart: now}
+		l.sources[key] = record
+	} else if l.window > 0 && now.Sub(record.windowStart) > l.window {
+		record.failures = 0
+		record.windowStart = now
+	}
+
+	record.failures++
+	if record.failures >= l.threshold {
+		record.bannedUntil = now.Add(l.cooldown)
+	}
+}
+
+// Success clears the failures recorded for upstream.
+func (l *FailureLimiter) Success(upstream net.Addr) {
+	key, ok := failureKey(upstream)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Leave banned sources alone: a connection accepted before the ban
+	// mustn't lift it
+	if record, ok := l.sources[key]; ok && record.bannedUntil.IsZero() {
+		delete(l.sources, key)
+	}
+}
+
+// sweep drops the records which no longer affect any source.
+func (l *FailureLimiter) sweep(now time.Time) {
+	for key, record := range l.sources {
+		if !record.bannedUntil.IsZero() {
+			if now.After(record.bannedUntil) {
+				delete(l.sources, key)
+			}
+			continue
+		}
+		if l.window > 0 && now.Sub(record.windowStart) > l.window {
+			delete(l.sources, key)
+		}
+	}
+}
+
+// failureKey returns the IP address failures of upstream are tracked under.
+// Addresses without an IP, e.g. unix sockets, aren't tracked.
+func failureKey(upstream net.Addr) (string, bool) {
+	if upstream == nil {
+		return "", false
+	}
+	ip, err := ipFromAddr(upstream)
+	if err != nil {
+		return "", false
+	}
+	return ip.String(), true
+}
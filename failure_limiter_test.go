@@ -0,0 +1,113 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFailureLimiterBansAfterThreshold(t *testing.T) {
+	l := NewFailureLimiter(2, time.Minute, time.Hour)
+	upstream := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	other := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 1000}
+
+	l.Failure(upstream)
+	if l.Banned(upstream) {
+		t.Fatal("bad: source banned below the threshold")
+	}
+
+	// A different port of the same source counts towards the same limit
+	l.Failure(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 2000})
+	if !l.Banned(upstream) {
+		t.Fatal("bad: source not banned at the threshold")
+	}
+	if l.Banned(other) {
+		t.Fatal("bad: unrelated source banned")
+	}
+
+	// Success doesn't lift a ban
+	l.Success(upstream)
+	if !l.Banned(upstream) {
+		t.Fatal("bad: ban lifted by a success")
+	}
+}
+
+func TestFailureLimiterSuccessClearsFailures(t *testing.T) {
+	l := NewFailureLimiter(2, time.Minute, time.Hour)
+	upstream := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+
+	l.Failure(upstream)
+	l.Success(upstream)
+	l.Failure(upstream)
+	if l.Banned(upstream) {
+		t.Fatal("bad: failures before a success were counted")
+	}
+}
+
+func TestFailureLimiterCooldown(t *testing.T) {
+	l := NewFailureLimiter(1, time.Minute, 10*time.Millisecond)
+	upstream := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+
+	l.Failure(upstream)
+	if !l.Banned(upstream) {
+		t.Fatal("bad: source not banned")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if l.Banned(upstream) {
+		t.Fatal("bad: ban not lifted after the cooldown")
+	}
+}
+
+func TestListenerFailureLimiter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	limiter := NewFailureLimiter(1, time.Minute, time.Hour)
+	pl := &Listener{Listener: l, FailureLimiter: limiter}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("PROXY garbage\r\n"))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("err: expected an invalid header error")
+	}
+	upstream := conn.(*Conn).Raw().RemoteAddr()
+	conn.Close()
+
+	if !limiter.Banned(upstream) {
+		t.Fatal("bad: failing source not banned")
+	}
+
+	// The next connection from the banned source is dropped by Accept
+	dropped := make(chan error, 1)
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			dropped <- err
+			return
+		}
+		defer conn.Close()
+		_, err = conn.Read(make([]byte, 1))
+		dropped <- err
+		pl.Close()
+	}()
+
+	if _, err := pl.Accept(); err == nil {
+		t.Fatal("err: expected no connection to be accepted")
+	}
+	if err := <-dropped; err == nil {
+		t.Fatal("err: expected the banned connection to be closed")
+	}
+}
@@ -2,9 +2,6 @@ module github.com/iqhive/go-proxyproto
 
 go 1.23
 
-require (
-	golang.org/x/net v0.23.0
-	golang.org/x/sys v0.18.0
-)
+require golang.org/x/net v0.23.0
 
 require golang.org/x/text v0.14.0 // indirect
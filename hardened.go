@@ -0,0 +1,180 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net/netip"
+	"strings"
+)
+
+// Limits enforced by HardenedMode.
+const (
+	// HardenedMaxHeaderLen is the maximum length of a version 2 header,
+	// room for Unix socket addresses and a few TLVs.
+	HardenedMaxHeaderLen = 1024
+	// HardenedMaxTLVs is the maximum number of TLVs of a version 2 header.
+	HardenedMaxTLVs = 16
+)
+
+var (
+	// ErrHeaderTooLarge is returned for version 2 headers longer than
+	// HardenedMaxHeaderLen, see HardenedMode, or carrying more TLV bytes
+	// than allowed, see WithTLVLimits.
+	ErrHeaderTooLarge = newError(ErrCodeOverflow, "proxyproto: header too large")
+	// ErrMissingChecksum is returned for version 2 headers without a CRC32C
+	// TLV, see HardenedMode.
+	ErrMissingChecksum = newError(ErrCodeMalformedTLV, "proxyproto: header has no CRC32C checksum")
+	// ErrChecksumMismatch is returned for version 2 headers whose CRC32C TLV
+	// doesn't match their content, see HardenedMode.
+	ErrChecksumMismatch = newError(ErrCodeMalformedTLV, "proxyproto: header CRC32C checksum mismatch")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// HardenedMode applies the strictest checks to the header of a connection
+// when passed as option to NewConn(), for internet-facing listeners whose
+// upstreams are only semi-trusted:
+//
+//   - A version 1 header has exactly the fields the spec defines: no
+//     trailing fields, even after UNKNOWN, ports in canonical decimal form,
+//     and no IPv4 address in a TCP6 line.
+//   - A version 2 header is at most HardenedMaxHeaderLen bytes long, with
+//     at most HardenedMaxTLVs well-formed TLVs and no IPv4-mapped address
+//     in an IPv6 address block.
+//   - A version 2 header carries a CRC32C TLV, which must match.
+//   - A header which isn't received within the read header timeout fails
+//     the connection even under the USE policy, rather than letting it
+//     through as if no header was sent.
+//
+// The checks are made before the header is consumed.
+func HardenedMode() func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.hardened = true
+	}
+}
+
+// checkStrictVersion1 checks the version 1 header at the start of reader.
+// Lines which can't be peeked at entirely are left to parseVersion1, which
+// fails for them.
+func checkStrictVersion1(reader *bufio.Reader) error {
+	line, _ := reader.Peek(min(reader.Buffered(), v1HeaderMaxLen))
+	i := bytes.IndexByte(line, '\n')
+	if i < 1 || line[i-1] != '\r' {
+		return nil
+	}
+	tokens := strings.Split(string(line[:i-1]), separator)
+	if len(tokens) < 2 {
+		return nil
+	}
+
+	switch tokens[1] {
+	case "UNKNOWN":
+		if len(tokens) != 2 {
+			return ErrCantReadAddressFamilyAndProtocol
+		}
+		return nil
+	case "TCP4", "TCP6":
+		if len(tokens) != 6 {
+			return ErrCantReadAddressFamilyAndProtocol
+		}
+	default:
+		return nil
+	}
+
+	for _, token := range tokens[2:4] {
+		addr, err := netip.ParseAddr(token)
+		if err != nil || addr.Zone() != "" || addr.Is4() != (tokens[1] == "TCP4") || addr.Is4In6() {
+			return ErrInvalidAddress
+		}
+	}
+	for _, token := range tokens[4:6] {
+		if !isCanonicalPort(token) {
+			return ErrInvalidPortNumber
+		}
+	}
+	return nil
+}
+
+// isCanonicalPort reports whether s is a port number without sign or
+// leading zero.
+func isCanonicalPort(s string) bool {
+	if len(s) == 0 || len(s) > 5 || (s[0] == '0' && len(s) > 1) {
+		return false
+	}
+	port := 0
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+		port = port*10 + int(c-'0')
+	}
+	return port <= 65535
+}
+
+// checkHardenedVersion2 checks the version 2 header at the start of reader.
+// Headers which are truncated or have an invalid length are left to
+// parseVersion2, which fails for them.
+func checkHardenedVersion2(reader *bufio.Reader) error {
+	prefix, err := reader.Peek(v2HeaderPrefixLen)
+	if err != nil {
+		return nil
+	}
+	length := v2HeaderPrefixLen + int(binary.BigEndian.Uint16(prefix[14:v2HeaderPrefixLen]))
+	if length > HardenedMaxHeaderLen {
+		return ErrHeaderTooLarge
+	}
+	raw, err := reader.Peek(length)
+	if err != nil {
+		return nil
+	}
+
+	addrLen := addressBlockLen(AddressFamilyAndProtocol(raw[13]))
+	if length < v2HeaderPrefixLen+addrLen {
+		return nil
+	}
+	if addrLen == int(lengthV6) {
+		src, _ := netip.AddrFromSlice(raw[16:32])
+		dst, _ := netip.AddrFromSlice(raw[32:48])
+		if src.Is4In6() || dst.Is4In6() {
+			return ErrInvalidAddress
+		}
+	}
+
+	crcOffset := -1
+	count := 0
+	for i := v2HeaderPrefixLen + addrLen; i < length; count++ {
+		if count == HardenedMaxTLVs {
+			return ErrTooManyTLVs
+		}
+		if length-i < 3 {
+			return ErrTruncatedTLV
+		}
+		tlvType := PP2Type(raw[i])
+		tlvLen := int(binary.BigEndian.Uint16(raw[i+1 : i+3]))
+		i += 3
+		if length-i < tlvLen {
+			return ErrTruncatedTLV
+		}
+		if tlvType == PP2_TYPE_CRC32C {
+			if crcOffset >= 0 || tlvLen != 4 {
+				return ErrMalformedTLV
+			}
+			crcOffset = i
+		}
+		i += tlvLen
+	}
+	if crcOffset < 0 {
+		return ErrMissingChecksum
+	}
+
+	// The checksum covers the header with the checksum itself zeroed
+	var buf [HardenedMaxHeaderLen]byte
+	header := buf[:copy(buf[:], raw)]
+	clear(header[crcOffset : crcOffset+4])
+	if crc32.Checksum(header, crc32cTable) != binary.BigEndian.Uint32(raw[crcOffset:crcOffset+4]) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
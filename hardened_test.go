@@ -0,0 +1,109 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+)
+
+// formatWithCRC32C formats header with a CRC32C TLV appended to its TLVs.
+func formatWithCRC32C(t *testing.T, header *Header) []byte {
+	t.Helper()
+	tlvs, err := header.TLVs()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	withCRC := *header
+	if err := withCRC.SetTLVs(append(tlvs, TLV{Type: PP2_TYPE_CRC32C, Value: make([]byte, 4)})); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := withCRC.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	binary.BigEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)))
+	return b
+}
+
+func TestHardenedMode(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	valid := formatWithCRC32C(t, header)
+	withoutCRC, _ := header.Format()
+	corrupted := bytes.Clone(valid)
+	corrupted[20] ^= 0xFF
+
+	mapped := *header
+	mapped.TransportProtocol = TCPv6
+	mapped.SourceAddr = &net.TCPAddr{IP: net.ParseIP("::ffff:10.1.1.1"), Port: 1000}
+	mapped.DestinationAddr = &net.TCPAddr{IP: net.ParseIP("::2"), Port: 2000}
+
+	tooMany := *header
+	tlvs := make([]TLV, HardenedMaxTLVs)
+	for i := range tlvs {
+		tlvs[i] = TLV{Type: PP2_TYPE_NOOP}
+	}
+	tooMany.SetTLVs(tlvs)
+
+	tooLarge := *header
+	tooLarge.SetTLVs([]TLV{{Type: PP2Type(0xE0), Value: make([]byte, HardenedMaxHeaderLen)}})
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		lenient error
+		err     error
+	}{
+		{"v2 with checksum", valid, nil, nil},
+		{"v2 without checksum", withoutCRC, nil, ErrMissingChecksum},
+		{"v2 with bad checksum", corrupted, nil, ErrChecksumMismatch},
+		{"v2 with mapped address", formatWithCRC32C(t, &mapped), nil, ErrInvalidAddress},
+		{"v2 with too many TLVs", formatWithCRC32C(t, &tooMany), nil, ErrTooManyTLVs},
+		{"v2 too large", formatWithCRC32C(t, &tooLarge), nil, ErrHeaderTooLarge},
+		{"v1", []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"), nil, nil},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), nil, nil},
+		{"v1 trailing field", []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000 3000\r\n"), nil, ErrCantReadAddressFamilyAndProtocol},
+		{"v1 unknown with fields", []byte("PROXY UNKNOWN 10.1.1.1 20.2.2.2 1000 2000\r\n"), nil, ErrCantReadAddressFamilyAndProtocol},
+		{"v1 signed port", []byte("PROXY TCP4 10.1.1.1 20.2.2.2 +1000 2000\r\n"), nil, ErrInvalidPortNumber},
+		{"v1 padded port", []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 02000\r\n"), nil, ErrInvalidPortNumber},
+		{"v1 mapped address", []byte("PROXY TCP6 ::ffff:10.1.1.1 ::2 1000 2000\r\n"), nil, ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bufio.NewReader(bytes.NewReader(tt.raw))); err != tt.lenient {
+				t.Fatalf("bad: lenient parsing returned %v", err)
+			}
+			reader := bufio.NewReader(bytes.NewReader(tt.raw))
+			if _, err := (parseOptions{hardened: true}).read(reader); err != tt.err {
+				t.Fatalf("bad: %v, want %v", err, tt.err)
+			}
+			// Nothing is consumed by a failed check
+			if tt.err != nil && reader.Buffered() != len(tt.raw) {
+				t.Fatalf("bad: %d bytes consumed", len(tt.raw)-reader.Buffered())
+			}
+		})
+	}
+}
+
+func TestHardenedModeTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, WithPolicy(USE), SetReadHeaderTimeout(10*time.Millisecond), HardenedMode())
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 1)); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+	if conn.ErrorCode() != ErrCodeTimeout {
+		t.Fatalf("bad: %v", conn.ErrorCode())
+	}
+}
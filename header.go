@@ -5,9 +5,12 @@ package proxyproto
 import (
 	"bufio"
 	"bytes"
-	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net"
+	"slices"
+	"sync"
 	"time"
 )
 
@@ -16,22 +19,22 @@ var (
 	SIGV1 = []byte{'\x50', '\x52', '\x4F', '\x58', '\x59'}
 	SIGV2 = []byte{'\x0D', '\x0A', '\x0D', '\x0A', '\x00', '\x0D', '\x0A', '\x51', '\x55', '\x49', '\x54', '\x0A'}
 
-	ErrCantReadVersion1Header               = errors.New("proxyproto: can't read version 1 header")
-	ErrVersion1HeaderTooLong                = errors.New("proxyproto: version 1 header must be 107 bytes or less")
-	ErrLineMustEndWithCrlf                  = errors.New("proxyproto: version 1 header is invalid, must end with \\r\\n")
-	ErrCantReadProtocolVersionAndCommand    = errors.New("proxyproto: can't read proxy protocol version and command")
-	ErrCantReadAddressFamilyAndProtocol     = errors.New("proxyproto: can't read address family or protocol")
-	ErrCantReadLength                       = errors.New("proxyproto: can't read length")
-	ErrCantResolveSourceUnixAddress         = errors.New("proxyproto: can't resolve source Unix address")
-	ErrCantResolveDestinationUnixAddress    = errors.New("proxyproto: can't resolve destination Unix address")
-	ErrNoProxyProtocol                      = errors.New("proxyproto: proxy protocol signature not present")
-	ErrUnknownProxyProtocolVersion          = errors.New("proxyproto: unknown proxy protocol version")
-	ErrUnsupportedProtocolVersionAndCommand = errors.New("proxyproto: unsupported proxy protocol version and command")
-	ErrUnsupportedAddressFamilyAndProtocol  = errors.New("proxyproto: unsupported address family and protocol")
-	ErrInvalidLength                        = errors.New("proxyproto: invalid length")
-	ErrInvalidAddress                       = errors.New("proxyproto: invalid address")
-	ErrInvalidPortNumber                    = errors.New("proxyproto: invalid port number")
-	ErrSuperfluousProxyHeader               = errors.New("proxyproto: upstream connection sent PROXY header but isn't allowed to send one")
+	ErrCantReadVersion1Header               = newError(ErrCodeMalformedHeader, "proxyproto: can't read version 1 header")
+	ErrVersion1HeaderTooLong                = newError(ErrCodeOverflow, "proxyproto: version 1 header must be 107 bytes or less")
+	ErrLineMustEndWithCrlf                  = newError(ErrCodeMalformedHeader, "proxyproto: version 1 header is invalid, must end with \\r\\n")
+	ErrCantReadProtocolVersionAndCommand    = newError(ErrCodeBadVersion, "proxyproto: can't read proxy protocol version and command")
+	ErrCantReadAddressFamilyAndProtocol     = newError(ErrCodeBadFamily, "proxyproto: can't read address family or protocol")
+	ErrCantReadLength                       = newError(ErrCodeBadLength, "proxyproto: can't read length")
+	ErrCantResolveSourceUnixAddress         = newError(ErrCodeBadAddress, "proxyproto: can't resolve source Unix address")
+	ErrCantResolveDestinationUnixAddress    = newError(ErrCodeBadAddress, "proxyproto: can't resolve destination Unix address")
+	ErrNoProxyProtocol                      = newError(ErrCodeBadSignature, "proxyproto: proxy protocol signature not present")
+	ErrUnknownProxyProtocolVersion          = newError(ErrCodeBadVersion, "proxyproto: unknown proxy protocol version")
+	ErrUnsupportedProtocolVersionAndCommand = newError(ErrCodeBadVersion, "proxyproto: unsupported proxy protocol version and command")
+	ErrUnsupportedAddressFamilyAndProtocol  = newError(ErrCodeBadFamily, "proxyproto: unsupported address family and protocol")
+	ErrInvalidLength                        = newError(ErrCodeBadLength, "proxyproto: invalid length")
+	ErrInvalidAddress                       = newError(ErrCodeBadAddress, "proxyproto: invalid address")
+	ErrInvalidPortNumber                    = newError(ErrCodeBadAddress, "proxyproto: invalid port number")
+	ErrSuperfluousProxyHeader               = newError(ErrCodePolicyReject, "proxyproto: upstream connection sent PROXY header but isn't allowed to send one")
 )
 
 // Header is the placeholder for proxy protocol header.
@@ -42,6 +45,8 @@ type Header struct {
 	SourceAddr        net.Addr
 	DestinationAddr   net.Addr
 	rawTLVs           []byte
+	// tlvs caches the TLVs split from rawTLVs, see setRawTLVs
+	tlvs *tlvCache
 }
 
 // HeaderProxyFromAddrs creates a new PROXY header from a source and a
@@ -97,6 +102,32 @@ func HeaderProxyFromAddrs(version byte, sourceAddr, destAddr net.Addr) *Header {
 	return h
 }
 
+// HeaderWithTLVs creates a new version 2 header carrying no address, only
+// the given TLVs, e.g. to exchange metadata over a control channel. As per
+// specification, such a header uses the LOCAL command and the UNSPEC family,
+// so receivers keep the real connection endpoints.
+func HeaderWithTLVs(tlvs []TLV) (*Header, error) {
+	h := &Header{
+		Version:           2,
+		Command:           LOCAL,
+		TransportProtocol: UNSPEC,
+	}
+	if err := h.SetTLVs(tlvs); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WriteTLVs writes a version 2 header carrying only the given TLVs to w. See
+// HeaderWithTLVs.
+func WriteTLVs(w io.Writer, tlvs []TLV) (int64, error) {
+	h, err := HeaderWithTLVs(tlvs)
+	if err != nil {
+		return 0, err
+	}
+	return h.WriteTo(w)
+}
+
 func (header *Header) TCPAddrs() (sourceAddr, destAddr *net.TCPAddr, ok bool) {
 	if !header.TransportProtocol.IsStream() {
 		return nil, nil, false
@@ -196,7 +227,8 @@ func (header *Header) WriteTo(w io.Writer) (int64, error) {
 	return int64(n), err
 }
 
-// Format renders a proxy protocol header with minimal allocations.
+// Format renders a proxy protocol header with minimal allocations. The
+// zones of IPv6 addresses are dropped, see SetIPv6Zone.
 func (header *Header) Format() ([]byte, error) {
 	switch header.Version {
 	case 1:
@@ -209,8 +241,48 @@ func (header *Header) Format() ([]byte, error) {
 }
 
 // TLVs returns the TLVs stored into this header, if they exist.  TLVs are optional for v2 of the protocol.
+//
+// The TLVs are split once and cached, so that calling TLVs repeatedly, e.g.
+// through the typed getters such as Authority, is cheap. The returned slice
+// belongs to the caller, but the values of the TLVs must not be modified.
 func (header *Header) TLVs() ([]TLV, error) {
-	return SplitTLVs(header.rawTLVs)
+	c := header.tlvs
+	if c == nil {
+		return SplitTLVs(header.rawTLVs)
+	}
+	c.once.Do(func() {
+		c.tlvs, c.err = SplitTLVs(header.rawTLVs)
+	})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return slices.Clone(c.tlvs), nil
+}
+
+// TLVsLimit acts as TLVs, but fails with ErrTooManyTLVs if the header
+// carries more than maxCount TLVs, for headers which weren't read with the
+// limit of WithTLVLimits or Listener.MaxTLVCount. Zero means no limit.
+func (header *Header) TLVsLimit(maxCount int) ([]TLV, error) {
+	if maxCount > 0 && countTLVs(header.rawTLVs, maxCount) > maxCount {
+		return nil, ErrTooManyTLVs
+	}
+	return header.TLVs()
+}
+
+// RawAddressBlock returns the bytes following the length of a version 2
+// header whose address family is unspecified, which receivers must ignore.
+// As nothing delimits an address block from the TLVs in that case, those are
+// the same bytes TLVs parses, returned untouched for relays to forward
+// as they are: formatting the header writes them back verbatim. It returns
+// nil for any other header.
+//
+// Families other than the ones defined by the specification are rejected
+// when parsing, as it mandates.
+func (header *Header) RawAddressBlock() []byte {
+	if header.Version != 2 || header.TransportProtocol&0xF0 != 0 {
+		return nil
+	}
+	return header.rawTLVs
 }
 
 // SetTLVs sets the TLVs stored in this header. This method replaces any
@@ -220,10 +292,43 @@ func (header *Header) SetTLVs(tlvs []TLV) error {
 	if err != nil {
 		return err
 	}
-	header.rawTLVs = raw
+	header.setRawTLVs(raw)
+	return nil
+}
+
+// AddTLV appends tlv to the TLVs stored in this header, keeping the
+// previous ones.
+func (header *Header) AddTLV(tlv TLV) error {
+	if len(tlv.Value) > math.MaxUint16 {
+		return fmt.Errorf("proxyproto: cannot format TLV %v with length %d", tlv.Type, len(tlv.Value))
+	}
+	// The raw TLVs may be a slice of a larger buffer, never append in place
+	raw := make([]byte, len(header.rawTLVs), len(header.rawTLVs)+3+len(tlv.Value))
+	copy(raw, header.rawTLVs)
+	raw = append(raw, byte(tlv.Type), byte(len(tlv.Value)>>8), byte(len(tlv.Value)))
+	raw = append(raw, tlv.Value...)
+	header.setRawTLVs(raw)
 	return nil
 }
 
+// tlvCache holds the TLVs split from the raw TLVs of a header. It is shared
+// by the copies of the header, which is fine as it is replaced along with
+// the raw TLVs.
+type tlvCache struct {
+	once sync.Once
+	tlvs []TLV
+	err  error
+}
+
+// setRawTLVs replaces the raw TLVs of the header and resets their cache.
+func (header *Header) setRawTLVs(raw []byte) {
+	header.rawTLVs = raw
+	header.tlvs = nil
+	if len(raw) > 0 {
+		header.tlvs = &tlvCache{}
+	}
+}
+
 // Read identifies the proxy protocol version and reads the remaining of
 // the header, accordingly.
 //
@@ -234,6 +339,57 @@ func (header *Header) SetTLVs(tlvs []TLV) error {
 // the remaining header, assume the reader buffer to be in a corrupt state.
 // Also, this operation will block until enough bytes are available for peeking.
 func Read(reader *bufio.Reader) (*Header, error) {
+	return parseOptions{}.read(reader)
+}
+
+// parseOptions restricts what Read accepts.
+type parseOptions struct {
+	disableV1 bool
+	disableV2 bool
+	// versions are the versions of WithAllowedVersions
+	versions Versions
+	// commands are the commands of WithAllowedCommands
+	commands []ProtocolVersionAndCommand
+	// families are the families of WithAllowedFamilies
+	families []AddressFamilyAndProtocol
+	// zone is set on the link-local IPv6 addresses of the header
+	zone string
+	// hardened applies the checks of HardenedMode
+	hardened bool
+	// strictTLVs applies the checks of StrictTLVs
+	strictTLVs bool
+	// maxTLVBytes and maxTLVCount are the limits of WithTLVLimits
+	maxTLVBytes int
+	maxTLVCount int
+}
+
+func (opts parseOptions) read(reader *bufio.Reader) (*Header, error) {
+	header, err := opts.parse(reader)
+	if err == nil && len(opts.commands) > 0 {
+		if err := checkCommand(header, opts.commands); err != nil {
+			return nil, err
+		}
+	}
+	if err == nil && len(opts.families) > 0 {
+		if err := checkFamily(header, opts.families); err != nil {
+			return nil, err
+		}
+	}
+	if err == nil && opts.maxTLVCount > 0 && countTLVs(header.rawTLVs, opts.maxTLVCount) > opts.maxTLVCount {
+		return nil, ErrTooManyTLVs
+	}
+	if err == nil && opts.strictTLVs && header.Version == 2 {
+		if err := CheckStrictTLVs(header); err != nil {
+			return nil, err
+		}
+	}
+	if err == nil && opts.zone != "" {
+		header.SetIPv6Zone(opts.zone)
+	}
+	return header, err
+}
+
+func (opts parseOptions) parse(reader *bufio.Reader) (*Header, error) {
 	// In order to improve speed for small non-PROXYed packets, take a peek at the first byte alone.
 	firstByte, err := reader.Peek(1)
 	if err != nil {
@@ -252,7 +408,7 @@ func Read(reader *bufio.Reader) (*Header, error) {
 	}
 
 	// If it could be a proxy protocol header, peek at more bytes
-	if firstByteVal == SIGV1[0] {
+	if firstByteVal == SIGV1[0] && !opts.disableV1 {
 		signature, err := reader.Peek(5)
 		if err != nil {
 			if err == io.EOF {
@@ -261,13 +417,21 @@ func Read(reader *bufio.Reader) (*Header, error) {
 			return nil, err
 		}
 
-		// Compare fixed length arrays directly for better performance
-		if bytes.Equal(signature[:5], SIGV1) {
+		// Compare the signature as words, see SignatureVersion
+		if SignatureVersion(signature) == 1 {
+			if !opts.versions.allows(1) {
+				return nil, ErrVersionNotAllowed
+			}
+			if opts.hardened {
+				if err := checkStrictVersion1(reader); err != nil {
+					return nil, err
+				}
+			}
 			return parseVersion1(reader)
 		}
 	}
 
-	if firstByteVal == SIGV2[0] {
+	if firstByteVal == SIGV2[0] && !opts.disableV2 {
 		signature, err := reader.Peek(12)
 		if err != nil {
 			if err == io.EOF {
@@ -276,7 +440,20 @@ func Read(reader *bufio.Reader) (*Header, error) {
 			return nil, err
 		}
 
-		if bytes.Equal(signature[:12], SIGV2) {
+		if SignatureVersion(signature) == 2 {
+			if !opts.versions.allows(2) {
+				return nil, ErrVersionNotAllowed
+			}
+			if opts.hardened {
+				if err := checkHardenedVersion2(reader); err != nil {
+					return nil, err
+				}
+			}
+			if opts.maxTLVBytes > 0 {
+				if err := checkTLVBytes(reader, opts.maxTLVBytes); err != nil {
+					return nil, err
+				}
+			}
 			return parseVersion2(reader)
 		}
 	}
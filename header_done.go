@@ -0,0 +1,39 @@
+package proxyproto
+
+// HeaderDone returns a channel which is closed once reading the proxy header
+// is over, successfully or not, or once the connection is closed. Unlike
+// ProxyHeader, it never triggers the read, so that goroutines logging or
+// enriching connections can wait for the header without blocking on the
+// connection on behalf of its user:
+//
+//	go func() {
+//		<-conn.HeaderDone()
+//		log.Print(conn.ProxyHeader())
+//	}()
+func (p *Conn) HeaderDone() <-chan struct{} {
+	p.headerDoneMu.Lock()
+	defer p.headerDoneMu.Unlock()
+
+	if p.headerDone == nil {
+		p.headerDone = make(chan struct{})
+		if p.headerFinished {
+			close(p.headerDone)
+		}
+	}
+	return p.headerDone
+}
+
+// finishHeader marks reading the header as over, waking up the goroutines
+// waiting on HeaderDone.
+func (p *Conn) finishHeader() {
+	p.headerDoneMu.Lock()
+	defer p.headerDoneMu.Unlock()
+
+	if p.headerFinished {
+		return
+	}
+	p.headerFinished = true
+	if p.headerDone != nil {
+		close(p.headerDone)
+	}
+}
@@ -0,0 +1,51 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnHeaderDone(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConn(server)
+	defer conn.Close()
+
+	done := conn.HeaderDone()
+	select {
+	case <-done:
+		t.Fatal("bad: done before the header was read")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+	go conn.Read(make([]byte, 1))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: not done after the header was read")
+	}
+	if conn.ProxyHeader() == nil {
+		t.Fatal("bad: no header")
+	}
+	// Late callers get a closed channel
+	<-conn.HeaderDone()
+}
+
+func TestConnHeaderDoneOnClose(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConn(server)
+	done := conn.HeaderDone()
+	conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: not done after close")
+	}
+}
@@ -0,0 +1,76 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+)
+
+// HeaderLite is a compact copy of a Header holding its addresses by value,
+// for servers keeping large numbers of headers around, e.g. to track
+// connections: it holds no pointer besides the zones of the addresses, so
+// it costs no allocation and no garbage collector scanning of its own.
+//
+// It doesn't hold TLVs, and can't represent Unix socket addresses.
+type HeaderLite struct {
+	Version           byte
+	Command           ProtocolVersionAndCommand
+	TransportProtocol AddressFamilyAndProtocol
+	// Source and Destination are the zero AddrPort when the header has no
+	// addresses, e.g. with the LOCAL command.
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+}
+
+// Lite returns a HeaderLite copy of the header, dropping its TLVs. It fails
+// with ErrInvalidAddress if the header carries addresses which aren't IP
+// ones matching its transport protocol, such as Unix socket addresses.
+func (header *Header) Lite() (HeaderLite, error) {
+	lite := HeaderLite{
+		Version:           header.Version,
+		Command:           header.Command,
+		TransportProtocol: header.TransportProtocol,
+	}
+	if header.SourceAddr == nil && header.DestinationAddr == nil {
+		return lite, nil
+	}
+
+	var ok bool
+	if sourceAddr, destAddr, isTCP := header.TCPAddrs(); isTCP {
+		lite.Source, lite.Destination = sourceAddr.AddrPort(), destAddr.AddrPort()
+		ok = true
+	} else if sourceAddr, destAddr, isUDP := header.UDPAddrs(); isUDP {
+		lite.Source, lite.Destination = sourceAddr.AddrPort(), destAddr.AddrPort()
+		ok = true
+	}
+	if !ok {
+		return HeaderLite{}, ErrInvalidAddress
+	}
+	if header.TransportProtocol.IsIPv4() {
+		lite.Source = netip.AddrPortFrom(lite.Source.Addr().Unmap(), lite.Source.Port())
+		lite.Destination = netip.AddrPortFrom(lite.Destination.Addr().Unmap(), lite.Destination.Port())
+	}
+	return lite, nil
+}
+
+// Header returns a Header holding the same fields as h, with *net.TCPAddr or
+// *net.UDPAddr addresses depending on its transport protocol, and none if
+// it has no addresses or isn't an IP one.
+func (h HeaderLite) Header() *Header {
+	header := &Header{
+		Version:           h.Version,
+		Command:           h.Command,
+		TransportProtocol: h.TransportProtocol,
+	}
+	if !h.Source.IsValid() || !h.Destination.IsValid() {
+		return header
+	}
+	switch {
+	case h.TransportProtocol.IsStream():
+		header.SourceAddr = net.TCPAddrFromAddrPort(h.Source)
+		header.DestinationAddr = net.TCPAddrFromAddrPort(h.Destination)
+	case h.TransportProtocol.IsDatagram():
+		header.SourceAddr = net.UDPAddrFromAddrPort(h.Source)
+		header.DestinationAddr = net.UDPAddrFromAddrPort(h.Destination)
+	}
+	return header
+}
@@ -0,0 +1,61 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestHeaderLite(t *testing.T) {
+	for _, header := range []*Header{
+		{
+			Version:           2,
+			Command:           PROXY,
+			TransportProtocol: TCPv4,
+			SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+			DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		},
+		{
+			Version:           1,
+			Command:           PROXY,
+			TransportProtocol: UDPv6,
+			SourceAddr:        &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1000, Zone: "eth0"},
+			DestinationAddr:   &net.UDPAddr{IP: net.ParseIP("::2"), Port: 2000},
+		},
+		{
+			Version:           2,
+			Command:           LOCAL,
+			TransportProtocol: UNSPEC,
+		},
+	} {
+		lite, err := header.Lite()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !lite.Header().EqualsTo(header) {
+			t.Fatalf("bad: %+v doesn't round-trip", header)
+		}
+	}
+
+	lite, _ := (&Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}).Lite()
+	if lite.Source != netip.MustParseAddrPort("10.1.1.1:1000") {
+		t.Fatalf("bad: %v", lite.Source)
+	}
+
+	unix := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: UnixStream,
+		SourceAddr:        &net.UnixAddr{Net: "unix", Name: "src"},
+		DestinationAddr:   &net.UnixAddr{Net: "unix", Name: "dst"},
+	}
+	if _, err := unix.Lite(); err != ErrInvalidAddress {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package proxyproto
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// LogValue implements slog.LogValuer, so that logging a header emits its
+// fields rather than a dump of the struct:
+//
+//	slog.Info("conn", "proxy", header)
+func (header *Header) LogValue() slog.Value {
+	if header == nil {
+		return slog.GroupValue()
+	}
+
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs,
+		slog.Int("version", int(header.Version)),
+		slog.String("command", commandName(header.Command)),
+		slog.String("family", transportProtocolName(header.TransportProtocol)),
+	)
+	if header.SourceAddr != nil {
+		attrs = append(attrs, slog.String("src", header.SourceAddr.String()))
+	}
+	if header.DestinationAddr != nil {
+		attrs = append(attrs, slog.String("dst", header.DestinationAddr.String()))
+	}
+	attrs = append(attrs, slog.Int("tlvs", countTLVs(header.rawTLVs, math.MaxInt)))
+
+	return slog.GroupValue(attrs...)
+}
+
+// commandName returns the name of the command as used in the spec
+func commandName(pvc ProtocolVersionAndCommand) string {
+	switch pvc {
+	case LOCAL:
+		return "LOCAL"
+	case PROXY:
+		return "PROXY"
+	}
+	return fmt.Sprintf("0x%02x", byte(pvc))
+}
+
+// transportProtocolName returns the name of the address family and
+// transport protocol constant
+func transportProtocolName(ap AddressFamilyAndProtocol) string {
+	switch ap {
+	case UNSPEC:
+		return "UNSPEC"
+	case TCPv4:
+		return "TCPv4"
+	case UDPv4:
+		return "UDPv4"
+	case TCPv6:
+		return "TCPv6"
+	case UDPv6:
+		return "UDPv6"
+	case UnixStream:
+		return "UnixStream"
+	case UnixDatagram:
+		return "UnixDatagram"
+	}
+	return fmt.Sprintf("0x%02x", byte(ap))
+}
@@ -0,0 +1,37 @@
+package proxyproto
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestHeaderLogValue(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}, {Type: PP2_TYPE_NOOP}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("conn", "proxy", header)
+
+	expected := "proxy.version=2 proxy.command=PROXY proxy.family=TCPv4 proxy.src=10.1.1.1:1000 proxy.dst=20.2.2.2:2000 proxy.tlvs=2"
+	if !strings.Contains(buf.String(), expected) {
+		t.Fatalf("bad: %s", buf.String())
+	}
+}
+
+func TestHeaderLogValueNil(t *testing.T) {
+	var header *Header
+	if v := header.LogValue(); v.Kind() != slog.KindGroup || len(v.Group()) != 0 {
+		t.Fatalf("bad: %v", v)
+	}
+}
@@ -0,0 +1,23 @@
+package proxyproto
+
+// HeaderPolicyFunc decides whether to accept a connection once its header
+// has been read, e.g. based on the claimed source address, the TLVs or the
+// address family. Unlike a policy, it runs after parsing, and unlike a
+// Validator, it also receives the addresses of the connection and the
+// connection itself in opts, e.g. to only allow some upstreams to claim some
+// sources.
+//
+// It is called for the headers used by the connection, after the
+// validators. In case an error is returned, the connection is refused: the
+// first read returns the error, and ErrCodePolicyReject is its code.
+type HeaderPolicyFunc func(opts ConnPolicyOptions, header *Header) error
+
+// WithHeaderPolicy adds given header policy to a connection when passed as
+// option to NewConn().
+func WithHeaderPolicy(f HeaderPolicyFunc) func(*Conn) {
+	return func(c *Conn) {
+		if f != nil {
+			c.headerPolicy = f
+		}
+	}
+}
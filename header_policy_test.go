@@ -0,0 +1,82 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestHeaderPolicy(t *testing.T) {
+	errNotAllowed := errors.New("not allowed")
+	var seen ConnPolicyOptions
+	policy := func(opts ConnPolicyOptions, header *Header) error {
+		seen = opts
+		if !header.TransportProtocol.IsIPv4() {
+			return errNotAllowed
+		}
+		return nil
+	}
+
+	for _, tc := range []struct {
+		header string
+		err    error
+	}{
+		{"PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", nil},
+		{"PROXY TCP6 ::1 ::2 1000 2000\r\n", errNotAllowed},
+	} {
+		server, client := net.Pipe()
+		go func() {
+			client.Write([]byte(tc.header + "ping"))
+		}()
+		conn := NewConn(server, WithHeaderPolicy(policy))
+
+		_, err := conn.Read(make([]byte, 4))
+		if err != tc.err {
+			t.Fatalf("bad: %v for %q", err, tc.header)
+		}
+		if tc.err != nil && conn.ErrorCode() != ErrCodePolicyReject {
+			t.Fatalf("bad: %v", conn.ErrorCode())
+		}
+		if seen.Conn != server || seen.Upstream != server.RemoteAddr() || seen.Downstream != server.LocalAddr() {
+			t.Fatalf("bad: %+v", seen)
+		}
+		conn.Close()
+		client.Close()
+	}
+}
+
+func TestListenerHeaderPolicy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// Refuse headers claiming to come from the listener itself
+	errSpoofed := errors.New("spoofed")
+	pl := &Listener{Listener: l, HeaderPolicy: func(opts ConnPolicyOptions, header *Header) error {
+		if header.SourceAddr.String() == opts.Downstream.String() {
+			return errSpoofed
+		}
+		return nil
+	}}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 127.0.0.1 127.0.0.1 " + strconv.Itoa(pl.Addr().(*net.TCPAddr).Port) + " 2000\r\n"))
+		conn.Read(make([]byte, 1))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err != errSpoofed {
+		t.Fatalf("bad: %v", err)
+	}
+}
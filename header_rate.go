@@ -0,0 +1,96 @@
+package proxyproto
+
+import (
+	"net"
+	"time"
+)
+
+// ErrHeaderTooSlow is returned when the PROXY header doesn't arrive at the
+// minimum rate set by WithMinHeaderRate.
+var ErrHeaderTooSlow = newError(ErrCodeTooSlow, "proxyproto: PROXY header not received at the minimum rate")
+
+// DefaultMinHeaderRateGrace is the time allowed for the first bytes of the
+// header to arrive when enforcing a minimum rate without an explicit grace.
+var DefaultMinHeaderRateGrace = time.Second
+
+// WithMinHeaderRate requires the bytes read while processing the header to
+// arrive at bytesPerSecond or faster, after an initial grace period, when
+// passed as option to NewConn(). Unlike the read header timeout, which only
+// bounds the total time, this stops peers from holding a connection open by
+// dribbling bytes in. A slow connection fails with ErrHeaderTooSlow.
+//
+// As a connection sending nothing fails once the grace period is over, only
+// use it for protocols where the client speaks first.
+func WithMinHeaderRate(bytesPerSecond int, grace time.Duration) func(*Conn) {
+	return func(c *Conn) {
+		if bytesPerSecond <= 0 {
+			return
+		}
+		if grace <= 0 {
+			grace = DefaultMinHeaderRateGrace
+		}
+		c.headerRate = &headerRateReader{
+			conn:  c.conn,
+			rate:  bytesPerSecond,
+			grace: grace,
+		}
+		// Nothing has been buffered yet, so the reader can be redirected
+		c.bufReader.Reset(c.headerRate)
+	}
+}
+
+// headerRateReader sits between the connection and its buffered reader,
+// moving the read deadline forward as header bytes arrive.
+type headerRateReader struct {
+	conn  net.Conn
+	rate  int
+	grace time.Duration
+
+	deadline *clockDeadline
+	active   bool
+	start    time.Time
+	limit    time.Time // read header timeout deadline, if any
+	received int
+	slow     bool
+}
+
+// begin starts enforcing the rate, arming deadline as bytes arrive. limit is
+// the deadline of the whole header read, zero for none.
+func (r *headerRateReader) begin(deadline *clockDeadline, limit time.Time) {
+	r.deadline = deadline
+	r.active = true
+	r.start = deadline.clock.Now()
+	r.limit = limit
+	r.received = 0
+	r.slow = false
+}
+
+// end stops enforcing the rate and reports whether the peer was too slow.
+func (r *headerRateReader) end() bool {
+	r.active = false
+	return r.slow
+}
+
+func (r *headerRateReader) Read(b []byte) (int, error) {
+	if !r.active {
+		return r.conn.Read(b)
+	}
+
+	// Every byte received buys the peer 1/rate seconds more
+	deadline := r.start.Add(r.grace + time.Duration(r.received)*time.Second/time.Duration(r.rate))
+	rateBound := true
+	if !r.limit.IsZero() && r.limit.Before(deadline) {
+		deadline = r.limit
+		rateBound = false
+	}
+	if err := r.deadline.arm(deadline); err != nil {
+		return 0, err
+	}
+
+	n, err := r.conn.Read(b)
+	r.received += n
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() && rateBound {
+		r.slow = true
+	}
+	return n, err
+}
@@ -0,0 +1,92 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMinHeaderRateRejectsSlowHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{
+		Listener:           l,
+		ReadHeaderTimeout:  5 * time.Second,
+		MinHeaderRate:      100,
+		MinHeaderRateGrace: 50 * time.Millisecond,
+	}
+	defer pl.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Dribble the header in well below the required rate
+		for _, b := range []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n") {
+			if _, err := conn.Write([]byte{b}); err != nil {
+				return
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err != ErrHeaderTooSlow {
+		t.Fatalf("bad: expected %v, got %v", ErrHeaderTooSlow, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("bad: slow header detected after %v", elapsed)
+	}
+}
+
+func TestMinHeaderRateAcceptsFastHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, MinHeaderRate: 100}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping"))
+		time.Sleep(100 * time.Millisecond)
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	recv := make([]byte, 4)
+	if _, err := conn.Read(recv); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(recv) != "ping" {
+		t.Fatalf("bad: %v", recv)
+	}
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+}
@@ -789,3 +789,122 @@ func TestHeaderProxyFromAddrs(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteTLVs(t *testing.T) {
+	tlvs := []TLV{
+		{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")},
+		{Type: PP2_TYPE_UNIQUE_ID, Value: []byte("req-42")},
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteTLVs(&buf, tlvs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	buf.WriteString("payload")
+
+	reader := bufio.NewReader(&buf)
+	header, err := Read(reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if header.Version != 2 || header.Command != LOCAL || header.TransportProtocol != UNSPEC {
+		t.Fatalf("bad: %+v", header)
+	}
+	if header.SourceAddr != nil || header.DestinationAddr != nil {
+		t.Fatalf("bad: unexpected addresses %v, %v", header.SourceAddr, header.DestinationAddr)
+	}
+	got, err := header.TLVs()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(got, tlvs) {
+		t.Fatalf("bad: %v", got)
+	}
+	if rest, _ := reader.ReadString(0); rest != "payload" {
+		t.Fatalf("bad: %q", rest)
+	}
+}
+
+func TestRawAddressBlock(t *testing.T) {
+	// An opaque block which isn't a valid TLV vector
+	block := []byte{0xde, 0xad, 0xbe, 0xef, 0x42}
+	raw := append(append([]byte{}, SIGV2...), byte(LOCAL), byte(UNSPEC), 0, byte(len(block)))
+	raw = append(raw, block...)
+
+	header, err := Read(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(header.RawAddressBlock(), block) {
+		t.Fatalf("bad: %x", header.RawAddressBlock())
+	}
+
+	// Relaying the header forwards the block untouched
+	formatted, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(formatted, raw) {
+		t.Fatalf("bad: %x", formatted)
+	}
+
+	tcp := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if tcp.RawAddressBlock() != nil {
+		t.Fatalf("bad: %x", tcp.RawAddressBlock())
+	}
+}
+
+func TestAddTLV(t *testing.T) {
+	raw := []byte("payload")
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	formatted, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The raw TLVs of a parsed header are a slice of the stream, which
+	// adding a TLV must not overwrite
+	stream := append(formatted, raw...)
+	parsed, offset, err := ExtractHeader(stream)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tlvs, _ := parsed.TLVs(); len(tlvs) != 1 {
+		t.Fatalf("bad: %+v", tlvs)
+	}
+	if err := parsed.AddTLV(TLV{Type: PP2_TYPE_UNIQUE_ID, Value: []byte("req-42")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(stream[offset:], raw) {
+		t.Fatalf("bad: stream modified %q", stream[offset:])
+	}
+
+	tlvs, err := parsed.TLVs()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(tlvs) != 2 || tlvs[0].Type != PP2_TYPE_AUTHORITY || string(tlvs[1].Value) != "req-42" {
+		t.Fatalf("bad: %+v", tlvs)
+	}
+	// The cached TLVs aren't affected by changes to the returned slice
+	tlvs[0] = TLV{Type: PP2_TYPE_NOOP}
+	if again, _ := parsed.TLVs(); again[0].Type != PP2_TYPE_AUTHORITY {
+		t.Fatalf("bad: %+v", again)
+	}
+
+	if err := parsed.AddTLV(TLV{Type: PP2_TYPE_NOOP, Value: make([]byte, 1<<16)}); err == nil {
+		t.Fatalf("bad: value too long accepted")
+	}
+}
@@ -0,0 +1,93 @@
+package proxyproto
+
+import (
+	"net"
+	"time"
+)
+
+// HeaderTracer traces the handling of the headers of connections, e.g. as
+// spans of a distributed tracing system, without this package depending on
+// one. An OpenTelemetry tracer may be adapted this way:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) StartHeader(conn *proxyproto.Conn) func(proxyproto.HeaderTrace) {
+//		_, span := t.tracer.Start(context.Background(), "proxyproto.header")
+//		return func(ht proxyproto.HeaderTrace) {
+//			span.SetAttributes(attribute.Int("proxyproto.version", int(ht.Version)))
+//			if ht.Err != nil {
+//				span.RecordError(ht.Err)
+//			}
+//			span.End()
+//		}
+//	}
+type HeaderTracer interface {
+	// StartHeader is called when the header of conn starts being handled.
+	// It returns the function called with the outcome once it has been,
+	// which may be nil.
+	StartHeader(conn *Conn) func(HeaderTrace)
+}
+
+// HeaderTraceFunc adapts a function to a HeaderTracer.
+type HeaderTraceFunc func(conn *Conn) func(HeaderTrace)
+
+// StartHeader calls f(conn).
+func (f HeaderTraceFunc) StartHeader(conn *Conn) func(HeaderTrace) {
+	return f(conn)
+}
+
+// HeaderTrace describes how handling the header of a connection went, for a
+// HeaderTracer.
+type HeaderTrace struct {
+	// Upstream is the address of the peer of the connection, the proxy
+	// sending the header.
+	Upstream net.Addr
+	// Version is the version of the header received, zero if none was.
+	Version byte
+	// Command, TransportProtocol, SourceAddr and DestinationAddr are the
+	// ones of the header used by the connection, if any.
+	Command           ProtocolVersionAndCommand
+	TransportProtocol AddressFamilyAndProtocol
+	SourceAddr        net.Addr
+	DestinationAddr   net.Addr
+	// TLVTypes are the types of the TLVs of the header, in order.
+	TLVTypes []PP2Type
+	// Duration is the time spent handling the header, see ConnStats.
+	Duration time.Duration
+	// Err and ErrorCode are the error of the header, if any.
+	Err       error
+	ErrorCode ErrorCode
+}
+
+// WithHeaderTracer sets the tracer of the header of a connection when
+// passed as option to NewConn().
+func WithHeaderTracer(t HeaderTracer) func(*Conn) {
+	return func(c *Conn) {
+		if t != nil {
+			c.tracer = t
+		}
+	}
+}
+
+// headerTrace returns the trace of the header of p, once handled.
+func (p *Conn) headerTrace() HeaderTrace {
+	trace := HeaderTrace{
+		Upstream:  p.conn.RemoteAddr(),
+		Version:   p.headerVersion,
+		Duration:  p.headerReadDuration,
+		Err:       p.readErr,
+		ErrorCode: p.readErrCode,
+	}
+	if h := p.header; h != nil {
+		trace.Command = h.Command
+		trace.TransportProtocol = h.TransportProtocol
+		trace.SourceAddr = h.SourceAddr
+		trace.DestinationAddr = h.DestinationAddr
+		if tlvs, err := h.TLVs(); err == nil {
+			for _, tlv := range tlvs {
+				trace.TLVTypes = append(trace.TLVTypes, tlv.Type)
+			}
+		}
+	}
+	return trace
+}
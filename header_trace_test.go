@@ -0,0 +1,69 @@
+package proxyproto
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestHeaderTracer(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetAuthority("example.org"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write(append(raw, "ping"...))
+
+	var started *Conn
+	traces := make(chan HeaderTrace, 1)
+	conn := NewConn(server, WithHeaderTracer(HeaderTraceFunc(func(conn *Conn) func(HeaderTrace) {
+		started = conn
+		return func(trace HeaderTrace) {
+			traces <- trace
+		}
+	})))
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 4)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	trace := <-traces
+	if started != conn || trace.Err != nil || trace.Version != 2 || trace.Upstream != server.RemoteAddr() {
+		t.Fatalf("bad: %+v", trace)
+	}
+	if trace.SourceAddr.String() != "10.1.1.1:1000" || trace.TransportProtocol != TCPv4 || trace.Command != PROXY {
+		t.Fatalf("bad: %+v", trace)
+	}
+	if !slices.Equal(trace.TLVTypes, []PP2Type{PP2_TYPE_AUTHORITY}) {
+		t.Fatalf("bad: %v", trace.TLVTypes)
+	}
+}
+
+func TestHeaderTracerError(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1\r\n"))
+
+	traces := make(chan HeaderTrace, 1)
+	conn := NewConn(server, WithHeaderTracer(HeaderTraceFunc(func(conn *Conn) func(HeaderTrace) {
+		return func(trace HeaderTrace) {
+			traces <- trace
+		}
+	})))
+	defer conn.Close()
+
+	_, err := conn.Read(make([]byte, 1))
+	trace := <-traces
+	if err == nil || trace.Err != err || trace.ErrorCode != conn.ErrorCode() || trace.SourceAddr != nil {
+		t.Fatalf("bad: %+v", trace)
+	}
+}
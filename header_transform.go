@@ -0,0 +1,32 @@
+package proxyproto
+
+// HeaderTransform rewrites the header of a connection once it has been
+// read, e.g. to translate the addresses of a NAT, scrub TLVs or normalize
+// IPv4-mapped IPv6 addresses to IPv4. It returns the header to use instead,
+// which may be header itself once changed, and must not be nil. In case an
+// error is returned, the connection is refused: the first read returns the
+// error.
+type HeaderTransform func(header *Header) (*Header, error)
+
+// WithHeaderTransforms appends the given transforms to the chain applied to
+// the header of a connection when passed as option to NewConn(). They run
+// in order on the headers used by the connection, before the validators and
+// policies, so that those, Enrichment and the addresses of the connection
+// all see the transformed header. The restrictions on the parsed header,
+// like WithAllowedFamilies, apply before them.
+func WithHeaderTransforms(transforms ...HeaderTransform) func(*Conn) {
+	return func(c *Conn) {
+		c.headerTransforms = append(c.headerTransforms, transforms...)
+	}
+}
+
+// transformHeader passes header through the transforms of the connection.
+func (p *Conn) transformHeader(header *Header) (*Header, error) {
+	for _, transform := range p.headerTransforms {
+		var err error
+		if header, err = transform(header); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
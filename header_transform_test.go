@@ -0,0 +1,112 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestHeaderTransforms(t *testing.T) {
+	// Translate the addresses of a NAT, then scrub the TLVs
+	nat := func(header *Header) (*Header, error) {
+		if src, ok := header.SourceAddr.(*net.TCPAddr); ok && src.IP.Equal(net.ParseIP("10.1.1.1")) {
+			header.SourceAddr = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: src.Port}
+		}
+		return header, nil
+	}
+	scrub := func(header *Header) (*Header, error) {
+		return header, header.SetTLVs(nil)
+	}
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetAuthority("example.org"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write(append(raw, "ping"...))
+
+	var validated net.Addr
+	conn := NewConn(server,
+		WithHeaderTransforms(nat),
+		WithHeaderTransforms(scrub),
+		ValidateHeader(func(header *Header) error {
+			validated = header.SourceAddr
+			return nil
+		}),
+	)
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 4)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conn.RemoteAddr().String() != "192.0.2.1:1000" || validated.String() != "192.0.2.1:1000" {
+		t.Fatalf("bad: %v, %v", conn.RemoteAddr(), validated)
+	}
+	if tlvs, _ := conn.ProxyHeader().TLVs(); len(tlvs) != 0 {
+		t.Fatalf("bad: %+v", tlvs)
+	}
+}
+
+func TestHeaderTransformError(t *testing.T) {
+	errUnmapped := errors.New("unmapped address")
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping"))
+
+	called := false
+	conn := NewConn(server,
+		WithHeaderTransforms(
+			func(*Header) (*Header, error) { return nil, errUnmapped },
+			func(header *Header) (*Header, error) {
+				called = true
+				return header, nil
+			},
+		),
+	)
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 4)); err != errUnmapped {
+		t.Fatalf("bad: %v", err)
+	}
+	if called || conn.ProxyHeader() != nil {
+		t.Fatalf("bad: chain went on after an error")
+	}
+}
+
+func TestListenerHeaderTransforms(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{
+		Listener: l,
+		HeaderTransforms: []HeaderTransform{func(header *Header) (*Header, error) {
+			header.SourceAddr.(*net.TCPAddr).Port = 4000
+			return header, nil
+		}},
+	}
+	defer pl.Close()
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != "10.1.1.1:4000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+}
@@ -0,0 +1,170 @@
+// Package debugvars exposes the runtime state of a proxyproto.Listener as
+// JSON, through expvar or an http.Handler, for basic introspection without
+// setting up a metrics pipeline.
+//
+//	pub := debugvars.New(listener)
+//	pub.Publish("proxyproto")
+//	// or one variable per counter
+//	pub.PublishCounters("proxyproto")
+//	// or
+//	http.Handle("/debug/proxyproto", pub)
+package debugvars
+
+import (
+	"encoding/json"
+	"expvar"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iqhive/go-proxyproto"
+)
+
+// RecentErrors is the number of header errors a Publisher remembers.
+const RecentErrors = 32
+
+// Publisher collects the header outcomes of a listener and reports them
+// along with its counters and the process wide ones of the package.
+type Publisher struct {
+	listener *proxyproto.Listener
+
+	mu       sync.Mutex
+	headers  uint64
+	failures map[string]uint64
+	recent   []ErrorEvent // ring buffer, next is the oldest once full
+	next     int
+}
+
+// Snapshot is the state reported by a Publisher.
+type Snapshot struct {
+	Listener     ListenerStats            `json:"listener"`
+	Pool         proxyproto.PoolStats     `json:"pool"`
+	ZeroCopy     proxyproto.ZeroCopyStats `json:"zero_copy"`
+	RecentErrors []ErrorEvent             `json:"recent_errors"`
+}
+
+// ListenerStats holds the counters of the listener.
+type ListenerStats struct {
+	// Headers is the number of connections whose header was handled, and
+	// Failures the number of them which failed, by error code.
+	Headers  uint64            `json:"headers"`
+	Failures map[string]uint64 `json:"failures"`
+	// Pending, Queued and Shed are the ones of Listener.Serve.
+	Pending int    `json:"pending"`
+	Queued  int    `json:"queued"`
+	Shed    uint64 `json:"shed"`
+	// Counters are the ones of Listener.Stats.
+	Counters proxyproto.ListenerStats `json:"counters"`
+}
+
+// ErrorEvent describes a header error.
+type ErrorEvent struct {
+	Time     time.Time `json:"time"`
+	Upstream string    `json:"upstream"`
+	Code     string    `json:"code"`
+}
+
+// New returns a Publisher for l. It sets the HeaderReadHook of l, calling
+// the one already set if any, so it must be called before l accepts
+// connections.
+func New(l *proxyproto.Listener) *Publisher {
+	p := &Publisher{listener: l, failures: make(map[string]uint64)}
+	next := l.HeaderReadHook
+	l.HeaderReadHook = func(conn *proxyproto.Conn, stats proxyproto.ConnStats) {
+		p.record(conn, stats)
+		if next != nil {
+			next(conn, stats)
+		}
+	}
+	return p
+}
+
+func (p *Publisher) record(conn *proxyproto.Conn, stats proxyproto.ConnStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.headers++
+	if stats.ErrorCode == proxyproto.ErrCodeNone {
+		return
+	}
+	code := stats.ErrorCode.String()
+	p.failures[code]++
+
+	event := ErrorEvent{Time: time.Now(), Upstream: conn.Raw().RemoteAddr().String(), Code: code}
+	if len(p.recent) < RecentErrors {
+		p.recent = append(p.recent, event)
+		return
+	}
+	p.recent[p.next] = event
+	p.next = (p.next + 1) % RecentErrors
+}
+
+// Snapshot returns the current state, the most recent errors last.
+func (p *Publisher) Snapshot() Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failures := maps.Clone(p.failures)
+	recent := make([]ErrorEvent, 0, len(p.recent))
+	recent = append(recent, p.recent[p.next:]...)
+	recent = append(recent, p.recent[:p.next]...)
+
+	return Snapshot{
+		Listener: ListenerStats{
+			Headers:  p.headers,
+			Failures: failures,
+			Pending:  p.listener.Pending(),
+			Queued:   p.listener.Queued(),
+			Shed:     p.listener.Shed(),
+			Counters: p.listener.Stats(),
+		},
+		Pool:         proxyproto.GetPoolStats(),
+		ZeroCopy:     proxyproto.GetZeroCopyStats(),
+		RecentErrors: recent,
+	}
+}
+
+// Publish publishes the snapshots as an expvar variable, served by the
+// /debug/vars handler of expvar. Like expvar.Publish, it panics if name is
+// already in use.
+func (p *Publisher) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return p.Snapshot()
+	}))
+}
+
+// PublishCounters publishes the counters of the listener as separate expvar
+// variables named after prefix, e.g. "proxyproto.accepted" or
+// "proxyproto.parse_errors", for tools which only read flat numbers from
+// /debug/vars. The failures by error code are published as a single
+// variable, "proxyproto.failures". Like expvar.Publish, it panics if a name
+// is already in use.
+func (p *Publisher) PublishCounters(prefix string) {
+	stats := p.listener.Stats
+	for name, counter := range map[string]func() any{
+		"accepted":             func() any { return stats().Accepted },
+		"proxy_v1":             func() any { return stats().ProxyV1 },
+		"proxy_v2":             func() any { return stats().ProxyV2 },
+		"no_proxy":             func() any { return stats().NoProxy },
+		"policy_rejected":      func() any { return stats().PolicyRejected },
+		"parse_errors":         func() any { return stats().ParseErrors },
+		"validation_errors":    func() any { return stats().ValidationErrors },
+		"header_read_timeouts": func() any { return stats().HeaderReadTimeouts },
+		"shed":                 func() any { return p.listener.Shed() },
+		"conns":                func() any { return p.listener.Conns() },
+		"failures": func() any {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return maps.Clone(p.failures)
+		},
+	} {
+		expvar.Publish(prefix+"."+name, expvar.Func(counter))
+	}
+}
+
+// ServeHTTP writes the current snapshot as JSON.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.Snapshot())
+}
@@ -0,0 +1,94 @@
+package debugvars_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/helper/debugvars"
+)
+
+func TestPublisher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &proxyproto.Listener{
+		Listener: l,
+		Policy:   func(net.Addr) (proxyproto.Policy, error) { return proxyproto.REQUIRE, nil },
+	}
+	defer pl.Close()
+	pub := debugvars.New(pl)
+
+	for _, payload := range []string{"PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", "GET / HTTP/1.1\r\n"} {
+		client, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		client.Write([]byte(payload))
+		client.Close()
+
+		conn, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		io.ReadAll(conn)
+		conn.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	pub.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var snapshot debugvars.Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if snapshot.Listener.Headers != 2 || snapshot.Listener.Failures["bad_signature"] != 1 {
+		t.Fatalf("bad: %+v", snapshot.Listener)
+	}
+	if len(snapshot.RecentErrors) != 1 || snapshot.RecentErrors[0].Code != "bad_signature" {
+		t.Fatalf("bad: %+v", snapshot.RecentErrors)
+	}
+}
+
+func TestPublishCounters(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &proxyproto.Listener{Listener: l}
+	defer pl.Close()
+
+	pub := debugvars.New(pl)
+	pub.PublishCounters("test_counters")
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	client.Write([]byte("PROXY TCP4 10.1.1.1\r\n"))
+	client.Close()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	io.ReadAll(conn)
+	conn.Close()
+
+	for name, want := range map[string]string{
+		"test_counters.accepted":     "1",
+		"test_counters.parse_errors": "1",
+		"test_counters.proxy_v1":     "0",
+		"test_counters.failures":     `{"bad_family":1}`,
+	} {
+		if got := expvar.Get(name); got == nil || got.String() != want {
+			t.Fatalf("bad: %s = %v", name, got)
+		}
+	}
+	if snapshot := pub.Snapshot(); snapshot.Listener.Counters.ParseErrors != 1 {
+		t.Fatalf("bad: %+v", snapshot.Listener.Counters)
+	}
+}
@@ -0,0 +1,134 @@
+// Package eventloop adapts the PROXY protocol to event-loop networking
+// libraries such as gnet or evio, which hand connection data to a callback
+// in chunks instead of exposing a blocking net.Conn.
+//
+// Keep a Decoder in the context of each connection and pass it every chunk
+// received, e.g. with gnet:
+//
+//	func (s *server) OnOpen(c gnet.Conn) ([]byte, gnet.Action) {
+//		c.SetContext(&eventloop.Decoder{Policy: proxyproto.REQUIRE})
+//		return nil, gnet.None
+//	}
+//
+//	func (s *server) OnTraffic(c gnet.Conn) gnet.Action {
+//		d := c.Context().(*eventloop.Decoder)
+//		in, _ := c.Next(-1)
+//		payload, err := d.Decode(in)
+//		if err != nil {
+//			return gnet.Close
+//		}
+//		client := d.RemoteAddr(c.RemoteAddr())
+//		// handle payload from client, it may be empty
+//		return gnet.None
+//	}
+//
+// The package doesn't depend on any event-loop library.
+package eventloop
+
+import (
+	"net"
+
+	"github.com/iqhive/go-proxyproto"
+)
+
+// Decoder strips and validates the PROXY header from the first chunks of a
+// connection. The zero value parses the header with the USE policy.
+type Decoder struct {
+	// Policy decides how the header is handled, as for proxyproto.Conn.
+	// SKIP passes all data through without looking for a header.
+	Policy proxyproto.Policy
+	// Validate, if set, is called with the header once it is parsed.
+	Validate proxyproto.Validator
+
+	parser proxyproto.Parser
+	header *proxyproto.Header
+	done   bool
+	err    error
+}
+
+// Decode consumes the header bytes of in and returns the payload following
+// them. While the header is incomplete, it returns no payload and no error:
+// the bytes are kept until the next call. Once the header is handled, in is
+// returned as is.
+//
+// An error means the connection must be closed, and is returned again by
+// further calls. The payload usually aliases in, but may also hold bytes of
+// previous chunks when no header was sent.
+func (d *Decoder) Decode(in []byte) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.done || d.Policy == proxyproto.SKIP {
+		return in, nil
+	}
+
+	consumed, done, err := d.parser.Feed(in)
+	if !done {
+		return nil, nil
+	}
+	d.done = true
+
+	if err == proxyproto.ErrNoProxyProtocol {
+		if d.Policy == proxyproto.REQUIRE {
+			return nil, d.fail(err)
+		}
+		// What was taken for the start of a signature is payload
+		buffered := d.parser.Buffered()
+		if len(buffered) == 0 {
+			return in, nil
+		}
+		payload := make([]byte, 0, len(buffered)+len(in))
+		return append(append(payload, buffered...), in...), nil
+	}
+	if err != nil {
+		return nil, d.fail(err)
+	}
+
+	header := d.parser.Header()
+	switch d.Policy {
+	case proxyproto.REJECT:
+		return nil, d.fail(proxyproto.ErrSuperfluousProxyHeader)
+	case proxyproto.USE, proxyproto.REQUIRE:
+		if d.Validate != nil {
+			if err := d.Validate(header); err != nil {
+				return nil, d.fail(err)
+			}
+		}
+		d.header = header
+	}
+	return in[consumed:], nil
+}
+
+func (d *Decoder) fail(err error) error {
+	d.err = err
+	return err
+}
+
+// Done reports whether the header has been handled, successfully or not.
+func (d *Decoder) Done() bool {
+	return d.done || d.err != nil || d.Policy == proxyproto.SKIP
+}
+
+// Header returns the header in use, nil if none was received or the policy
+// ignores it.
+func (d *Decoder) Header() *proxyproto.Header {
+	return d.header
+}
+
+// RemoteAddr returns the client address announced by the header, or
+// fallback, the address of the connection peer, if there is none.
+func (d *Decoder) RemoteAddr(fallback net.Addr) net.Addr {
+	if d.header == nil || d.header.Command.IsLocal() || d.header.SourceAddr == nil {
+		return fallback
+	}
+	return d.header.SourceAddr
+}
+
+// LocalAddr returns the destination address announced by the header, or
+// fallback, the local address of the connection, if there is none.
+func (d *Decoder) LocalAddr(fallback net.Addr) net.Addr {
+	if d.header == nil || d.header.Command.IsLocal() || d.header.DestinationAddr == nil {
+		return fallback
+	}
+	return d.header.DestinationAddr
+}
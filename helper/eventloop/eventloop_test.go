@@ -0,0 +1,85 @@
+package eventloop_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/helper/eventloop"
+)
+
+func TestDecoder(t *testing.T) {
+	peer := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 4000}
+	d := &eventloop.Decoder{Policy: proxyproto.REQUIRE}
+
+	payload, err := d.Decode([]byte("PROXY TCP4 10.1.1.1 "))
+	if err != nil || len(payload) != 0 || d.Done() {
+		t.Fatalf("bad: %q, %v", payload, err)
+	}
+	payload, err = d.Decode([]byte("20.2.2.2 1000 2000\r\nhello"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(payload) != "hello" || !d.Done() {
+		t.Fatalf("bad: %q", payload)
+	}
+	if addr := d.RemoteAddr(peer); addr.String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", addr)
+	}
+	if payload, _ := d.Decode([]byte("PROXY again")); string(payload) != "PROXY again" {
+		t.Fatalf("bad: %q", payload)
+	}
+}
+
+func TestDecoderWithoutHeader(t *testing.T) {
+	peer := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 4000}
+	d := &eventloop.Decoder{}
+
+	// The start of the data looks like a signature until it doesn't
+	if payload, err := d.Decode([]byte("PRO")); err != nil || len(payload) != 0 {
+		t.Fatalf("bad: %q, %v", payload, err)
+	}
+	payload, err := d.Decode([]byte("MPT"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(payload) != "PROMPT" {
+		t.Fatalf("bad: %q", payload)
+	}
+	if addr := d.RemoteAddr(peer); addr != peer {
+		t.Fatalf("bad: %v", addr)
+	}
+
+	d = &eventloop.Decoder{Policy: proxyproto.REQUIRE}
+	if _, err := d.Decode([]byte("GET /")); err != proxyproto.ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := d.Decode([]byte("GET /")); err != proxyproto.ErrNoProxyProtocol {
+		t.Fatalf("bad: error not sticky: %v", err)
+	}
+}
+
+func TestDecoderPolicies(t *testing.T) {
+	header := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+
+	d := &eventloop.Decoder{Policy: proxyproto.REJECT}
+	if _, err := d.Decode(header); err != proxyproto.ErrSuperfluousProxyHeader {
+		t.Fatalf("bad: %v", err)
+	}
+
+	d = &eventloop.Decoder{Policy: proxyproto.IGNORE}
+	if payload, err := d.Decode(header); err != nil || len(payload) != 0 || d.Header() != nil {
+		t.Fatalf("bad: %q, %v, %v", payload, err, d.Header())
+	}
+
+	d = &eventloop.Decoder{Policy: proxyproto.SKIP}
+	if payload, err := d.Decode(header); err != nil || string(payload) != string(header) {
+		t.Fatalf("bad: %q, %v", payload, err)
+	}
+
+	errInvalid := proxyproto.ErrInvalidAddress
+	d = &eventloop.Decoder{Validate: func(*proxyproto.Header) error { return errInvalid }}
+	if _, err := d.Decode(header); err != errInvalid {
+		t.Fatalf("bad: %v", err)
+	}
+}
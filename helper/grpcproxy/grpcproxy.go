@@ -0,0 +1,107 @@
+// Package grpcproxy provides helpers for gRPC servers accepting connections
+// through the PROXY protocol.
+//
+// gRPC already reports the client address rewritten by a proxyproto.Listener
+// in peer.Peer.Addr. The transport credentials of this package also make the
+// full header, TLVs included, available from the peer information:
+//
+//	server := grpc.NewServer(grpc.Creds(grpcproxy.NewCredentials(nil)))
+//	server.Serve(&proxyproto.Listener{Listener: ln})
+//
+//	// In a service method
+//	header, ok := grpcproxy.HeaderFromContext(ctx)
+//
+// The package is a module of its own, so that the gRPC dependencies aren't
+// pulled by the importers of proxyproto.
+package grpcproxy
+
+import (
+	"context"
+	"net"
+
+	"github.com/iqhive/go-proxyproto"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+)
+
+// AuthInfo is the authentication information of connections accepted with
+// the credentials returned by NewCredentials. It carries the proxy header of
+// the connection along with the authentication information of the wrapped
+// credentials, such as credentials.TLSInfo.
+type AuthInfo struct {
+	credentials.AuthInfo
+
+	// Header is the proxy header received on the connection, nil if there
+	// was none.
+	Header *proxyproto.Header
+}
+
+// GetCommonAuthInfo returns the common authentication information of the
+// wrapped credentials, so that gRPC enforces security levels as it would
+// without the wrapper.
+func (ai AuthInfo) GetCommonAuthInfo() credentials.CommonAuthInfo {
+	if ci, ok := ai.AuthInfo.(interface {
+		GetCommonAuthInfo() credentials.CommonAuthInfo
+	}); ok {
+		return ci.GetCommonAuthInfo()
+	}
+	return credentials.CommonAuthInfo{}
+}
+
+type transportCredentials struct {
+	credentials.TransportCredentials
+}
+
+// NewCredentials returns transport credentials which capture the proxy header
+// of server connections during the handshake and expose it as an AuthInfo.
+// The handshake itself is delegated to creds, or done without security if
+// creds is nil. Client handshakes are left to creds untouched.
+//
+// The header is read before the handshake of creds, so the server must accept
+// connections from a proxyproto.Listener: connections of other listeners are
+// handed over without a header.
+func NewCredentials(creds credentials.TransportCredentials) credentials.TransportCredentials {
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+	return &transportCredentials{TransportCredentials: creds}
+}
+
+func (c *transportCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	var header *proxyproto.Header
+	if hc, ok := conn.(interface{ ProxyHeader() *proxyproto.Header }); ok {
+		// gRPC set the handshake deadline, which bounds the read of the
+		// header as well
+		header = hc.ProxyHeader()
+	}
+	conn, authInfo, err := c.TransportCredentials.ServerHandshake(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, AuthInfo{AuthInfo: authInfo, Header: header}, nil
+}
+
+func (c *transportCredentials) Clone() credentials.TransportCredentials {
+	return &transportCredentials{TransportCredentials: c.TransportCredentials.Clone()}
+}
+
+// HeaderFromPeer returns the proxy header captured by the credentials
+// returned by NewCredentials. It returns false if the peer didn't send one.
+func HeaderFromPeer(p *peer.Peer) (*proxyproto.Header, bool) {
+	if p == nil {
+		return nil, false
+	}
+	ai, ok := p.AuthInfo.(AuthInfo)
+	if !ok || ai.Header == nil {
+		return nil, false
+	}
+	return ai.Header, true
+}
+
+// HeaderFromContext returns the proxy header of the peer of an RPC, as found
+// by HeaderFromPeer.
+func HeaderFromContext(ctx context.Context) (*proxyproto.Header, bool) {
+	p, _ := peer.FromContext(ctx)
+	return HeaderFromPeer(p)
+}
@@ -0,0 +1,92 @@
+package grpcproxy_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/helper/grpcproxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+func TestHeaderFromContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type result struct {
+		header *proxyproto.Header
+		addr   net.Addr
+	}
+	results := make(chan result, 1)
+	server := grpc.NewServer(
+		grpc.Creds(grpcproxy.NewCredentials(nil)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			header, _ := grpcproxy.HeaderFromContext(ctx)
+			p, _ := peer.FromContext(ctx)
+			results <- result{header: header, addr: p.Addr}
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(&proxyproto.Listener{Listener: ln})
+	defer server.Stop()
+
+	header := proxyproto.HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetUniqueID([]byte("request-1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	client, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := header.WriteTo(conn); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := healthpb.NewHealthClient(client).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	res := <-results
+	if res.header == nil {
+		t.Fatalf("bad: no header")
+	}
+	if id, ok := res.header.UniqueID(); !ok || string(id) != "request-1" {
+		t.Fatalf("bad: %q, %v", id, ok)
+	}
+	if res.addr.String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", res.addr)
+	}
+}
+
+func TestHeaderFromContextMissing(t *testing.T) {
+	if _, ok := grpcproxy.HeaderFromContext(context.Background()); ok {
+		t.Fatalf("bad: header without peer")
+	}
+	if _, ok := grpcproxy.HeaderFromPeer(&peer.Peer{AuthInfo: grpcproxy.AuthInfo{}}); ok {
+		t.Fatalf("bad: header from empty auth info")
+	}
+}
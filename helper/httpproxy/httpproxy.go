@@ -0,0 +1,59 @@
+// Package httpproxy provides helpers for HTTP reverse proxies which forward
+// the client address to their origins using the PROXY protocol.
+package httpproxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iqhive/go-proxyproto"
+)
+
+type connContextKey struct{}
+
+// ConnContext stores the inbound connection in the request context. It is
+// meant to be used as http.Server.ConnContext so that DialContext can derive
+// the PROXY header of the outbound connection from it.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// InboundConn returns the inbound connection stored by ConnContext, if any.
+func InboundConn(ctx context.Context) (net.Conn, bool) {
+	c, ok := ctx.Value(connContextKey{}).(net.Conn)
+	return c, ok
+}
+
+// DialContext returns a dial function which writes a PROXY header of the
+// given version on every new connection, before handing it to the caller.
+//
+// The header carries the addresses of the inbound connection found in the
+// context (see ConnContext). When the inbound connection is itself a
+// proxyproto.Conn, the client address received from the upstream proxy is
+// forwarded. Without an inbound connection a LOCAL header is sent.
+func DialContext(version byte) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return proxyproto.TransportDialer(version, func(ctx context.Context) (net.Addr, net.Addr) {
+		if inbound, ok := InboundConn(ctx); ok {
+			return inbound.RemoteAddr(), inbound.LocalAddr()
+		}
+		return nil, nil
+	})
+}
+
+// NewTransport returns an http.Transport suitable for httputil.ReverseProxy
+// which preserves the client address towards the origin using PROXY headers
+// of the given version.
+//
+// A PROXY header describes a single client, so origin connections can't be
+// shared between inbound connections: keep-alives are disabled and every
+// request uses a fresh origin connection.
+func NewTransport(version byte) *http.Transport {
+	return &http.Transport{
+		DialContext:           DialContext(version),
+		DisableKeepAlives:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,92 @@
+package httpproxy_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/helper/httpproxy"
+)
+
+func ExampleNewTransport() {
+	origin, err := url.Parse("http://localhost:8080")
+	if err != nil {
+		log.Fatalf("failed to parse origin URL: %v", err)
+	}
+
+	rp := httputil.NewSingleHostReverseProxy(origin)
+	rp.Transport = httpproxy.NewTransport(2)
+
+	server := &http.Server{
+		Addr:        ":80",
+		Handler:     rp,
+		ConnContext: httpproxy.ConnContext,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
+
+func TestReverseProxyForwardsClientAddr(t *testing.T) {
+	// Origin reporting the client address it sees
+	originLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	origin := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.RemoteAddr)
+		}),
+	}
+	go origin.Serve(&proxyproto.Listener{Listener: originLn, Policy: func(net.Addr) (proxyproto.Policy, error) {
+		return proxyproto.REQUIRE, nil
+	}})
+	defer origin.Close()
+
+	// Reverse proxy in front of the origin
+	originURL, err := url.Parse("http://" + originLn.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse origin URL: %v", err)
+	}
+	rp := httputil.NewSingleHostReverseProxy(originURL)
+	rp.Transport = httpproxy.NewTransport(2)
+
+	frontLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	front := &http.Server{Handler: rp, ConnContext: httpproxy.ConnContext}
+	go front.Serve(frontLn)
+	defer front.Close()
+
+	var clientAddr net.Addr
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := new(net.Dialer).DialContext(ctx, network, addr)
+			if err == nil {
+				clientAddr = conn.LocalAddr()
+			}
+			return conn, err
+		},
+	}}
+
+	resp, err := client.Get("http://" + frontLn.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to perform HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if string(body) != clientAddr.String() {
+		t.Fatalf("origin saw %q, expected client address %q", body, clientAddr)
+	}
+}
@@ -0,0 +1,48 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+)
+
+type httpConnContextKey struct{}
+
+// headerConn is implemented by the connections of this package which carry a
+// proxy header, such as Conn and TLSConn.
+type headerConn interface {
+	ProxyHeader() *Header
+}
+
+// HTTPConnContext stores the connection in the context so that HTTP handlers
+// can retrieve its proxy header with HeaderFromContext. It is meant to be used
+// as http.Server.ConnContext:
+//
+//	server := &http.Server{ConnContext: proxyproto.HTTPConnContext}
+//
+// The header isn't read here, since net/http calls ConnContext from its
+// accept loop: it is read on the first call to HeaderFromContext, by which
+// time the request has usually been parsed already.
+func HTTPConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, httpConnContextKey{}, c)
+}
+
+// HeaderFromContext returns the proxy header of the connection stored by
+// HTTPConnContext, TLVs included. Connections wrapped by another package,
+// like a tls.Conn on top of a Conn, are unwrapped through their NetConn
+// method. It returns false if there is no such connection or it didn't carry
+// a proxy header.
+func HeaderFromContext(ctx context.Context) (*Header, bool) {
+	c, _ := ctx.Value(httpConnContextKey{}).(net.Conn)
+	for c != nil {
+		if hc, ok := c.(headerConn); ok {
+			header := hc.ProxyHeader()
+			return header, header != nil
+		}
+		wrapper, ok := c.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		c = wrapper.NetConn()
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package proxyproto
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestHeaderFromContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+
+	server := &http.Server{
+		ConnContext: HTTPConnContext,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header, ok := HeaderFromContext(r.Context())
+			if !ok {
+				http.Error(w, "no header", http.StatusBadRequest)
+				return
+			}
+			id, _ := header.UniqueID()
+			w.Write(id)
+		}),
+	}
+	go server.Serve(pl)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetUniqueID([]byte("request-1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := header.WriteTo(conn); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.org\r\n\r\n"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "request-1" {
+		t.Fatalf("bad: %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestHeaderFromContextMissing(t *testing.T) {
+	if _, ok := HeaderFromContext(context.Background()); ok {
+		t.Fatalf("bad: header without connection")
+	}
+
+	// A connection of another package carries no header
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if _, ok := HeaderFromContext(HTTPConnContext(context.Background(), server)); ok {
+		t.Fatalf("bad: header from plain connection")
+	}
+}
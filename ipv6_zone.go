@@ -0,0 +1,39 @@
+package proxyproto
+
+import "net"
+
+// WithIPv6Zone sets the zone, i.e. the interface name or index, of the
+// link-local IPv6 addresses of the header read by a connection when passed
+// as option to NewConn(), see Header.SetIPv6Zone.
+func WithIPv6Zone(zone string) func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.zone = zone
+	}
+}
+
+// SetIPv6Zone sets zone on the source and destination addresses of the
+// header which are link-local IPv6 addresses, leaving the others untouched.
+//
+// Neither protocol version can carry a zone, which is only meaningful on the
+// host it comes from: a version 1 header with a zoned address is refused
+// with ErrInvalidAddress, and Format drops the zones of the addresses. The
+// receiving end knows which interface its upstream is reached through, and
+// sets it back with SetIPv6Zone, or the WithIPv6Zone option of a Conn.
+func (header *Header) SetIPv6Zone(zone string) {
+	for _, addr := range []net.Addr{header.SourceAddr, header.DestinationAddr} {
+		switch a := addr.(type) {
+		case *net.TCPAddr:
+			if isLinkLocalIPv6(a.IP) {
+				a.Zone = zone
+			}
+		case *net.UDPAddr:
+			if isLinkLocalIPv6(a.IP) {
+				a.Zone = zone
+			}
+		}
+	}
+}
+
+func isLinkLocalIPv6(ip net.IP) bool {
+	return ip.To4() == nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast())
+}
@@ -0,0 +1,49 @@
+package proxyproto
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestIPv6Zone(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv6,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 1000, Zone: "eth0"},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 2000},
+	}
+
+	for _, version := range []byte{1, 2} {
+		header.Version = version
+		b, err := header.Format()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if strings.Contains(string(b), "eth0") {
+			t.Fatalf("bad: zone sent in %q", b)
+		}
+
+		stream := pipeStream{Reader: strings.NewReader(string(b)), Writer: io.Discard}
+		conn := NewConnFromReadWriter(stream, nil, nil, SetReadHeaderTimeout(0), WithIPv6Zone("eth1"))
+		got := conn.ProxyHeader()
+		if got == nil {
+			t.Fatalf("bad: no header for version %d", version)
+		}
+		if zone := got.SourceAddr.(*net.TCPAddr).Zone; zone != "eth1" {
+			t.Fatalf("bad: source zone %q", zone)
+		}
+		if zone := got.DestinationAddr.(*net.TCPAddr).Zone; zone != "" {
+			t.Fatalf("bad: destination zone %q", zone)
+		}
+	}
+
+	// Zones can't be sent in version 1 headers
+	_, err := Read(bufio.NewReader(strings.NewReader("PROXY TCP6 fe80::1%eth0 ::2 1000 2000\r\n")))
+	if err != ErrInvalidAddress {
+		t.Fatalf("bad: %v", err)
+	}
+}
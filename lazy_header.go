@@ -0,0 +1,85 @@
+package proxyproto
+
+import "sync"
+
+// ErrLazyHeaderSent is returned when changing a lazy header which was
+// already written, see SetLazyHeader.
+var ErrLazyHeaderSent = newError(ErrCodeInvalidUse, "proxyproto: lazy header already sent")
+
+// lazyHeader is the header written before the first payload written to a
+// connection, see WithLazyHeader.
+type lazyHeader struct {
+	mu     sync.Mutex
+	header *Header
+	sent   bool
+
+	once sync.Once
+	err  error
+}
+
+// WithLazyHeader makes a connection write header right before its first
+// payload when passed as option to NewConn(), for clients which set up the
+// connection before knowing what the header carries. The header can be
+// replaced until then with SetLazyHeader, and is written along with the
+// payload of the first Write in a single vectored write, see
+// WriteHeaderAndPayload, or before the data of ReadFrom, of zero-copy
+// transfers and of CloseWrite when relaying with CopyDuplex. If the header is
+// still nil by then, the write fails with ErrNilHeader, as do the following
+// ones.
+//
+// Such a connection speaks to a server, so WithPolicy(SKIP) is usually
+// passed as well, so that reads don't look for a header sent by the server.
+func WithLazyHeader(header *Header) func(*Conn) {
+	return func(c *Conn) {
+		c.lazyHeader = &lazyHeader{header: header}
+	}
+}
+
+// SetLazyHeader sets the header written before the first payload of a
+// connection created with WithLazyHeader. It fails with ErrLazyHeaderSent
+// once the header was written, and with ErrNilHeader without the option.
+func (p *Conn) SetLazyHeader(header *Header) error {
+	l := p.lazyHeader
+	if l == nil {
+		return ErrNilHeader
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sent {
+		return ErrLazyHeaderSent
+	}
+	l.header = header
+	return nil
+}
+
+// flushLazyHeader writes the lazy header on its own if there is one which
+// wasn't written yet, for the paths bypassing Write.
+func (p *Conn) flushLazyHeader() error {
+	if p.lazyHeader == nil {
+		return nil
+	}
+	_, _, err := p.writeLazyHeader(nil)
+	return err
+}
+
+// writeLazyHeader writes the lazy header along with payload the first time
+// it is called, in which case written is true and n is the number of bytes
+// of payload written. Afterwards, err is the error of the header write, if
+// any.
+func (p *Conn) writeLazyHeader(payload []byte) (n int, written bool, err error) {
+	l := p.lazyHeader
+	l.once.Do(func() {
+		l.mu.Lock()
+		header := l.header
+		l.sent = true
+		l.mu.Unlock()
+
+		written = true
+		n, l.err = WriteHeaderAndPayload(p.conn, header, payload)
+	})
+	if written {
+		return n, true, l.err
+	}
+	return 0, false, l.err
+}
@@ -0,0 +1,81 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestLazyHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(conn *Conn) error
+	}{
+		{"write", func(conn *Conn) error {
+			_, err := conn.Write([]byte("ping"))
+			return err
+		}},
+		{"read from", func(conn *Conn) error {
+			_, err := io.Copy(conn, bytes.NewReader([]byte("ping")))
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer server.Close()
+
+			// The header is decided once the connection is set up
+			conn := NewConn(client, WithLazyHeader(nil), WithPolicy(SKIP))
+			defer conn.Close()
+			header := HeaderProxyFromAddrs(2,
+				&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+				&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+			)
+			if err := conn.SetLazyHeader(header); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			received := make(chan string, 1)
+			go func() {
+				proxied := NewConn(server, WithPolicy(REQUIRE))
+				b := make([]byte, 4)
+				if _, err := io.ReadFull(proxied, b); err != nil {
+					received <- err.Error()
+					return
+				}
+				received <- proxied.RemoteAddr().String() + " " + string(b)
+			}()
+
+			if err := tc.write(conn); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if got := <-received; got != "10.1.1.1:1000 ping" {
+				t.Fatalf("bad: %s", got)
+			}
+			if err := conn.SetLazyHeader(header); err != ErrLazyHeaderSent {
+				t.Fatalf("bad: %v", err)
+			}
+		})
+	}
+}
+
+func TestLazyHeaderNil(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+
+	conn := NewConn(client, WithLazyHeader(nil), WithPolicy(SKIP))
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Write([]byte("ping")); err != ErrNilHeader {
+			t.Fatalf("bad: %v", err)
+		}
+	}
+
+	// Without the option, there is no lazy header to set
+	plain := NewConn(client)
+	defer plain.Close()
+	if err := plain.SetLazyHeader(&Header{}); err != ErrNilHeader {
+		t.Fatalf("bad: %v", err)
+	}
+}
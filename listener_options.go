@@ -0,0 +1,97 @@
+package proxyproto
+
+import (
+	"log"
+	"time"
+)
+
+// ListenerOptions holds the settings of a Listener which can be swapped
+// while it is running, see Listener.UpdateOptions. Each field has the
+// meaning of the Listener field of the same name.
+type ListenerOptions struct {
+	Policy                 PolicyFunc
+	ConnPolicy             ConnPolicyFunc
+	ValidateHeader         Validator
+	ValidateUpstreamHeader UpstreamValidator
+	ReadHeaderTimeout      time.Duration
+	SNIPolicy              SNIPolicyFunc
+	HeaderPolicy           HeaderPolicyFunc
+	HeaderTransforms       []HeaderTransform
+	MinHeaderRate          int
+	MinHeaderRateGrace     time.Duration
+	Enricher               Enricher
+	ErrorLog               *log.Logger
+
+	// The checks of the headers
+	RejectResponse             []byte
+	DisableV1                  bool
+	DisableV2                  bool
+	AllowedVersions            Versions
+	AllowedCommands            []ProtocolVersionAndCommand
+	AllowedFamilies            []AddressFamilyAndProtocol
+	HardenedMode               bool
+	StrictTLVs                 bool
+	MaxTLVBytes                int
+	MaxTLVCount                int
+	RejectUnspecifiedAddresses bool
+}
+
+// ListenerOption changes ListenerOptions.
+type ListenerOption func(*ListenerOptions)
+
+// UpdateOptions atomically replaces the options of the listener with the
+// current ones changed by opts, e.g. when reloading configuration:
+//
+//	l.UpdateOptions(func(o *proxyproto.ListenerOptions) {
+//		o.ConnPolicy = newPolicy
+//		o.ReadHeaderTimeout = time.Second
+//	})
+//
+// Connections accepted afterwards use the new options, those already
+// accepted keep the ones they were accepted with. Once UpdateOptions has been
+// called, the listener ignores the fields covered by ListenerOptions, which
+// must not be changed anymore.
+func (p *Listener) UpdateOptions(opts ...ListenerOption) {
+	p.optionsMu.Lock()
+	defer p.optionsMu.Unlock()
+
+	next := p.Options()
+	for _, opt := range opts {
+		opt(&next)
+	}
+	p.options.Store(&next)
+}
+
+// Options returns the options currently in effect: those last set by
+// UpdateOptions, or the listener's fields if it was never called.
+func (p *Listener) Options() ListenerOptions {
+	if opts := p.options.Load(); opts != nil {
+		return *opts
+	}
+	return ListenerOptions{
+		Policy:                 p.Policy,
+		ConnPolicy:             p.ConnPolicy,
+		ValidateHeader:         p.ValidateHeader,
+		ValidateUpstreamHeader: p.ValidateUpstreamHeader,
+		ReadHeaderTimeout:      p.ReadHeaderTimeout,
+		SNIPolicy:              p.SNIPolicy,
+		HeaderPolicy:           p.HeaderPolicy,
+		HeaderTransforms:       p.HeaderTransforms,
+		MinHeaderRate:          p.MinHeaderRate,
+		MinHeaderRateGrace:     p.MinHeaderRateGrace,
+		Enricher:               p.Enricher,
+		ErrorLog:               p.ErrorLog,
+
+		RejectResponse:             p.RejectResponse,
+		DisableV1:                  p.DisableV1,
+		DisableV2:                  p.DisableV2,
+		AllowedVersions:            p.AllowedVersions,
+		AllowedCommands:            p.AllowedCommands,
+		AllowedFamilies:            p.AllowedFamilies,
+		HardenedMode:               p.HardenedMode,
+		StrictTLVs:                 p.StrictTLVs,
+		MaxTLVBytes:                p.MaxTLVBytes,
+		MaxTLVCount:                p.MaxTLVCount,
+		RejectUnspecifiedAddresses: p.RejectUnspecifiedAddresses,
+	}
+}
@@ -0,0 +1,96 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenerUpdateOptions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{
+		Listener:          l,
+		Policy:            func(net.Addr) (Policy, error) { return IGNORE, nil },
+		ReadHeaderTimeout: time.Second,
+	}
+	defer pl.Close()
+
+	accept := func() *Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+		c, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c.(*Conn)
+	}
+
+	before := accept()
+	pl.UpdateOptions(func(o *ListenerOptions) {
+		o.Policy = nil
+		o.ConnPolicy = func(ConnPolicyOptions) (Policy, error) { return USE, nil }
+	})
+	after := accept()
+
+	if before.RemoteAddr().String() == "10.1.1.1:1000" {
+		t.Fatalf("bad: header used before the update")
+	}
+	if after.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: header not used after the update: %v", after.RemoteAddr())
+	}
+	if after.readHeaderTimeout != time.Second {
+		t.Fatalf("bad: untouched option lost: %v", after.readHeaderTimeout)
+	}
+	if opts := pl.Options(); opts.Policy != nil || opts.ReadHeaderTimeout != time.Second {
+		t.Fatalf("bad: %+v", opts)
+	}
+}
+
+func TestListenerUpdateHeaderChecks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+	defer pl.Close()
+
+	accept := func() *Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+		c, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c.(*Conn)
+	}
+
+	before := accept()
+	pl.UpdateOptions(func(o *ListenerOptions) {
+		o.AllowedVersions = Version2
+	})
+	after := accept()
+
+	if err := before.HeaderError(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := after.HeaderError(); err != ErrVersionNotAllowed {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package proxyproto
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ListenerStats counts the connections of a Listener by outcome, see
+// Listener.Stats. Each connection is counted as accepted, then under one of
+// the other counters once its header has been handled, except for the ones
+// closed by Accept because of MaxConns, see Listener.Shed, and the ones going
+// away before sending anything.
+type ListenerStats struct {
+	// Accepted is the number of connections accepted by the underlying
+	// listener.
+	Accepted uint64
+	// ProxyV1 and ProxyV2 are the numbers of connections which sent a
+	// header of the given version, whether it was used or ignored.
+	ProxyV1 uint64
+	ProxyV2 uint64
+	// NoProxy is the number of connections handled without a header, which
+	// didn't send one or skipped the PROXY protocol as the policy decided.
+	NoProxy uint64
+	// PolicyRejected is the number of connections refused by a policy or
+	// the FailureLimiter, see ErrCodePolicyReject.
+	PolicyRejected uint64
+	// ParseErrors is the number of connections whose header couldn't be
+	// read, e.g. a malformed header or a missing one under REQUIRE.
+	ParseErrors uint64
+	// ValidationErrors is the number of headers refused by a validator.
+	ValidationErrors uint64
+	// HeaderReadTimeouts is the number of connections refused because
+	// their header didn't arrive in time or at the minimum rate.
+	HeaderReadTimeouts uint64
+}
+
+// listenerStats holds the counters of ListenerStats.
+type listenerStats struct {
+	accepted           atomic.Uint64
+	proxyV1            atomic.Uint64
+	proxyV2            atomic.Uint64
+	noProxy            atomic.Uint64
+	policyRejected     atomic.Uint64
+	parseErrors        atomic.Uint64
+	validationErrors   atomic.Uint64
+	headerReadTimeouts atomic.Uint64
+}
+
+// Stats returns the counters of the connections accepted so far.
+func (p *Listener) Stats() ListenerStats {
+	s := &p.stats
+	return ListenerStats{
+		Accepted:           s.accepted.Load(),
+		ProxyV1:            s.proxyV1.Load(),
+		ProxyV2:            s.proxyV2.Load(),
+		NoProxy:            s.noProxy.Load(),
+		PolicyRejected:     s.policyRejected.Load(),
+		ParseErrors:        s.parseErrors.Load(),
+		ValidationErrors:   s.validationErrors.Load(),
+		HeaderReadTimeouts: s.headerReadTimeouts.Load(),
+	}
+}
+
+// recordHeader counts the outcome of handling the header of conn.
+func (s *listenerStats) recordHeader(conn *Conn) {
+	if conn.readErr == nil {
+		switch conn.headerVersion {
+		case 1:
+			s.proxyV1.Add(1)
+		case 2:
+			s.proxyV2.Add(1)
+		default:
+			s.noProxy.Add(1)
+		}
+		return
+	}
+	if conn.readErr == io.EOF {
+		return
+	}
+
+	switch conn.readErrCode {
+	case ErrCodePolicyReject:
+		s.policyRejected.Add(1)
+	case ErrCodeValidatorReject:
+		s.validationErrors.Add(1)
+	case ErrCodeTimeout, ErrCodeTooSlow:
+		s.headerReadTimeouts.Add(1)
+	default:
+		s.parseErrors.Add(1)
+	}
+}
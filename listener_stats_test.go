@@ -0,0 +1,63 @@
+package proxyproto
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestListenerStats(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, ValidateHeader: func(header *Header) error {
+		if header.SourceAddr.(*net.TCPAddr).Port == 6666 {
+			return errors.New("bad port")
+		}
+		return nil
+	}}
+	defer pl.Close()
+
+	v2, err := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, payload := range [][]byte{
+		[]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"),
+		v2,
+		[]byte("GET / HTTP/1.1\r\n"),
+		[]byte("PROXY TCP4 10.1.1.1\r\n"),
+		[]byte("PROXY TCP4 10.1.1.1 20.2.2.2 6666 2000\r\n"),
+	} {
+		client, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer client.Close()
+		// Followed by some data, so that reading doesn't block
+		client.Write(append(payload, "ping"...))
+
+		conn, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+
+	want := ListenerStats{
+		Accepted:         5,
+		ProxyV1:          1,
+		ProxyV2:          1,
+		NoProxy:          1,
+		ParseErrors:      1,
+		ValidationErrors: 1,
+	}
+	if got := pl.Stats(); got != want {
+		t.Fatalf("bad: %+v", got)
+	}
+}
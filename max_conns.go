@@ -0,0 +1,49 @@
+package proxyproto
+
+import "sync"
+
+// Conns returns the number of live connections wrapped in a Conn, accepted
+// by the listener and not closed or detached yet. It is always 0 unless
+// TrackConns or MaxConns is set.
+func (p *Listener) Conns() int {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+	return len(p.conns)
+}
+
+// connsFull reports whether MaxConns connections are live.
+func (p *Listener) connsFull() bool {
+	return p.MaxConns > 0 && p.Conns() >= p.MaxConns
+}
+
+// waitForConnSlot waits while MaxConns connections are live. It returns
+// false if the listener is closed meanwhile.
+//
+// Accept is usually called from a single goroutine, as Serve does. Callers
+// accepting concurrently may each get a slot and exceed MaxConns by the
+// number of them.
+func (p *Listener) waitForConnSlot() bool {
+	if p.MaxConns <= 0 {
+		return true
+	}
+
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+	for len(p.conns) >= p.MaxConns && !p.stopped {
+		if p.connFreed == nil {
+			p.connFreed = sync.NewCond(&p.connsMu)
+		}
+		p.connFreed.Wait()
+	}
+	return !p.stopped
+}
+
+// stopWaitingForConns makes the Accept calls waiting for a slot return.
+func (p *Listener) stopWaitingForConns() {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+	p.stopped = true
+	if p.connFreed != nil {
+		p.connFreed.Broadcast()
+	}
+}
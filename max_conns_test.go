@@ -0,0 +1,110 @@
+package proxyproto
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenerMaxConns(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, MaxConns: 1}
+	defer pl.Close()
+
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer client.Close()
+	}
+
+	first, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pl.Conns() != 1 {
+		t.Fatalf("bad: %d", pl.Conns())
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := pl.Accept()
+		accepted <- conn
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("bad: accepted above MaxConns")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	first.Close()
+	select {
+	case conn := <-accepted:
+		if conn == nil {
+			t.Fatal("bad: no connection")
+		}
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept didn't resume once a connection was closed")
+	}
+
+	// Closing the listener stops Accept from waiting
+	second, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer second.Close()
+	blocked, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer blocked.Close()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pl.Close()
+	}()
+	if _, err := pl.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestListenerMaxConnsShedLoad(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, MaxConns: 1, ShedLoad: true}
+	defer pl.Close()
+
+	first, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer first.Close()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	shed, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer shed.Close()
+	go pl.Accept()
+
+	shed.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := shed.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if pl.Shed() != 1 {
+		t.Fatalf("bad: %d shed", pl.Shed())
+	}
+}
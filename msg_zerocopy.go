@@ -0,0 +1,92 @@
+package proxyproto
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultMsgZeroCopyThreshold is the write size from which MSG_ZEROCOPY is
+// used when WithMsgZeroCopy is given no threshold. Below about 10 KiB, the
+// cost of the page pinning and completion notifications outweighs the copy.
+const DefaultMsgZeroCopyThreshold = 32 * 1024
+
+// msgZeroCopyRingSize is the number of buffers a relay sends from, which may
+// all be waiting for their completion at once.
+const msgZeroCopyRingSize = 8
+
+// msgZeroCopy holds the MSG_ZEROCOPY state of the socket of a connection.
+type msgZeroCopy struct {
+	threshold int
+
+	// once enables SO_ZEROCOPY on the socket on first use, which sets
+	// supported if it worked
+	once      sync.Once
+	supported bool
+
+	// mu serializes the zero-copy writes, whose completions are tracked
+	// by counting the sends: the kernel numbers them from 0 and notifies
+	// the ranges it is done with, in order for TCP
+	mu        sync.Mutex
+	sends     uint32
+	completed uint32
+	// ring holds the buffers of the relays, kept by the connection as the
+	// kernel may still use them after a relay failed
+	ring []zeroCopySlot
+	// maxInFlight is the most sends of a relay seen waiting for their
+	// completion at once
+	maxInFlight int
+}
+
+// zeroCopySlot is a relay buffer, in use by the kernel until the send
+// numbered end-1 is completed.
+type zeroCopySlot struct {
+	buf  []byte
+	end  uint32
+	busy bool
+}
+
+// WithMsgZeroCopy makes the writes of at least threshold bytes to a
+// connection use MSG_ZEROCOPY when passed as option to NewConn(), as do the
+// relays to it through ZeroCopy or io.Copy. The kernel then sends the pages
+// of the written buffer instead of copying them, which cuts the CPU spent by
+// relays on fast links. A threshold of 0 or less means
+// DefaultMsgZeroCopyThreshold.
+//
+// Relays read into buffers of their own, of which up to 8 are sent at once:
+// each is reused once the kernel is done with it. As the buffer given to
+// Write must not change until then, Write returns once the peer has
+// acknowledged the data, rather than once it is queued, so it only pays off
+// for large writes. The write deadline applies to both.
+//
+// It requires Linux 4.14 or later and a TCP connection; elsewhere, or when
+// the kernel refuses it, writes are regular ones.
+func WithMsgZeroCopy(threshold int) func(*Conn) {
+	return func(c *Conn) {
+		if threshold <= 0 {
+			threshold = DefaultMsgZeroCopyThreshold
+		}
+		c.msgZeroCopy = &msgZeroCopy{threshold: threshold}
+	}
+}
+
+// relayBuffer returns buf if it is large enough for relayed chunks to reach
+// the threshold, or a new buffer of twice the threshold.
+func (z *msgZeroCopy) relayBuffer(buf []byte) []byte {
+	if len(buf) >= z.threshold {
+		return buf
+	}
+	return make([]byte, 2*z.threshold)
+}
+
+// relayMsgZeroCopy copies src to the connection, through the ring of relay
+// buffers when MSG_ZEROCOPY can be used, or through its writes otherwise.
+func (p *Conn) relayMsgZeroCopy(src io.Reader, buf []byte) (int64, error) {
+	// The lazy header goes first, on its own
+	if err := p.flushLazyHeader(); err != nil {
+		return 0, err
+	}
+	if n, handled, err := p.msgZeroCopy.relay(p.conn, src); handled {
+		return n, err
+	}
+	return io.CopyBuffer(struct{ io.Writer }{p}, struct{ io.Reader }{src}, p.msgZeroCopy.relayBuffer(buf))
+}
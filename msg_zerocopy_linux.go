@@ -0,0 +1,235 @@
+//go:build linux
+// +build linux
+
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Linux constants for MSG_ZEROCOPY, see
+// https://www.kernel.org/doc/html/latest/networking/msg_zerocopy.html
+const (
+	soZeroCopy             = 60
+	msgZeroCopyFlag        = 0x4000000
+	soEEOriginZeroCopy     = 5
+	soEECodeZeroCopyCopied = 1
+)
+
+// rawConn returns the raw connection of conn once SO_ZEROCOPY is enabled on
+// it, or false if MSG_ZEROCOPY can't be used.
+func (z *msgZeroCopy) rawConn(conn net.Conn) (syscall.RawConn, bool) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, false
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return nil, false
+	}
+	z.once.Do(func() {
+		rawConn.Control(func(fd uintptr) {
+			z.supported = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soZeroCopy, 1) == nil
+		})
+	})
+	return rawConn, z.supported
+}
+
+// write sends b to conn with MSG_ZEROCOPY and waits for the kernel to be done
+// with it. It reports whether it handled the write: otherwise nothing was
+// sent and the caller must write b itself.
+func (z *msgZeroCopy) write(conn net.Conn, b []byte) (int, bool, error) {
+	rawConn, ok := z.rawConn(conn)
+	if !ok {
+		return 0, false, nil
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	written, err := z.send(rawConn, b)
+	if written > 0 {
+		// b belongs to the caller, who may change it once Write returns
+		if werr := z.wait(rawConn, z.sends); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if written == 0 && err == syscall.ENOBUFS {
+		// Out of memory to pin pages, the regular path copies instead
+		return 0, false, nil
+	}
+	if err == syscall.ENOBUFS {
+		var n int
+		n, err = conn.Write(b[written:])
+		written += n
+	}
+	return written, true, err
+}
+
+// relay copies src to conn, sending the chunks of at least the threshold
+// with MSG_ZEROCOPY from a ring of buffers: each is reused once its
+// completion arrives, so that sends don't wait for the peer. It reports
+// whether it handled the copy: otherwise nothing was read and the caller
+// must copy src itself.
+func (z *msgZeroCopy) relay(conn net.Conn, src io.Reader) (int64, bool, error) {
+	rawConn, ok := z.rawConn(conn)
+	if !ok {
+		return 0, false, nil
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.ring == nil {
+		z.ring = make([]zeroCopySlot, msgZeroCopyRingSize)
+	}
+	var total int64
+	var err error
+	for i := 0; err == nil; i = (i + 1) % len(z.ring) {
+		slot := &z.ring[i]
+		if slot.busy {
+			if err = z.wait(rawConn, slot.end); err != nil {
+				break
+			}
+		}
+		if slot.buf == nil {
+			slot.buf = make([]byte, 2*z.threshold)
+		}
+
+		n, rerr := src.Read(slot.buf)
+		if n > 0 {
+			var written int
+			written, err = z.relayChunk(conn, rawConn, slot, slot.buf[:n])
+			total += int64(written)
+		}
+		if err == nil && rerr != nil {
+			if rerr != io.EOF {
+				err = rerr
+			}
+			break
+		}
+	}
+
+	// The buffers can only be reused by the next relay once the kernel is
+	// done with them, which a failed connection may never tell
+	if werr := z.wait(rawConn, z.sends); werr != nil && err == nil {
+		err = werr
+	}
+	if err == nil {
+		for i := range z.ring {
+			z.ring[i].busy = false
+		}
+	}
+	return total, true, err
+}
+
+// relayChunk writes b, read into the buffer of slot, to conn. Small chunks
+// are copied, as are those the kernel can't pin the pages of.
+func (z *msgZeroCopy) relayChunk(conn net.Conn, rawConn syscall.RawConn, slot *zeroCopySlot, b []byte) (int, error) {
+	if len(b) < z.threshold {
+		return conn.Write(b)
+	}
+	written, err := z.send(rawConn, b)
+	if written > 0 {
+		slot.end, slot.busy = z.sends, true
+		if inFlight := int(z.sends - z.completed); inFlight > z.maxInFlight {
+			z.maxInFlight = inFlight
+		}
+	}
+	if err == syscall.ENOBUFS {
+		var n int
+		n, err = conn.Write(b[written:])
+		written += n
+	}
+	return written, err
+}
+
+// send sends b with MSG_ZEROCOPY, counting the sends, until it is all sent
+// or an error occurs.
+func (z *msgZeroCopy) send(rawConn syscall.RawConn, b []byte) (int, error) {
+	written := 0
+	var sendErr error
+	err := rawConn.Write(func(fd uintptr) bool {
+		for written < len(b) {
+			n, err := syscall.SendmsgN(int(fd), b[written:], nil, nil, msgZeroCopyFlag)
+			switch {
+			case err == syscall.EAGAIN:
+				return false
+			case err == syscall.EINTR:
+				continue
+			case err != nil:
+				sendErr = err
+				return true
+			}
+			written += n
+			z.sends++
+		}
+		return true
+	})
+	if err == nil {
+		err = sendErr
+	}
+	msgZeroCopyBytes.Add(uint64(written))
+	return written, err
+}
+
+// wait waits until the sends numbered up to end-1 are completed. Completion
+// notifications wake the writers of the socket up, so the runtime poller
+// does the waiting, bounded by the write deadline of the connection.
+func (z *msgZeroCopy) wait(rawConn syscall.RawConn, end uint32) error {
+	var recvErr error
+	err := rawConn.Write(func(fd uintptr) bool {
+		recvErr = z.reap(int(fd))
+		return recvErr != nil || int32(z.completed-end) >= 0
+	})
+	if err == nil {
+		err = recvErr
+	}
+	return err
+}
+
+// reap reads the completion notifications queued on the error queue of the
+// socket, without waiting.
+func (z *msgZeroCopy) reap(fd int) error {
+	var oob [128]byte
+	for {
+		_, oobn, _, _, err := syscall.Recvmsg(fd, nil, oob[:], syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+		switch {
+		case err == syscall.EAGAIN:
+			return nil
+		case err == syscall.EINTR:
+			continue
+		case err != nil:
+			return err
+		}
+		if hi, ok := completedSends(oob[:oobn]); ok {
+			z.completed = hi + 1
+		}
+	}
+}
+
+// completedSends returns the number of the last send completed by the
+// notification in the control messages oob, a sock_extended_err.
+func completedSends(oob []byte) (uint32, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, msg := range msgs {
+		isRecvErr := (msg.Header.Level == syscall.SOL_IP && msg.Header.Type == syscall.IP_RECVERR) ||
+			(msg.Header.Level == syscall.SOL_IPV6 && msg.Header.Type == syscall.IPV6_RECVERR)
+		// errno (4), origin, type, code, pad, info (4), data (4)
+		if !isRecvErr || len(msg.Data) < 16 || msg.Data[4] != soEEOriginZeroCopy {
+			continue
+		}
+		if msg.Data[6]&soEECodeZeroCopyCopied != 0 {
+			msgZeroCopyCopied.Add(1)
+		}
+		// The range starts at info, and ends at data
+		return binary.NativeEndian.Uint32(msg.Data[12:16]), true
+	}
+	return 0, false
+}
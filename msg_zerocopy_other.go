@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package proxyproto
+
+import (
+	"io"
+	"net"
+)
+
+// write never handles the write outside of Linux.
+func (z *msgZeroCopy) write(conn net.Conn, b []byte) (int, bool, error) {
+	return 0, false, nil
+}
+
+// relay never handles the copy outside of Linux.
+func (z *msgZeroCopy) relay(conn net.Conn, src io.Reader) (int64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,119 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMsgZeroCopy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn := NewConn(raw, WithMsgZeroCopy(64*1024))
+
+	before := GetZeroCopyStats()
+	small := []byte("small write")
+	large := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	for _, b := range [][]byte{small, large} {
+		if n, err := conn.Write(b); err != nil || n != len(b) {
+			t.Fatalf("bad: %d, %v", n, err)
+		}
+	}
+	// Relays to the connection write with MSG_ZEROCOPY as well
+	if n, err := conn.ReadFrom(bytes.NewReader(large)); err != nil || n != int64(len(large)) {
+		t.Fatalf("bad: %d, %v", n, err)
+	}
+	conn.Close()
+
+	want := append(append(append([]byte(nil), small...), large...), large...)
+	if got := <-received; !bytes.Equal(got, want) {
+		t.Fatalf("bad: received %d bytes, want %d", len(got), len(want))
+	}
+
+	stats := GetZeroCopyStats()
+	sent := stats.MsgZeroCopyBytes - before.MsgZeroCopyBytes
+	if runtime.GOOS != "linux" && sent != 0 {
+		t.Fatalf("bad: %d bytes sent with MSG_ZEROCOPY", sent)
+	}
+	// The small write is a regular one
+	if sent != 0 && sent != uint64(2*len(large)) {
+		t.Fatalf("bad: %d bytes sent with MSG_ZEROCOPY", sent)
+	}
+	t.Logf("%d bytes sent with MSG_ZEROCOPY, %d completions copied", sent, stats.MsgZeroCopyCopied-before.MsgZeroCopyCopied)
+}
+
+func TestMsgZeroCopyRelayPipelines(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		// A slow peer leaves the sends queued, waiting for completion
+		time.Sleep(100 * time.Millisecond)
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn := NewConn(raw, WithMsgZeroCopy(64*1024))
+
+	data := make([]byte, 16*1024*1024)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	before := GetZeroCopyStats()
+	if n, err := conn.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+		t.Fatalf("bad: %d, %v", n, err)
+	}
+	conn.Close()
+
+	if got := <-received; !bytes.Equal(got, data) {
+		t.Fatalf("bad: received %d bytes, want %d", len(got), len(data))
+	}
+	if GetZeroCopyStats().MsgZeroCopyBytes == before.MsgZeroCopyBytes {
+		t.Skip("MSG_ZEROCOPY unsupported")
+	}
+	if conn.msgZeroCopy.maxInFlight < 2 {
+		t.Fatalf("bad: at most %d sends in flight", conn.msgZeroCopy.maxInFlight)
+	}
+	for _, slot := range conn.msgZeroCopy.ring {
+		if slot.busy {
+			t.Fatalf("bad: relay buffer still busy")
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package proxyproto
+
+import "unicode"
+
+// NetNS returns the value of the PP2_TYPE_NETNS TLV of the header, the name
+// of the network namespace the connection was received in, and whether
+// there is one. A value which isn't US-ASCII, as required by the spec, is
+// reported as missing.
+func (header *Header) NetNS() (string, bool) {
+	value, ok := header.tlvValue(PP2_TYPE_NETNS)
+	if !ok || !isASCII(value) {
+		return "", false
+	}
+	return string(value), true
+}
+
+// SetNetNS sets the PP2_TYPE_NETNS TLV of the header to name, replacing the
+// previous one if any and keeping the other TLVs. An empty name removes the
+// TLV. It fails with ErrMalformedTLV if name isn't US-ASCII.
+func (header *Header) SetNetNS(name string) error {
+	if !isASCII([]byte(name)) {
+		return ErrMalformedTLV
+	}
+	return header.replaceTLV(PP2_TYPE_NETNS, []byte(name))
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
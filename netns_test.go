@@ -0,0 +1,33 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNetNS(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if _, ok := header.NetNS(); ok {
+		t.Fatalf("bad: unexpected namespace")
+	}
+	if err := header.SetNetNS("tenant-1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if name, ok := header.NetNS(); !ok || name != "tenant-1" {
+		t.Fatalf("bad: %q, %v", name, ok)
+	}
+
+	if err := header.SetNetNS("ténant"); err != ErrMalformedTLV {
+		t.Fatalf("bad: %v", err)
+	}
+	// A non-ASCII value sent by the upstream is ignored
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_NETNS, Value: []byte("ténant")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := header.NetNS(); ok {
+		t.Fatalf("bad: non-ASCII namespace")
+	}
+}
@@ -3,6 +3,7 @@ package proxyproto
 import (
 	"net"
 	"runtime"
+	"time"
 )
 
 // Set once during init time
@@ -12,10 +13,76 @@ var (
 
 	// Architecture-specific function pointers
 	// These will be populated by the arch-specific initialization
+	archProfile              string
 	archGetOptimalBufferSize func() int
-	archOptimizeConn         func(net.Conn)
+	archConnTuning           func() ConnTuning
 )
 
+// ConnTuning describes the socket settings applied to TCP connections, see
+// OptimizeConn. Zero values leave the OS defaults, so the zero ConnTuning
+// opts out of any tuning.
+type ConnTuning struct {
+	// NoDelay disables Nagle's algorithm.
+	NoDelay bool
+	// ReadBuffer and WriteBuffer are the sizes of the socket buffers,
+	// SO_RCVBUF and SO_SNDBUF.
+	ReadBuffer  int
+	WriteBuffer int
+	// KeepAlive enables TCP keepalives, every KeepAlivePeriod if set.
+	KeepAlive       bool
+	KeepAlivePeriod time.Duration
+	// QuickAck requests TCP_QUICKACK, which is only effective on Linux.
+	QuickAck bool
+}
+
+// DefaultConnTuning returns the settings tuned for the current architecture
+// and OS which OptimizeConn applies, e.g. to adjust them before passing them
+// to WithConnTuning. The socket buffers may be large: 256 KiB on Linux on
+// amd64 and arm64.
+func DefaultConnTuning() ConnTuning {
+	tuning := archConnTuning()
+	// Disable Nagle's algorithm for reduced latency on all platforms
+	tuning.NoDelay = true
+	return tuning
+}
+
+// Apply applies the settings to conn if it is a TCP connection.
+func (t ConnTuning) Apply(conn net.Conn) {
+	tcpConn, isTCP := conn.(*net.TCPConn)
+	if !isTCP {
+		return
+	}
+
+	if t.NoDelay {
+		tcpConn.SetNoDelay(true)
+	}
+	if t.ReadBuffer > 0 {
+		tcpConn.SetReadBuffer(t.ReadBuffer)
+	}
+	if t.WriteBuffer > 0 {
+		tcpConn.SetWriteBuffer(t.WriteBuffer)
+	}
+	if t.KeepAlive {
+		tcpConn.SetKeepAlive(true)
+		if t.KeepAlivePeriod > 0 {
+			tcpConn.SetKeepAlivePeriod(t.KeepAlivePeriod)
+		}
+	}
+	if t.QuickAck {
+		setQuickAck(tcpConn)
+	}
+}
+
+// WithConnTuning applies the given settings to a connection instead of
+// DefaultConnTuning when passed as option to NewConn(). The zero ConnTuning
+// leaves the socket untouched, e.g. on memory-constrained hosts with many
+// connections, where the default socket buffers add up.
+func WithConnTuning(t ConnTuning) func(*Conn) {
+	return func(c *Conn) {
+		c.tuning = &t
+	}
+}
+
 func init() {
 	// Initialize architecture-specific optimizations
 	initArchSpecific()
@@ -26,9 +93,10 @@ func GetOptimalBufferSize() int {
 	return archGetOptimalBufferSize()
 }
 
-// OptimizeConn applies architecture-specific optimizations to a network connection
+// OptimizeConn applies architecture-specific optimizations to a network
+// connection, the settings of DefaultConnTuning.
 func OptimizeConn(conn net.Conn) {
-	archOptimizeConn(conn)
+	DefaultConnTuning().Apply(conn)
 }
 
 // UpdateExistingInitConn updates the package to use the optimized connection initializer
@@ -4,9 +4,7 @@
 package proxyproto
 
 import (
-	"net"
 	"runtime"
-	"syscall"
 	"time"
 )
 
@@ -26,8 +24,9 @@ const (
 // initArchSpecific initializes architecture-specific optimizations for AMD64
 func initArchSpecific() {
 	// Register architecture-specific functions that may be called from generic code
+	archProfile = "amd64"
 	archGetOptimalBufferSize = amd64GetOptimalBufferSize
-	archOptimizeConn = amd64OptimizeConn
+	archConnTuning = amd64ConnTuning
 }
 
 // amd64GetOptimalBufferSize returns the optimal buffer size for AMD64 architecture
@@ -47,56 +46,29 @@ func amd64GetOptimalBufferSize() int {
 	}
 }
 
-// amd64OptimizeConn applies AMD64-specific optimizations to network connections
-func amd64OptimizeConn(conn net.Conn) {
-	// Apply specific optimizations for AMD64 architecture
-	tcpConn, isTCP := conn.(*net.TCPConn)
-	if !isTCP {
-		return
-	}
-
-	// Disable Nagle's algorithm for all platforms
-	tcpConn.SetNoDelay(true)
-
+// amd64ConnTuning returns the socket settings for AMD64 on the current OS
+func amd64ConnTuning() ConnTuning {
 	// Platform-specific optimizations
 	if OSIsLinux {
-		// Linux-specific optimizations for AMD64
-
-		// Use larger buffers on AMD64 Linux systems
-		tcpConn.SetReadBuffer(archReadBufferSize)
-		tcpConn.SetWriteBuffer(archWriteBufferSize)
-
-		// Set keepalive settings
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
-
-		// Try to set TCP_QUICKACK for AMD64 Linux
-		if fd, err := getFd(tcpConn); err == nil {
-			// TCP_QUICKACK (12) - enable quickack mode
-			syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, 12, 1)
+		// Use larger buffers on AMD64 Linux systems, and try to set
+		// TCP_QUICKACK
+		return ConnTuning{
+			ReadBuffer:      archReadBufferSize,
+			WriteBuffer:     archWriteBufferSize,
+			KeepAlive:       true,
+			KeepAlivePeriod: 30 * time.Second,
+			QuickAck:        true,
 		}
-	} else if runtime.GOOS == "darwin" {
-		// macOS-specific optimizations for AMD64
-		tcpConn.SetReadBuffer(128 * 1024)  // 128KB
-		tcpConn.SetWriteBuffer(128 * 1024) // 128KB
-		tcpConn.SetKeepAlive(true)
-	} else if runtime.GOOS == "windows" {
-		// Windows-specific optimizations for AMD64
-		tcpConn.SetReadBuffer(64 * 1024)  // 64KB
-		tcpConn.SetWriteBuffer(64 * 1024) // 64KB
-		tcpConn.SetKeepAlive(true)
 	}
-}
 
-// getFd extracts the file descriptor from a TCP connection
-func getFd(tcpConn *net.TCPConn) (int, error) {
-	// This is a bit hacky but allows us to set socket options directly
-	// Extract the file descriptor from a TCPConn for low-level socket operations
-	file, err := tcpConn.File()
-	if err != nil {
-		return -1, err
+	switch runtime.GOOS {
+	case "darwin":
+		// macOS-specific optimizations for AMD64
+		return ConnTuning{ReadBuffer: 128 * 1024, WriteBuffer: 128 * 1024, KeepAlive: true}
+	case "windows":
+		// Windows-specific optimizations for AMD64
+		return ConnTuning{ReadBuffer: 64 * 1024, WriteBuffer: 64 * 1024, KeepAlive: true}
+	default:
+		return ConnTuning{}
 	}
-	defer file.Close()
-
-	return int(file.Fd()), nil
 }
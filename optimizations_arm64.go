@@ -4,9 +4,7 @@
 package proxyproto
 
 import (
-	"net"
 	"runtime"
-	"syscall"
 	"time"
 )
 
@@ -26,8 +24,9 @@ const (
 // initArchSpecific initializes architecture-specific optimizations for ARM64
 func initArchSpecific() {
 	// Register architecture-specific functions that may be called from generic code
+	archProfile = "arm64"
 	archGetOptimalBufferSize = arm64GetOptimalBufferSize
-	archOptimizeConn = arm64OptimizeConn
+	archConnTuning = arm64ConnTuning
 }
 
 // arm64GetOptimalBufferSize returns the optimal buffer size for ARM64 architecture
@@ -47,57 +46,30 @@ func arm64GetOptimalBufferSize() int {
 	}
 }
 
-// arm64OptimizeConn applies ARM64-specific optimizations to network connections
-func arm64OptimizeConn(conn net.Conn) {
-	// Apply specific optimizations for ARM64 architecture
-	tcpConn, isTCP := conn.(*net.TCPConn)
-	if !isTCP {
-		return
-	}
-
-	// Disable Nagle's algorithm for all platforms
-	tcpConn.SetNoDelay(true)
-
+// arm64ConnTuning returns the socket settings for ARM64 on the current OS
+func arm64ConnTuning() ConnTuning {
 	// Platform-specific optimizations
 	if OSIsLinux {
-		// Linux-specific optimizations for ARM64
-
 		// ARM64 often benefits from different buffer sizes compared to AMD64
 		// due to different memory access patterns and cache behavior
-		tcpConn.SetReadBuffer(archReadBufferSize)
-		tcpConn.SetWriteBuffer(archWriteBufferSize)
-
-		// Set keepalive settings
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
-
-		// For ARM64 Linux, we can apply specific socket options
-		if fd, err := getFd(tcpConn); err == nil {
-			// TCP_QUICKACK (12) - enable quickack mode
-			syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, 12, 1)
+		return ConnTuning{
+			ReadBuffer:      archReadBufferSize,
+			WriteBuffer:     archWriteBufferSize,
+			KeepAlive:       true,
+			KeepAlivePeriod: 30 * time.Second,
+			QuickAck:        true,
 		}
-	} else if runtime.GOOS == "darwin" {
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
 		// macOS-specific optimizations for ARM64 (Apple Silicon)
 		// Apple Silicon has different memory characteristics
-		tcpConn.SetReadBuffer(128 * 1024)  // 128KB
-		tcpConn.SetWriteBuffer(128 * 1024) // 128KB
-		tcpConn.SetKeepAlive(true)
-	} else if runtime.GOOS == "windows" {
+		return ConnTuning{ReadBuffer: 128 * 1024, WriteBuffer: 128 * 1024, KeepAlive: true}
+	case "windows":
 		// Windows-specific optimizations for ARM64
-		tcpConn.SetReadBuffer(64 * 1024)  // 64KB
-		tcpConn.SetWriteBuffer(64 * 1024) // 64KB
-		tcpConn.SetKeepAlive(true)
-	}
-}
-
-// getFd extracts the file descriptor from a TCP connection
-func getFd(tcpConn *net.TCPConn) (int, error) {
-	// Extract the file descriptor from a TCPConn for low-level socket operations
-	file, err := tcpConn.File()
-	if err != nil {
-		return -1, err
+		return ConnTuning{ReadBuffer: 64 * 1024, WriteBuffer: 64 * 1024, KeepAlive: true}
+	default:
+		return ConnTuning{}
 	}
-	defer file.Close()
-
-	return int(file.Fd()), nil
 }
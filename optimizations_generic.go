@@ -4,7 +4,6 @@
 package proxyproto
 
 import (
-	"net"
 	"runtime"
 	"time"
 )
@@ -25,8 +24,9 @@ const (
 // initArchSpecific initializes architecture-specific optimizations for generic platforms
 func initArchSpecific() {
 	// Register architecture-specific functions that may be called from generic code
+	archProfile = "generic"
 	archGetOptimalBufferSize = genericGetOptimalBufferSize
-	archOptimizeConn = genericOptimizeConn
+	archConnTuning = genericConnTuning
 }
 
 // genericGetOptimalBufferSize returns a reasonable buffer size for unknown architectures
@@ -44,39 +44,21 @@ func genericGetOptimalBufferSize() int {
 	}
 }
 
-// genericOptimizeConn applies basic optimizations to network connections
-// for platforms where we don't have specific tuning
-func genericOptimizeConn(conn net.Conn) {
-	tcpConn, isTCP := conn.(*net.TCPConn)
-	if !isTCP {
-		return
-	}
-
-	// Disable Nagle's algorithm for reduced latency on all platforms
-	tcpConn.SetNoDelay(true)
-
+// genericConnTuning returns basic socket settings for platforms where we
+// don't have specific tuning
+func genericConnTuning() ConnTuning {
 	// Apply conservative optimizations based on OS
 	switch runtime.GOOS {
 	case "linux":
 		// Generic Linux optimizations
-		tcpConn.SetReadBuffer(archReadBufferSize)
-		tcpConn.SetWriteBuffer(archWriteBufferSize)
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
-	case "darwin":
-		// Generic macOS optimizations
-		tcpConn.SetReadBuffer(32 * 1024)  // 32KB
-		tcpConn.SetWriteBuffer(32 * 1024) // 32KB
-		tcpConn.SetKeepAlive(true)
-	case "windows":
-		// Generic Windows optimizations
-		tcpConn.SetReadBuffer(32 * 1024)  // 32KB
-		tcpConn.SetWriteBuffer(32 * 1024) // 32KB
-		tcpConn.SetKeepAlive(true)
+		return ConnTuning{
+			ReadBuffer:      archReadBufferSize,
+			WriteBuffer:     archWriteBufferSize,
+			KeepAlive:       true,
+			KeepAlivePeriod: 30 * time.Second,
+		}
 	default:
-		// For unknown OSes, just apply basic settings
-		tcpConn.SetReadBuffer(32 * 1024)  // 32KB
-		tcpConn.SetWriteBuffer(32 * 1024) // 32KB
-		tcpConn.SetKeepAlive(true)
+		// macOS, Windows and unknown OSes get the same basic settings
+		return ConnTuning{ReadBuffer: 32 * 1024, WriteBuffer: 32 * 1024, KeepAlive: true}
 	}
 }
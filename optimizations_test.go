@@ -0,0 +1,55 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDefaultConnTuning(t *testing.T) {
+	tuning := DefaultConnTuning()
+	if !tuning.NoDelay {
+		t.Fatalf("bad: %+v", tuning)
+	}
+
+	caps := GetCapabilities()
+	if caps.ReadBufferSize != tuning.ReadBuffer || caps.WriteBufferSize != tuning.WriteBuffer {
+		t.Fatalf("bad: %+v", caps)
+	}
+}
+
+func TestListenerConnTuning(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, ConnTuning: &ConnTuning{}}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping"))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	pConn := conn.(*Conn)
+	if pConn.tuning == nil || *pConn.tuning != (ConnTuning{}) {
+		t.Fatalf("bad: %+v", pConn.tuning)
+	}
+	b := make([]byte, 4)
+	if _, err := pConn.Read(b); err != nil || string(b) != "ping" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+	if pConn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", pConn.RemoteAddr())
+	}
+}
@@ -0,0 +1,62 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+)
+
+// ErrNilHeader is returned when writing a nil header.
+var ErrNilHeader = newError(ErrCodeInvalidUse, "proxyproto: nil header")
+
+// WriteProxyHeader writes header to the underlying connection, for proxies
+// which both receive and send the PROXY protocol using the same type. It
+// must be called before anything else is written, as the receiving end
+// expects the header first.
+//
+// It is independent from the header read from the connection, if any. A
+// proxy forwarding the client of an inbound connection in to an outbound
+// one out does, whether or not in had a header:
+//
+//	out.WriteProxyHeader(proxyproto.HeaderProxyFromAddrs(2, in.RemoteAddr(), in.LocalAddr()))
+func (p *Conn) WriteProxyHeader(header *Header) error {
+	if p.conn == nil {
+		return io.EOF
+	}
+	if header == nil {
+		return ErrNilHeader
+	}
+	_, err := header.WriteTo(p.conn)
+	return err
+}
+
+// WriteProxyHeaderAndPayload is like WriteProxyHeader, but writes the first
+// bytes of the payload along with the header, see WriteHeaderAndPayload. It
+// returns the number of bytes of payload written.
+func (p *Conn) WriteProxyHeaderAndPayload(header *Header, payload []byte) (int, error) {
+	if p.conn == nil {
+		return 0, io.EOF
+	}
+	return WriteHeaderAndPayload(p.conn, header, payload)
+}
+
+// WriteHeaderAndPayload writes header followed by payload to w. Both are
+// handed to the kernel in a single vectored write (writev) when w supports
+// it, as *net.TCPConn does, so that they usually leave in the same TCP
+// segment: the receiving end gets the header and the start of the payload
+// at once, and neither waits on a delayed ACK. It returns the number of
+// bytes of payload written.
+func WriteHeaderAndPayload(w io.Writer, header *Header, payload []byte) (int, error) {
+	if header == nil {
+		return 0, ErrNilHeader
+	}
+	buf, err := header.Format()
+	if err != nil {
+		return 0, err
+	}
+
+	bufs := net.Buffers{buf, payload}
+	n, err := bufs.WriteTo(w)
+	// Only count the payload, of which nothing was written if the header
+	// was cut short
+	return max(int(n)-len(buf), 0), err
+}
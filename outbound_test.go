@@ -0,0 +1,99 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestConnWriteProxyHeader(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	out := NewConn(client)
+	defer out.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	go func() {
+		if err := out.WriteProxyHeader(header); err != nil {
+			t.Errorf("err: %v", err)
+		}
+		out.Write([]byte("ping"))
+	}()
+
+	reader := bufio.NewReader(server)
+	got, err := Read(reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !got.EqualsTo(header) {
+		t.Fatalf("bad: %+v", got)
+	}
+	b := make([]byte, 4)
+	if _, err := reader.Read(b); err != nil || string(b) != "ping" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+
+	if err := out.WriteProxyHeader(nil); err != ErrNilHeader {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestWriteHeaderAndPayload(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+	defer pl.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+		out := NewConn(conn)
+		defer out.Close()
+		if n, err := out.WriteProxyHeaderAndPayload(header, []byte("ping")); n != 4 || err != nil {
+			t.Errorf("bad: %d, %v", n, err)
+		}
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	b, err := io.ReadAll(conn)
+	if err != nil || string(b) != "ping" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+	if !conn.(*Conn).ProxyHeader().EqualsTo(header) {
+		t.Fatalf("bad: %+v", conn.(*Conn).ProxyHeader())
+	}
+
+	// Writers without writev get both in turn
+	var buf bytes.Buffer
+	if n, err := WriteHeaderAndPayload(&buf, header, []byte("ping")); n != 4 || err != nil {
+		t.Fatalf("bad: %d, %v", n, err)
+	}
+	reader := bufio.NewReader(&buf)
+	if got, err := Read(reader); err != nil || !got.EqualsTo(header) {
+		t.Fatalf("bad: %+v, %v", got, err)
+	}
+	if rest, _ := io.ReadAll(reader); string(rest) != "ping" {
+		t.Fatalf("bad: %q", rest)
+	}
+	if _, err := WriteHeaderAndPayload(&buf, nil, nil); err != ErrNilHeader {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package proxyproto
+
+import "net"
+
+// ParsePacket decodes the PROXY header at the start of a datagram and
+// returns it along with the payload following it, a slice of b. A datagram
+// without a header fails with ErrNoProxyProtocol and is returned whole as
+// payload; one cut short within the header fails with io.ErrUnexpectedEOF,
+// as datagrams can't be continued.
+func ParsePacket(b []byte) (header *Header, payload []byte, err error) {
+	if len(b) == 0 {
+		return nil, b, ErrNoProxyProtocol
+	}
+	header, offset, err := ExtractHeader(b)
+	if err == ErrNoProxyProtocol {
+		return nil, b, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, b[offset:], nil
+}
+
+// PacketConn wraps a net.PacketConn receiving datagrams prefixed by a PROXY
+// header, such as the ones relayed by a UDP proxy sending version 2 headers
+// with the DGRAM transport protocols. Reads strip the headers and report
+// the source address they carry.
+//
+// The header applies to its datagram alone, and so does the policy: a
+// datagram which is refused, because of its policy, an invalid header or
+// the validator, is dropped silently and the read waits for the next one.
+type PacketConn struct {
+	net.PacketConn
+	// Policy, if set, decides how to treat the header of the datagrams of
+	// each upstream. Datagrams for which it returns an error are dropped.
+	// The default is USE.
+	Policy PolicyFunc
+	// ValidateHeader, if set, drops the datagrams whose header it refuses.
+	ValidateHeader Validator
+	// GRO, if set, makes reads receive many datagrams per syscall on Linux,
+	// coalesced by the kernel with UDP_GRO, which are then returned one by
+	// one, see WriteBatchTo for the sending side. UDP_GRO is enabled by the
+	// first read, and the reads are serialized. Elsewhere, or if the kernel
+	// refuses it, reads are regular ones.
+	GRO bool
+
+	offload packetOffload
+}
+
+// ReadFrom reads a datagram into b after stripping its header, and returns
+// the source address of the header, or the address of the upstream when
+// there is none or it is ignored. Replies must be sent to the upstream
+// rather than that address, see ReadHeaderFrom.
+//
+// The header is read into b along with the payload, so b must have room
+// for both.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, header, upstream, err := c.ReadHeaderFrom(b)
+	if err == nil && header != nil && header.Command == PROXY && header.SourceAddr != nil {
+		return n, header.SourceAddr, nil
+	}
+	return n, upstream, err
+}
+
+// ReadHeaderFrom acts as ReadFrom but returns both the header of the
+// datagram, nil if there is none or the policy ignores it, and the address
+// of the upstream which sent it.
+func (c *PacketConn) ReadHeaderFrom(b []byte) (n int, header *Header, upstream net.Addr, err error) {
+	for {
+		n, upstream, err = c.readDatagram(b)
+		if err != nil {
+			return n, nil, upstream, err
+		}
+
+		policy := USE
+		if c.Policy != nil {
+			if policy, err = c.Policy(upstream); err != nil {
+				continue
+			}
+		}
+		if policy == SKIP {
+			return n, nil, upstream, nil
+		}
+
+		header, payload, err := ParsePacket(b[:n])
+		switch {
+		case err == ErrNoProxyProtocol:
+			if policy == REQUIRE {
+				continue
+			}
+			return n, nil, upstream, nil
+		case err != nil, policy == REJECT:
+			continue
+		}
+		if c.ValidateHeader != nil && c.ValidateHeader(header) != nil {
+			continue
+		}
+
+		n = copy(b, payload)
+		if policy == IGNORE {
+			header = nil
+		}
+		return n, header, upstream, nil
+	}
+}
+
+// AppendToDatagram appends the version 2 header h followed by payload to
+// dst and returns the extended buffer, a datagram ready to be sent to a
+// receiver reading it with ParsePacket or PacketConn. Reusing dst across
+// datagrams avoids allocating. Version 1 headers, which can't carry UDP
+// addresses, are refused with ErrUnknownProxyProtocolVersion.
+func AppendToDatagram(dst []byte, h *Header, payload []byte) ([]byte, error) {
+	if h == nil {
+		return dst, ErrNilHeader
+	}
+	if h.Version != 2 {
+		return dst, ErrUnknownProxyProtocolVersion
+	}
+	b, err := h.appendVersion2(dst)
+	if err != nil {
+		return dst, err
+	}
+	return append(b, payload...), nil
+}
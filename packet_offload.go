@@ -0,0 +1,122 @@
+package proxyproto
+
+import (
+	"net"
+	"sync"
+)
+
+const (
+	// maxGSOSegments is the most datagrams sent in one syscall, the limit
+	// of Linux for UDP_SEGMENT.
+	maxGSOSegments = 64
+	// maxGSOBytes is the most bytes sent in one syscall, the largest UDP
+	// payload over IPv4.
+	maxGSOBytes = 65507
+)
+
+// packetOffload holds the UDP segmentation offload state of a PacketConn.
+type packetOffload struct {
+	// groOnce enables UDP_GRO on the socket on first read, which sets
+	// groSupported if it worked
+	groOnce      sync.Once
+	groSupported bool
+
+	// readMu guards the datagrams received coalesced, returned one by one
+	readMu  sync.Mutex
+	readBuf []byte
+	rest    []byte
+	segSize int
+	from    net.Addr
+
+	// writeMu guards the buffer the datagrams sent at once are gathered
+	// in. gsoRefused is set once the kernel refused UDP_SEGMENT.
+	writeMu    sync.Mutex
+	writeBuf   []byte
+	gsoRefused bool
+}
+
+// readDatagram reads the next datagram into b, from those received
+// coalesced if GRO is enabled.
+func (c *PacketConn) readDatagram(b []byte) (int, net.Addr, error) {
+	if !c.GRO {
+		return c.PacketConn.ReadFrom(b)
+	}
+
+	o := &c.offload
+	o.readMu.Lock()
+	defer o.readMu.Unlock()
+	if len(o.rest) == 0 {
+		if o.readBuf == nil {
+			o.readBuf = make([]byte, maxGSOBytes)
+		}
+		n, segSize, from, handled, err := c.readGRO(o.readBuf)
+		if !handled {
+			return c.PacketConn.ReadFrom(b)
+		}
+		if err != nil {
+			return 0, from, err
+		}
+		if segSize <= 0 || segSize > n {
+			segSize = n
+		}
+		o.rest, o.segSize, o.from = o.readBuf[:n], segSize, from
+	}
+
+	// As for regular reads, a datagram larger than b is truncated
+	segment := o.rest[:min(o.segSize, len(o.rest))]
+	o.rest = o.rest[len(segment):]
+	return copy(b, segment), o.from, nil
+}
+
+// WriteBatchTo sends datagrams to addr, e.g. ones built with
+// AppendToDatagram, and returns how many were sent. On Linux, runs of
+// datagrams of the same size, the last of a run possibly shorter, are sent
+// in a single syscall with UDP_SEGMENT, as relays prepending the same
+// header to payloads of the same size produce. Elsewhere, or if the kernel
+// refuses it, the datagrams are sent one by one.
+func (c *PacketConn) WriteBatchTo(datagrams [][]byte, addr net.Addr) (int, error) {
+	o := &c.offload
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+
+	sent := 0
+	for sent < len(datagrams) {
+		size := len(datagrams[sent])
+		end, total := sent+1, size
+		for end < len(datagrams) && end-sent < maxGSOSegments && size > 0 {
+			next := len(datagrams[end])
+			if next > size || total+next > maxGSOBytes {
+				break
+			}
+			total += next
+			end++
+			if next < size {
+				// Only the last datagram of a run may be shorter
+				break
+			}
+		}
+
+		if end-sent > 1 && !o.gsoRefused {
+			o.writeBuf = o.writeBuf[:0]
+			for _, d := range datagrams[sent:end] {
+				o.writeBuf = append(o.writeBuf, d...)
+			}
+			handled, err := c.writeGSO(o.writeBuf, size, addr)
+			if handled {
+				if err != nil {
+					return sent, err
+				}
+				sent = end
+				continue
+			}
+			o.gsoRefused = true
+		}
+
+		for ; sent < end; sent++ {
+			if _, err := c.PacketConn.WriteTo(datagrams[sent], addr); err != nil {
+				return sent, err
+			}
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,93 @@
+//go:build linux
+// +build linux
+
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Linux constants for UDP segmentation offload, see udp(7)
+const (
+	solUDP     = 17
+	udpSegment = 103
+	udpGRO     = 104
+)
+
+// readGRO reads the datagrams the kernel coalesced into b with UDP_GRO and
+// returns their size, the last one possibly shorter. It reports whether it
+// handled the read: otherwise nothing was read and the caller must read a
+// datagram itself.
+func (c *PacketConn) readGRO(b []byte) (n, segSize int, from net.Addr, handled bool, err error) {
+	udpConn, ok := c.PacketConn.(*net.UDPConn)
+	if !ok {
+		return 0, 0, nil, false, nil
+	}
+	o := &c.offload
+	o.groOnce.Do(func() {
+		rawConn, err := udpConn.SyscallConn()
+		if err != nil {
+			return
+		}
+		rawConn.Control(func(fd uintptr) {
+			o.groSupported = syscall.SetsockoptInt(int(fd), solUDP, udpGRO, 1) == nil
+		})
+	})
+	if !o.groSupported {
+		return 0, 0, nil, false, nil
+	}
+
+	var oob [64]byte
+	n, oobn, _, addr, err := udpConn.ReadMsgUDP(b, oob[:])
+	if err != nil {
+		return n, 0, nil, true, err
+	}
+	return n, groSegmentSize(oob[:oobn]), addr, true, nil
+}
+
+// groSegmentSize returns the size of the datagrams coalesced, from the
+// control messages oob, or 0 if they carry none.
+func groSegmentSize(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == solUDP && msg.Header.Type == udpGRO && len(msg.Data) >= 4 {
+			return int(binary.NativeEndian.Uint32(msg.Data))
+		}
+	}
+	return 0
+}
+
+// writeGSO sends b to addr as datagrams of segSize bytes with UDP_SEGMENT,
+// the last one possibly shorter. It reports whether it handled the write:
+// otherwise nothing was sent and the caller must send the datagrams itself.
+func (c *PacketConn) writeGSO(b []byte, segSize int, addr net.Addr) (bool, error) {
+	udpConn, ok := c.PacketConn.(*net.UDPConn)
+	if !ok {
+		return false, nil
+	}
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return false, nil
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(2))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level, h.Type = solUDP, udpSegment
+	h.SetLen(syscall.CmsgLen(2))
+	binary.NativeEndian.PutUint16(oob[syscall.CmsgLen(0):], uint16(segSize))
+
+	_, _, err := udpConn.WriteMsgUDP(b, oob, udpAddr)
+	switch {
+	case errors.Is(err, syscall.EIO), errors.Is(err, syscall.EINVAL), errors.Is(err, syscall.ENOPROTOOPT), errors.Is(err, syscall.EOPNOTSUPP):
+		// No segmentation offload for this socket or device
+		return false, nil
+	}
+	return true, err
+}
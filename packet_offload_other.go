@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package proxyproto
+
+import "net"
+
+// readGRO never handles the read outside of Linux.
+func (c *PacketConn) readGRO(b []byte) (n, segSize int, from net.Addr, handled bool, err error) {
+	return 0, 0, nil, false, nil
+}
+
+// writeGSO never handles the write outside of Linux.
+func (c *PacketConn) writeGSO(b []byte, segSize int, addr net.Addr) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,175 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParsePacket(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.UDPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.UDPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	parsed, payload, err := ParsePacket(append(raw, "ping"...))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !parsed.EqualsTo(header) || parsed.TransportProtocol != UDPv4 || string(payload) != "ping" {
+		t.Fatalf("bad: %+v, %q", parsed, payload)
+	}
+
+	if _, payload, err := ParsePacket([]byte("ping")); err != ErrNoProxyProtocol || string(payload) != "ping" {
+		t.Fatalf("bad: %q, %v", payload, err)
+	}
+	if _, _, err := ParsePacket(raw[:20]); err == nil {
+		t.Fatalf("bad: truncated header accepted")
+	}
+}
+
+func TestPacketConn(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pc := &PacketConn{
+		PacketConn: server,
+		Policy: func(net.Addr) (Policy, error) {
+			return REQUIRE, nil
+		},
+	}
+	defer pc.Close()
+
+	client, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+
+	source := &net.UDPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000}
+	raw, err := HeaderProxyFromAddrs(2, source, &net.UDPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000}).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The datagram without a header is dropped
+	client.Write([]byte("dropped"))
+	client.Write(append(raw, "ping"...))
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1500)
+	n, addr, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(b[:n]) != "ping" || addr.String() != source.String() {
+		t.Fatalf("bad: %q from %v", b[:n], addr)
+	}
+
+	client.Write(append(raw, "pong"...))
+	n, header, upstream, err := pc.ReadHeaderFrom(b)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(b[:n]) != "pong" || header == nil || upstream.String() != client.LocalAddr().String() {
+		t.Fatalf("bad: %q, %+v from %v", b[:n], header, upstream)
+	}
+}
+
+func TestAppendToDatagram(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.UDPAddr{IP: net.ParseIP("::1"), Port: 1000},
+		&net.UDPAddr{IP: net.ParseIP("::2"), Port: 2000},
+	)
+	if err := header.SetAuthority("example.org"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	buf := make([]byte, 0, 1500)
+	for _, payload := range []string{"first", "second"} {
+		datagram, err := AppendToDatagram(buf[:0], header, []byte(payload))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if &datagram[0] != &buf[:1][0] {
+			t.Fatalf("bad: buffer not reused")
+		}
+		parsed, rest, err := ParsePacket(datagram)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !parsed.EqualsTo(header) || string(rest) != payload {
+			t.Fatalf("bad: %+v, %q", parsed, rest)
+		}
+	}
+
+	v1 := HeaderProxyFromAddrs(1, header.SourceAddr, header.DestinationAddr)
+	if _, err := AppendToDatagram(nil, v1, nil); err != ErrUnknownProxyProtocolVersion {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestPacketConnOffload(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pc := &PacketConn{PacketConn: server, GRO: true}
+	defer pc.Close()
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sender := &PacketConn{PacketConn: client}
+	defer sender.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.UDPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.UDPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	// The first read enables GRO
+	warmUp, err := AppendToDatagram(nil, header, []byte("warm-up"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := sender.WriteBatchTo([][]byte{warmUp}, server.LocalAddr()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1500)
+	if n, _, err := pc.ReadFrom(b); err != nil || string(b[:n]) != "warm-up" {
+		t.Fatalf("bad: %q, %v", b[:n], err)
+	}
+
+	// A run of datagrams of the same size, the last one shorter, then
+	// a larger one which starts another
+	payloads := []string{"ping-00", "ping-01", "ping-02", "ping-03", "ping", "larger ping"}
+	datagrams := make([][]byte, len(payloads))
+	for i, payload := range payloads {
+		if datagrams[i], err = AppendToDatagram(nil, header, []byte(payload)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if n, err := sender.WriteBatchTo(datagrams, server.LocalAddr()); err != nil || n != len(datagrams) {
+		t.Fatalf("bad: %d, %v", n, err)
+	}
+
+	for i, payload := range payloads {
+		n, addr, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if string(b[:n]) != payload || addr.String() != "10.1.1.1:1000" {
+			t.Fatalf("bad: %q from %v", b[:n], addr)
+		}
+		// The kernel coalesces the datagrams sent at once
+		if i == 0 && pc.offload.groSupported && len(pc.offload.rest) == 0 {
+			t.Fatalf("bad: datagrams received one by one")
+		}
+	}
+}
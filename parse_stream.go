@@ -0,0 +1,129 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// parseStreamWindow is the size of the buffer ParseStream reads through,
+// enough for any header.
+const parseStreamWindow = 1 << 17
+
+// ParseStream walks r, a recording of many concatenated headered
+// connections or datagrams, e.g. a replayed log or captured traffic, and
+// calls fn for each PROXY header found with the offset in r of the payload
+// which follows it. The payload runs until the next header, whose offset
+// is the one of the next call minus its length.
+//
+// Headers are searched for by their signature: bytes which look like one
+// without being a valid header are skipped as payload. Walking stops at the
+// end of r, with io.ErrUnexpectedEOF if it ends within a header, or at the
+// first error returned by fn, which is returned.
+func ParseStream(r io.Reader, fn func(header *Header, payloadOffset int64) error) error {
+	br := bufio.NewReaderSize(r, parseStreamWindow)
+	var offset int64
+	for {
+		// Scan what is buffered first, as refilling shifts the buffer
+		buf, err := br.Peek(max(br.Buffered(), 1))
+		if len(buf) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		i := nextSignature(buf)
+		if i < 0 {
+			br.Discard(len(buf))
+			offset += int64(len(buf))
+			continue
+		}
+		br.Discard(i)
+		offset += int64(i)
+
+		var p Parser
+		consumed, done, parseErr := p.Feed(buf[i:])
+		if !done {
+			// The header may be longer than what was buffered
+			if buf, err = br.Peek(parseStreamWindow); err != nil && err != io.EOF {
+				return err
+			}
+			p.Reset()
+			if consumed, done, parseErr = p.Feed(buf); !done {
+				return p.truncated()
+			}
+		}
+		if parseErr != nil {
+			br.Discard(1)
+			offset++
+			continue
+		}
+
+		br.Discard(consumed)
+		offset += int64(consumed)
+		if err := fn(p.Header(), offset); err != nil {
+			return err
+		}
+	}
+}
+
+// ParseBytes acts as ParseStream on data held in memory, scanning it in
+// place rather than through a buffer.
+func ParseBytes(data []byte, fn func(header *Header, payloadOffset int64) error) error {
+	for pos := 0; pos < len(data); {
+		i := nextSignature(data[pos:])
+		if i < 0 {
+			return nil
+		}
+		pos += i
+
+		var p Parser
+		consumed, done, err := p.Feed(data[pos:])
+		if !done {
+			return p.truncated()
+		}
+		if err != nil {
+			pos++
+			continue
+		}
+
+		pos += consumed
+		if err := fn(p.Header(), int64(pos)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncated returns the error of a stream ending while p isn't done:
+// io.ErrUnexpectedEOF within a header, none within what only looks like the
+// start of a signature.
+func (p *Parser) truncated() error {
+	if len(p.buf) < len(p.sig) {
+		return nil
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// nextSignature returns the index of the first signature of either version
+// in b, or of the start of one cut short by the end of b, -1 if there is
+// none.
+func nextSignature(b []byte) int {
+	for i := 0; i < len(b); i++ {
+		j := bytes.IndexAny(b[i:], "\x0D"+string(SIGV1[:1]))
+		if j < 0 {
+			return -1
+		}
+		i += j
+		sig := SIGV1
+		if b[i] == SIGV2[0] {
+			sig = SIGV2
+		}
+		rest := b[i:]
+		if bytes.HasPrefix(rest, sig) || bytes.HasPrefix(sig, rest) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package proxyproto
+
+import (
+	"os"
+	"syscall"
+)
+
+// ParseFile acts as ParseStream on the file at path, which is memory-mapped
+// rather than read, see ParseBytes. On platforms without mmap, the file is
+// read into memory instead.
+func ParseFile(path string, fn func(header *Header, payloadOffset int64) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return &os.PathError{Op: "mmap", Path: path, Err: err}
+	}
+	defer syscall.Munmap(data)
+
+	return ParseBytes(data, fn)
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package proxyproto
+
+import "os"
+
+// ParseFile acts as ParseStream on the file at path, which is memory-mapped
+// rather than read, see ParseBytes. On platforms without mmap, the file is
+// read into memory instead.
+func ParseFile(path string, fn func(header *Header, payloadOffset int64) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return ParseBytes(data, fn)
+}
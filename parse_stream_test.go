@@ -0,0 +1,94 @@
+package proxyproto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseStream(t *testing.T) {
+	v2 := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.3.3.3"), Port: 3000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	v2Bytes, err := v2.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var stream bytes.Buffer
+	stream.WriteString("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nhello")
+	stream.WriteString("PROXY TCP4 10.2.2.2 20.2.2.2 2000 2000\r\nPROXY is not a header\r\n")
+	stream.Write(v2Bytes)
+	stream.WriteString("PROX")
+
+	type found struct {
+		source string
+		offset int64
+	}
+	want := []found{
+		{"10.1.1.1:1000", 40},
+		{"10.2.2.2:2000", 85},
+		{"10.3.3.3:3000", int64(108 + len(v2Bytes))},
+	}
+
+	walkers := map[string]func(func(*Header, int64) error) error{
+		"stream": func(fn func(*Header, int64) error) error {
+			return ParseStream(bytes.NewReader(stream.Bytes()), fn)
+		},
+		"one byte reads": func(fn func(*Header, int64) error) error {
+			return ParseStream(iotest.OneByteReader(bytes.NewReader(stream.Bytes())), fn)
+		},
+		"bytes": func(fn func(*Header, int64) error) error {
+			return ParseBytes(stream.Bytes(), fn)
+		},
+		"file": func(fn func(*Header, int64) error) error {
+			path := filepath.Join(t.TempDir(), "capture")
+			if err := os.WriteFile(path, stream.Bytes(), 0o600); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			return ParseFile(path, fn)
+		},
+	}
+	for name, walk := range walkers {
+		t.Run(name, func(t *testing.T) {
+			var got []found
+			err := walk(func(header *Header, offset int64) error {
+				got = append(got, found{header.SourceAddr.String(), offset})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("bad: %v", got)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("bad: %v, want %v", got[i], want[i])
+				}
+			}
+		})
+	}
+
+	// Errors of the callback and truncated headers stop the walk
+	stop := errors.New("stop")
+	if err := ParseBytes(stream.Bytes(), func(*Header, int64) error { return stop }); err != stop {
+		t.Fatalf("bad: %v", err)
+	}
+	truncated := append([]byte("payload"), v2Bytes[:20]...)
+	if err := ParseStream(bytes.NewReader(truncated), func(*Header, int64) error { return nil }); err != io.ErrUnexpectedEOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := ParseBytes(truncated, func(*Header, int64) error { return nil }); err != io.ErrUnexpectedEOF {
+		t.Fatalf("bad: %v", err)
+	}
+}
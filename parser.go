@@ -0,0 +1,223 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// v2HeaderPrefixLen is the length of the fixed part of a version 2 header:
+// signature, version and command, family and protocol, and length.
+const v2HeaderPrefixLen = 16
+
+// v1HeaderMaxLen is the maximum length of a version 1 header, CRLF included.
+const v1HeaderMaxLen = 107
+
+// Parser parses a PROXY header fed in chunks of any size, for event-driven
+// code which receives bytes as they arrive rather than through a blocking
+// reader. The zero value is ready to use.
+//
+//	var p proxyproto.Parser
+//	consumed, done, err := p.Feed(chunk)
+//
+// Feed never consumes bytes past the end of the header: once done, the
+// unconsumed part of the last chunk is payload.
+type Parser struct {
+	buf    []byte
+	sig    []byte
+	header *Header
+	done   bool
+	err    error
+}
+
+// Feed consumes bytes of b until the header is complete, returning how many
+// were consumed and whether parsing is over. Once done, Header returns the
+// parsed header, or err is the parsing error and further calls return it
+// again.
+//
+// If the data doesn't start with a PROXY signature, err is
+// ErrNoProxyProtocol and nothing of b is consumed: the bytes consumed by
+// previous calls, returned by Buffered, must then be handled as payload
+// along with b.
+func (p *Parser) Feed(b []byte) (consumed int, done bool, err error) {
+	if p.done {
+		return 0, true, p.err
+	}
+
+	if p.sig == nil {
+		if len(b) == 0 {
+			return 0, false, nil
+		}
+		switch b[0] {
+		case SIGV1[0]:
+			p.sig = SIGV1
+		case SIGV2[0]:
+			p.sig = SIGV2
+		default:
+			return 0, true, p.fail(ErrNoProxyProtocol)
+		}
+	}
+
+	// Match the signature as it arrives
+	if len(p.buf) < len(p.sig) {
+		n := min(len(p.sig)-len(p.buf), len(b))
+		if !bytes.Equal(b[:n], p.sig[len(p.buf):len(p.buf)+n]) {
+			return 0, true, p.fail(ErrNoProxyProtocol)
+		}
+		p.buf = append(p.buf, b[:n]...)
+		consumed, b = n, b[n:]
+		if len(p.buf) < len(p.sig) {
+			return consumed, false, nil
+		}
+	}
+
+	var complete bool
+	var n int
+	if len(p.sig) == len(SIGV1) {
+		n, complete = p.feedVersion1(b)
+	} else {
+		n, complete = p.feedVersion2(b)
+	}
+	consumed += n
+	if p.done {
+		return consumed, true, p.err
+	}
+	if !complete {
+		return consumed, false, nil
+	}
+
+	reader := bufio.NewReaderSize(bytes.NewReader(p.buf), len(p.buf))
+	header, err := Read(reader)
+	if err != nil {
+		return consumed, true, p.fail(err)
+	}
+	p.header = header
+	p.done = true
+	return consumed, true, nil
+}
+
+// feedVersion1 buffers b up to the end of the header line.
+func (p *Parser) feedVersion1(b []byte) (int, bool) {
+	window := b[:min(len(b), v1HeaderMaxLen-len(p.buf))]
+	if i := bytes.IndexByte(window, '\n'); i >= 0 {
+		p.buf = append(p.buf, window[:i+1]...)
+		return i + 1, true
+	}
+	p.buf = append(p.buf, window...)
+	if len(p.buf) == v1HeaderMaxLen {
+		p.fail(ErrVersion1HeaderTooLong)
+	}
+	return len(window), false
+}
+
+// feedVersion2 buffers b up to the length announced by the header.
+func (p *Parser) feedVersion2(b []byte) (int, bool) {
+	consumed := 0
+	for {
+		n := min(p.version2Len()-len(p.buf), len(b))
+		p.buf = append(p.buf, b[:n]...)
+		consumed, b = consumed+n, b[n:]
+		// Once the fixed part is complete, the length may ask for more
+		if len(p.buf) == p.version2Len() {
+			return consumed, true
+		}
+		if len(b) == 0 {
+			return consumed, false
+		}
+	}
+}
+
+// version2Len returns the length of the header as far as it is known.
+func (p *Parser) version2Len() int {
+	if len(p.buf) < v2HeaderPrefixLen {
+		return v2HeaderPrefixLen
+	}
+	return v2HeaderPrefixLen + int(binary.BigEndian.Uint16(p.buf[14:v2HeaderPrefixLen]))
+}
+
+// want returns how many more bytes the parser can be fed without reaching
+// past the end of the header.
+func (p *Parser) want() int {
+	switch {
+	case p.sig == nil:
+		return 1
+	case len(p.buf) < len(p.sig):
+		return len(p.sig) - len(p.buf)
+	case len(p.sig) == len(SIGV1):
+		// The end of the line can't be known in advance
+		return 1
+	default:
+		return p.version2Len() - len(p.buf)
+	}
+}
+
+func (p *Parser) fail(err error) error {
+	p.done = true
+	p.err = err
+	return err
+}
+
+// Header returns the parsed header, nil until Feed is done or if it failed.
+func (p *Parser) Header() *Header {
+	return p.header
+}
+
+// Buffered returns the bytes consumed so far.
+func (p *Parser) Buffered() []byte {
+	return p.buf
+}
+
+// Reset clears the parser state to parse another header.
+func (p *Parser) Reset() {
+	*p = Parser{buf: p.buf[:0]}
+}
+
+// ExtractHeader decodes the PROXY header at the start of stream, the
+// reassembled bytes of a TCP flow from the client, e.g. captured with
+// gopacket, and returns it along with the offset at which the payload
+// starts. It is meant for offline analysis: a stream cut short within the
+// header fails with io.ErrUnexpectedEOF, and one without a header with
+// ErrNoProxyProtocol.
+func ExtractHeader(stream []byte) (header *Header, offset int, err error) {
+	var p Parser
+	consumed, done, err := p.Feed(stream)
+	if !done {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return p.Header(), consumed, nil
+}
+
+// ReadHeader reads a PROXY header from r without ever reading past its end,
+// for callers which hand r to another parser afterwards and can't afford the
+// read-ahead of Read. A version 2 header is read by its announced length; a
+// version 1 header is read byte by byte after its signature, so r should be
+// cheap to read from in small pieces.
+//
+// If r doesn't start with a PROXY header, ErrNoProxyProtocol is returned and
+// the bytes read, at most a signature, are lost. If r ends before any byte
+// was read, io.EOF is returned, and io.ErrUnexpectedEOF if it ends within
+// the header.
+func ReadHeader(r io.Reader) (*Header, error) {
+	var p Parser
+	var chunk [v2HeaderPrefixLen]byte
+	for {
+		n := p.want()
+		b := chunk[:min(n, len(chunk))]
+		if n > len(chunk) {
+			b = make([]byte, n)
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF && len(p.buf) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if _, done, err := p.Feed(b); done {
+			return p.Header(), err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestParserFeedByteByByte(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var p Parser
+	for i := range raw {
+		consumed, done, err := p.Feed(raw[i : i+1])
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if consumed != 1 || done != (i == len(raw)-1) {
+			t.Fatalf("bad: byte %d consumed %d, done %v", i, consumed, done)
+		}
+	}
+	if !p.Header().EqualsTo(header) {
+		t.Fatalf("bad: %+v", p.Header())
+	}
+}
+
+func TestParserFeedChunks(t *testing.T) {
+	raw := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nGET / HTTP/1.1\r\n")
+
+	var p Parser
+	consumed, done, err := p.Feed(raw[:3])
+	if consumed != 3 || done || err != nil {
+		t.Fatalf("bad: %d, %v, %v", consumed, done, err)
+	}
+	consumed, done, err = p.Feed(raw[3:])
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !done || consumed != 37 {
+		t.Fatalf("bad: %d, %v", consumed, done)
+	}
+	if payload := raw[3+consumed:]; string(payload) != "GET / HTTP/1.1\r\n" {
+		t.Fatalf("bad: payload %q", payload)
+	}
+	if p.Header().SourceAddr.String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", p.Header().SourceAddr)
+	}
+
+	// Further calls don't consume anything
+	if consumed, done, err := p.Feed(raw); consumed != 0 || !done || err != nil {
+		t.Fatalf("bad: %d, %v, %v", consumed, done, err)
+	}
+}
+
+func TestParserFeedNoProxyProtocol(t *testing.T) {
+	var p Parser
+	if consumed, done, err := p.Feed([]byte("PRO")); consumed != 3 || done || err != nil {
+		t.Fatalf("bad: %d, %v, %v", consumed, done, err)
+	}
+	consumed, done, err := p.Feed([]byte("MPT"))
+	if consumed != 0 || !done || err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %d, %v, %v", consumed, done, err)
+	}
+	if !bytes.Equal(p.Buffered(), []byte("PRO")) {
+		t.Fatalf("bad: %q", p.Buffered())
+	}
+
+	p.Reset()
+	if _, done, err := p.Feed([]byte("GET /")); !done || err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v, %v", done, err)
+	}
+}
+
+func TestParserFeedInvalid(t *testing.T) {
+	var p Parser
+	long := append([]byte("PROXY "), bytes.Repeat([]byte("a"), 200)...)
+	if _, done, err := p.Feed(long); !done || err != ErrVersion1HeaderTooLong {
+		t.Fatalf("bad: %v, %v", done, err)
+	}
+
+	p.Reset()
+	if _, done, err := p.Feed([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000\r\n")); !done || err == nil {
+		t.Fatalf("bad: %v, %v", done, err)
+	}
+}
+
+func TestExtractHeader(t *testing.T) {
+	stream := []byte("PROXY TCP6 ::1 ::2 1000 2000\r\nSSH-2.0-OpenSSH\r\n")
+
+	header, offset, err := ExtractHeader(stream)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if header.TransportProtocol != TCPv6 || string(stream[offset:]) != "SSH-2.0-OpenSSH\r\n" {
+		t.Fatalf("bad: %+v, %d", header, offset)
+	}
+
+	if _, _, err := ExtractHeader(stream[:10]); err != io.ErrUnexpectedEOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, _, err := ExtractHeader([]byte("SSH-2.0-OpenSSH\r\n")); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	v2 := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if err := v2.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	v2Bytes, err := v2.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, stream := range [][]byte{
+		[]byte("PROXY TCP6 ::1 ::2 1000 2000\r\npayload"),
+		append(v2Bytes, "payload"...),
+	} {
+		r := bytes.NewReader(stream)
+		header, err := ReadHeader(r)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if header.SourceAddr.(*net.TCPAddr).Port != 1000 {
+			t.Fatalf("bad: %+v", header)
+		}
+		// Nothing past the header was read
+		if rest, _ := io.ReadAll(r); string(rest) != "payload" {
+			t.Fatalf("bad: %q", rest)
+		}
+	}
+
+	if _, err := ReadHeader(bytes.NewReader(nil)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := ReadHeader(bytes.NewReader(v2Bytes[:20])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := ReadHeader(bytes.NewReader([]byte("GET / HTTP/1.1\r\n"))); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Peek reads the proxy header if needed, then returns the next n bytes of
+// the payload without consuming them, e.g. to tell TLS from plaintext
+// before handing the connection over. It waits for the bytes which aren't
+// buffered yet, up to the read deadline of the connection. If fewer than n
+// bytes are returned, the error tells why: the connection failed, or n is
+// larger than the read buffer, in which case bufio.ErrBufferFull is
+// returned at once along with the bytes already buffered.
+//
+// The bytes are a copy, the buffer holding them being recycled once the
+// connection is closed. Peeking a closed connection returns io.EOF, and one
+// whose header couldn't be read, the error of the header.
+func (p *Conn) Peek(n int) ([]byte, error) {
+	if !p.acquireReader() {
+		return nil, io.EOF
+	}
+	defer p.releaseReader()
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		return nil, p.readErr
+	}
+
+	// Don't wait for bytes which can't all be returned anyway
+	if n > p.bufReader.Size() {
+		b, _ := p.bufReader.Peek(p.bufReader.Buffered())
+		return bytes.Clone(b), bufio.ErrBufferFull
+	}
+	b, err := p.bufReader.Peek(n)
+	return bytes.Clone(b), err
+}
+
+// Buffered reads the proxy header if needed, then returns the number of
+// bytes of the payload already buffered along with it, which Peek returns
+// without waiting.
+func (p *Conn) Buffered() int {
+	if !p.acquireReader() {
+		return 0
+	}
+	defer p.releaseReader()
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		return 0
+	}
+	return p.bufReader.Buffered()
+}
@@ -0,0 +1,63 @@
+package proxyproto
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPeek(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n\x16\x03\x01"))
+		client.Write([]byte("hello"))
+	}()
+
+	conn := NewConn(server)
+	defer conn.Close()
+
+	// Waits for the bytes past those buffered with the header
+	b, err := conn.Peek(5)
+	if err != nil || string(b) != "\x16\x03\x01he" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+	if n := conn.Buffered(); n < 5 {
+		t.Fatalf("bad: %d buffered", n)
+	}
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+
+	// Nothing was consumed
+	all := make([]byte, 8)
+	if _, err := io.ReadFull(conn, all); err != nil || string(all) != "\x16\x03\x01hello" {
+		t.Fatalf("bad: %q, %v", all, err)
+	}
+
+	// More than the read buffer holds
+	go client.Write([]byte("more"))
+	if b, err := conn.Peek(4); err != nil || string(b) != "more" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+	if b, err := conn.Peek(1 << 20); err != bufio.ErrBufferFull || string(b) != "more" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+}
+
+func TestPeekErrors(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 10.1.1.1\r\n"))
+
+	conn := NewConn(server)
+	_, readErr := conn.Read(make([]byte, 1))
+	if _, err := conn.Peek(1); err == nil || err != readErr {
+		t.Fatalf("bad: %v", err)
+	}
+	conn.Close()
+	if _, err := conn.Peek(1); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+}
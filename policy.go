@@ -3,6 +3,8 @@ package proxyproto
 import (
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
 	"strings"
 )
 
@@ -27,6 +29,9 @@ type ConnPolicyFunc func(connPolicyOptions ConnPolicyOptions) (Policy, error)
 type ConnPolicyOptions struct {
 	Upstream   net.Addr
 	Downstream net.Addr
+	// Conn is the connection itself, as accepted by the underlying
+	// listener, if known.
+	Conn net.Conn
 }
 
 // Policy defines how a connection with a PROXY header address is treated.
@@ -204,3 +209,64 @@ func IgnoreProxyHeaderNotOnInterface(allowedIP net.IP) ConnPolicyFunc {
 		return IGNORE, nil
 	}
 }
+
+var (
+	// privateNetworks are the private IPv4 ranges of RFC 1918 and the
+	// unique local IPv6 addresses of RFC 4193, as for netip.Addr.IsPrivate.
+	privateNetworks = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("fc00::/7"),
+	}
+	loopbackNetworks = []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+)
+
+// TrustedSubnetsPolicy returns a ConnPolicyFunc which applies the trusted
+// policy to connections from an upstream within one of cidrs, and the
+// untrusted one to the others, e.g. USE and REJECT to only take the PROXY
+// headers of known load balancers into account. IPv4-mapped IPv6 upstream
+// addresses match IPv4 prefixes. Connections from upstreams without an IP
+// address are rejected with an error.
+func TrustedSubnetsPolicy(cidrs []netip.Prefix, trusted, untrusted Policy) ConnPolicyFunc {
+	cidrs = slices.Clone(cidrs)
+	return func(connOpts ConnPolicyOptions) (Policy, error) {
+		return subnetsPolicy(cidrs, connOpts.Upstream, trusted, untrusted)
+	}
+}
+
+// subnetsPolicy returns trusted if upstream is within one of cidrs,
+// untrusted otherwise.
+func subnetsPolicy(cidrs []netip.Prefix, upstream net.Addr, trusted, untrusted Policy) (Policy, error) {
+	ip, err := ipFromAddr(upstream)
+	if err != nil {
+		// something is wrong with the source IP, better reject the connection
+		return REJECT, err
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	addr = addr.Unmap()
+
+	for _, cidr := range cidrs {
+		if cidr.Contains(addr) {
+			return trusted, nil
+		}
+	}
+	return untrusted, nil
+}
+
+// PrivateNetworksOnly returns a TrustedSubnetsPolicy using the PROXY headers
+// of upstreams with a private address, in 10.0.0.0/8, 172.16.0.0/12,
+// 192.168.0.0/16 or fc00::/7, and rejecting the ones of other upstreams.
+func PrivateNetworksOnly() ConnPolicyFunc {
+	return TrustedSubnetsPolicy(privateNetworks, USE, REJECT)
+}
+
+// LoopbackOnly returns a TrustedSubnetsPolicy using the PROXY headers of
+// upstreams on the same host, with a loopback address, and rejecting the
+// ones of other upstreams, e.g. for a load balancer running as a sidecar.
+func LoopbackOnly() ConnPolicyFunc {
+	return TrustedSubnetsPolicy(loopbackNetworks, USE, REJECT)
+}
@@ -0,0 +1,189 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PolicySource provides a ConnPolicyFunc which may change over time, e.g.
+// when trust configuration is reloaded. See Listener.PolicySource.
+type PolicySource interface {
+	// Get returns the policy currently in effect.
+	Get() ConnPolicyFunc
+	// Changed returns a channel which is closed the next time the policy
+	// changes. Call Changed again after each notification to keep watching.
+	Changed() <-chan struct{}
+}
+
+// DefaultPolicyFileInterval is how often a FilePolicySource checks its file
+// for changes, if no interval is given.
+var DefaultPolicyFileInterval = 5 * time.Second
+
+// FilePolicySource is a PolicySource built from a file listing the trusted
+// upstream IP addresses and IP ranges, one per line. Empty lines and lines
+// starting with '#' are ignored.
+//
+// The file is checked for changes periodically and reloaded when its size or
+// modification time changes. If the new content is invalid, the previous
+// policy stays in effect and the error is reported by Err.
+type FilePolicySource struct {
+	path      string
+	trusted   Policy
+	untrusted Policy
+
+	policy atomic.Pointer[ConnPolicyFunc]
+
+	mu      sync.Mutex
+	changed chan struct{}
+	modTime time.Time
+	size    int64
+	err     error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFilePolicySource loads the trusted addresses from path and starts
+// watching it. Connections from a listed upstream are given the trusted
+// policy, all others the untrusted one. The file must exist and be valid.
+//
+// Close must be called to stop watching the file.
+func NewFilePolicySource(path string, interval time.Duration, trusted, untrusted Policy) (*FilePolicySource, error) {
+	if interval <= 0 {
+		interval = DefaultPolicyFileInterval
+	}
+
+	s := &FilePolicySource{
+		path:      path,
+		trusted:   trusted,
+		untrusted: untrusted,
+		changed:   make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	go s.watch(interval)
+	return s, nil
+}
+
+// Get returns the policy built from the last valid version of the file.
+func (s *FilePolicySource) Get() ConnPolicyFunc {
+	return *s.policy.Load()
+}
+
+// Changed returns a channel which is closed the next time the file is
+// successfully reloaded.
+func (s *FilePolicySource) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+// Err returns the error of the last reload attempt, if any.
+func (s *FilePolicySource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Reload reads the file and replaces the policy if its content is valid.
+func (s *FilePolicySource) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return s.setErr(err)
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return s.setErr(err)
+	}
+
+	var allowed []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowed = append(allowed, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return s.setErr(err)
+	}
+
+	allowFrom, err := parse(allowed)
+	if err != nil {
+		return s.setErr(err)
+	}
+
+	policy := ConnPolicyFunc(func(connOpts ConnPolicyOptions) (Policy, error) {
+		upstreamIP, err := ipFromAddr(connOpts.Upstream)
+		if err != nil {
+			// something is wrong with the source IP, better reject the connection
+			return REJECT, err
+		}
+
+		for _, allowFrom := range allowFrom {
+			if allowFrom(upstreamIP) {
+				return s.trusted, nil
+			}
+		}
+
+		return s.untrusted, nil
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy.Store(&policy)
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.err = nil
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return nil
+}
+
+// Close stops watching the file. The last loaded policy remains available.
+func (s *FilePolicySource) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *FilePolicySource) setErr(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	return err
+}
+
+func (s *FilePolicySource) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(s.path)
+		if err != nil {
+			s.setErr(err)
+			continue
+		}
+
+		s.mu.Lock()
+		unchanged := info.ModTime().Equal(s.modTime) && info.Size() == s.size
+		s.mu.Unlock()
+		if !unchanged {
+			s.Reload()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package proxyproto
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilePolicySourceReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trusted.txt")
+	if err := os.WriteFile(path, []byte("# load balancers\n10.0.0.0/30\n\n10.0.1.1\n"), 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	source, err := NewFilePolicySource(path, 10*time.Millisecond, USE, REJECT)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer source.Close()
+
+	checkPolicy := func(ip string, expected Policy) {
+		t.Helper()
+		policy, err := source.Get()(ConnPolicyOptions{Upstream: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1000}})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if policy != expected {
+			t.Fatalf("bad: expected policy %v for %s, got %v", expected, ip, policy)
+		}
+	}
+
+	checkPolicy("10.0.0.2", USE)
+	checkPolicy("10.0.1.1", USE)
+	checkPolicy("10.0.2.1", REJECT)
+
+	changed := source.Changed()
+	if err := os.WriteFile(path, []byte("10.0.2.0/24\n"), 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("err: policy change wasn't notified")
+	}
+
+	checkPolicy("10.0.0.2", REJECT)
+	checkPolicy("10.0.2.1", USE)
+
+	// An invalid file keeps the previous policy in effect
+	if err := os.WriteFile(path, []byte("not an address\n"), 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := source.Reload(); err == nil {
+		t.Fatal("err: expected invalid file to fail reloading")
+	}
+	if source.Err() == nil {
+		t.Fatal("err: expected reload error to be reported")
+	}
+	checkPolicy("10.0.2.1", USE)
+}
+
+func TestNewFilePolicySourceMissingFile(t *testing.T) {
+	if _, err := NewFilePolicySource(filepath.Join(t.TempDir(), "missing"), 0, USE, REJECT); err == nil {
+		t.Fatal("err: expected an error for a missing file")
+	}
+}
+
+func TestListenerUsesPolicySource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trusted.txt")
+	if err := os.WriteFile(path, []byte("127.0.0.1\n"), 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	source, err := NewFilePolicySource(path, time.Hour, REQUIRE, USE)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer source.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, PolicySource: source}
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("ping"))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 4)); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: expected %v, got %v", ErrNoProxyProtocol, err)
+	}
+}
@@ -2,6 +2,7 @@ package proxyproto
 
 import (
 	"net"
+	"net/netip"
 	"testing"
 )
 
@@ -249,3 +250,50 @@ func TestIgnoreProxyHeaderNotOnInterface(t *testing.T) {
 	}
 
 }
+
+func TestTrustedSubnetsPolicy(t *testing.T) {
+	p := TrustedSubnetsPolicy([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}, USE, IGNORE)
+
+	for _, tc := range []struct {
+		upstream string
+		want     Policy
+	}{
+		{"10.1.2.3:1000", USE},
+		{"[::ffff:10.1.2.3]:1000", USE},
+		{"[2001:db8::1]:1000", USE},
+		{"11.1.2.3:1000", IGNORE},
+		{"[2001:db9::1]:1000", IGNORE},
+	} {
+		upstream, _ := net.ResolveTCPAddr("tcp", tc.upstream)
+		if got, err := p(ConnPolicyOptions{Upstream: upstream}); got != tc.want || err != nil {
+			t.Fatalf("bad: %v, %v for %s", got, err, tc.upstream)
+		}
+	}
+
+	if got, err := p(ConnPolicyOptions{Upstream: failingAddr{}}); got != REJECT || err == nil {
+		t.Fatalf("bad: %v, %v", got, err)
+	}
+}
+
+func TestPrivateNetworksAndLoopbackOnly(t *testing.T) {
+	for _, tc := range []struct {
+		upstream          string
+		private, loopback Policy
+	}{
+		{"192.168.1.1:1000", USE, REJECT},
+		{"172.31.0.1:1000", USE, REJECT},
+		{"[fd00::1]:1000", USE, REJECT},
+		{"127.0.0.1:1000", REJECT, USE},
+		{"[::1]:1000", REJECT, USE},
+		{"8.8.8.8:1000", REJECT, REJECT},
+	} {
+		upstream, _ := net.ResolveTCPAddr("tcp", tc.upstream)
+		opts := ConnPolicyOptions{Upstream: upstream}
+		if got, _ := PrivateNetworksOnly()(opts); got != tc.private {
+			t.Fatalf("bad: %v for %s", got, tc.upstream)
+		}
+		if got, _ := LoopbackOnly()(opts); got != tc.loopback {
+			t.Fatalf("bad: %v for %s", got, tc.upstream)
+		}
+	}
+}
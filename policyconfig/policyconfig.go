@@ -0,0 +1,354 @@
+// Package policyconfig builds the policy, validator and settings of a
+// proxyproto.Listener from a JSON document, so that operators can configure
+// which upstreams are trusted without writing Go.
+//
+// A document looks like:
+//
+//	{
+//		"default": "ignore",
+//		"rules": [
+//			{"policy": "use", "sources": ["10.0.0.0/8", "192.168.1.1"]},
+//			{"policy": "skip", "sources": ["127.0.0.1"]}
+//		],
+//		"ports": [
+//			{"port": 8443, "default": "reject", "rules": [
+//				{"policy": "require", "sources": ["10.1.0.0/16"]}
+//			]}
+//		],
+//		"required_tlvs": ["authority", "0xEA"],
+//		"read_header_timeout": "5s",
+//		"min_header_rate": 512,
+//		"min_header_rate_grace": "1s"
+//	}
+//
+// Policies are "use", "ignore", "reject", "require" and "skip". The policy of
+// a connection is the one of the first rule whose sources contain the
+// upstream address: the rules of the port the connection was accepted on
+// come first, then the global ones. Without a match, the default of the port
+// is used if set, the global default otherwise, and "use" if neither is set.
+package policyconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iqhive/go-proxyproto"
+)
+
+// Document is the JSON representation of a configuration.
+type Document struct {
+	Default            string   `json:"default,omitempty"`
+	Rules              []Rule   `json:"rules,omitempty"`
+	Ports              []Port   `json:"ports,omitempty"`
+	RequiredTLVs       []string `json:"required_tlvs,omitempty"`
+	ReadHeaderTimeout  Duration `json:"read_header_timeout,omitempty"`
+	MinHeaderRate      int      `json:"min_header_rate,omitempty"`
+	MinHeaderRateGrace Duration `json:"min_header_rate_grace,omitempty"`
+}
+
+// Rule gives a policy to the upstreams within sources, which are IP
+// addresses or CIDR ranges.
+type Rule struct {
+	Policy  string   `json:"policy"`
+	Sources []string `json:"sources"`
+}
+
+// Port overrides the rules and the default policy for the connections
+// accepted on a local port.
+type Port struct {
+	Port    int    `json:"port"`
+	Default string `json:"default,omitempty"`
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1.5s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string, see time.ParseDuration.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Bundle holds what a Document configures. Apply sets it on a Listener.
+type Bundle struct {
+	ConnPolicy proxyproto.ConnPolicyFunc
+	// Validate is nil if no TLV is required.
+	Validate           proxyproto.Validator
+	ReadHeaderTimeout  time.Duration
+	MinHeaderRate      int
+	MinHeaderRateGrace time.Duration
+}
+
+// Apply sets the bundle on l, replacing its policy. It may be called while
+// l is running, see proxyproto.Listener.UpdateOptions.
+func (b *Bundle) Apply(l *proxyproto.Listener) {
+	l.UpdateOptions(func(o *proxyproto.ListenerOptions) {
+		o.Policy = nil
+		o.ConnPolicy = b.ConnPolicy
+		o.ValidateHeader = b.Validate
+		o.ReadHeaderTimeout = b.ReadHeaderTimeout
+		o.MinHeaderRate = b.MinHeaderRate
+		o.MinHeaderRateGrace = b.MinHeaderRateGrace
+	})
+}
+
+// Load reads and compiles the document at path.
+func Load(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and compiles a JSON document. Unknown fields are errors, so
+// that typos don't go unnoticed.
+func Parse(data []byte) (*Bundle, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("policyconfig: %w", err)
+	}
+	return doc.Compile()
+}
+
+// Compile checks the document and builds its bundle.
+func (doc *Document) Compile() (*Bundle, error) {
+	def, err := parsePolicy(doc.Default, proxyproto.USE)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := compileRules(doc.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make(map[int]portRules, len(doc.Ports))
+	for _, p := range doc.Ports {
+		if p.Port <= 0 || p.Port > 65535 {
+			return nil, fmt.Errorf("policyconfig: invalid port %d", p.Port)
+		}
+		if _, ok := ports[p.Port]; ok {
+			return nil, fmt.Errorf("policyconfig: port %d configured twice", p.Port)
+		}
+		portDef, err := parsePolicy(p.Default, def)
+		if err != nil {
+			return nil, err
+		}
+		own, err := compileRules(p.Rules)
+		if err != nil {
+			return nil, err
+		}
+		// The global rules apply after the port's own
+		ports[p.Port] = portRules{rules: append(own, rules...), def: portDef}
+	}
+
+	required, err := parseTLVTypes(doc.RequiredTLVs)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ReadHeaderTimeout < 0 || doc.MinHeaderRate < 0 || doc.MinHeaderRateGrace < 0 {
+		return nil, fmt.Errorf("policyconfig: timeouts and rates can't be negative")
+	}
+
+	global := portRules{rules: rules, def: def}
+	b := &Bundle{
+		ConnPolicy: func(opts proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+			if r, ok := ports[portOf(opts.Downstream)]; ok {
+				return r.policy(opts.Upstream), nil
+			}
+			return global.policy(opts.Upstream), nil
+		},
+		ReadHeaderTimeout:  time.Duration(doc.ReadHeaderTimeout),
+		MinHeaderRate:      doc.MinHeaderRate,
+		MinHeaderRateGrace: time.Duration(doc.MinHeaderRateGrace),
+	}
+	if len(required) > 0 {
+		b.Validate = requireTLVs(required)
+	}
+	return b, nil
+}
+
+type rule struct {
+	policy   proxyproto.Policy
+	prefixes []netip.Prefix
+}
+
+type portRules struct {
+	rules []rule
+	def   proxyproto.Policy
+}
+
+func (r portRules) policy(upstream net.Addr) proxyproto.Policy {
+	ip, ok := addrOf(upstream)
+	if !ok {
+		return r.def
+	}
+	for _, rule := range r.rules {
+		for _, prefix := range rule.prefixes {
+			if prefix.Contains(ip) {
+				return rule.policy
+			}
+		}
+	}
+	return r.def
+}
+
+func compileRules(rules []Rule) ([]rule, error) {
+	compiled := make([]rule, 0, len(rules))
+	for _, r := range rules {
+		if r.Policy == "" {
+			return nil, fmt.Errorf("policyconfig: rule without a policy")
+		}
+		policy, err := parsePolicy(r.Policy, proxyproto.USE)
+		if err != nil {
+			return nil, err
+		}
+		prefixes := make([]netip.Prefix, 0, len(r.Sources))
+		for _, source := range r.Sources {
+			prefix, err := parseSource(source)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		compiled = append(compiled, rule{policy: policy, prefixes: prefixes})
+	}
+	return compiled, nil
+}
+
+func parseSource(source string) (netip.Prefix, error) {
+	if strings.Contains(source, "/") {
+		prefix, err := netip.ParsePrefix(source)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("policyconfig: invalid source %q: %w", source, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(source)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("policyconfig: invalid source %q: %w", source, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+var policies = map[string]proxyproto.Policy{
+	"use":     proxyproto.USE,
+	"ignore":  proxyproto.IGNORE,
+	"reject":  proxyproto.REJECT,
+	"require": proxyproto.REQUIRE,
+	"skip":    proxyproto.SKIP,
+}
+
+func parsePolicy(name string, def proxyproto.Policy) (proxyproto.Policy, error) {
+	if name == "" {
+		return def, nil
+	}
+	policy, ok := policies[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("policyconfig: unknown policy %q", name)
+	}
+	return policy, nil
+}
+
+var tlvTypes = map[string]proxyproto.PP2Type{
+	"alpn":      proxyproto.PP2_TYPE_ALPN,
+	"authority": proxyproto.PP2_TYPE_AUTHORITY,
+	"crc32c":    proxyproto.PP2_TYPE_CRC32C,
+	"unique_id": proxyproto.PP2_TYPE_UNIQUE_ID,
+	"ssl":       proxyproto.PP2_TYPE_SSL,
+	"netns":     proxyproto.PP2_TYPE_NETNS,
+}
+
+// parseTLVTypes accepts TLV names, see tlvTypes, and numeric types such as
+// "0xEA".
+func parseTLVTypes(names []string) ([]proxyproto.PP2Type, error) {
+	types := make([]proxyproto.PP2Type, 0, len(names))
+	for _, name := range names {
+		if t, ok := tlvTypes[strings.ToLower(name)]; ok {
+			types = append(types, t)
+			continue
+		}
+		v, err := strconv.ParseUint(name, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("policyconfig: unknown TLV type %q", name)
+		}
+		types = append(types, proxyproto.PP2Type(v))
+	}
+	return types, nil
+}
+
+// MissingTLVError is returned by the validator of a Bundle when a required
+// TLV is absent from the header.
+type MissingTLVError struct {
+	Type proxyproto.PP2Type
+}
+
+func (e *MissingTLVError) Error() string {
+	return fmt.Sprintf("policyconfig: required TLV 0x%02x missing from PROXY header", byte(e.Type))
+}
+
+func requireTLVs(required []proxyproto.PP2Type) proxyproto.Validator {
+	return func(header *proxyproto.Header) error {
+		tlvs, err := header.TLVs()
+		if err != nil {
+			return err
+		}
+	next:
+		for _, t := range required {
+			for _, tlv := range tlvs {
+				if tlv.Type == t {
+					continue next
+				}
+			}
+			return &MissingTLVError{Type: t}
+		}
+		return nil
+	}
+}
+
+func addrOf(addr net.Addr) (netip.Addr, bool) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	case *net.UDPAddr:
+		ip, ok := netip.AddrFromSlice(a.IP)
+		return ip.Unmap(), ok
+	}
+	return netip.Addr{}, false
+}
+
+func portOf(addr net.Addr) int {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.Port
+	case *net.UDPAddr:
+		return a.Port
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package policyconfig_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iqhive/go-proxyproto"
+	"github.com/iqhive/go-proxyproto/policyconfig"
+)
+
+const document = `{
+	"default": "ignore",
+	"rules": [
+		{"policy": "use", "sources": ["10.0.0.0/8", "192.168.1.1"]},
+		{"policy": "skip", "sources": ["127.0.0.1"]}
+	],
+	"ports": [
+		{"port": 8443, "default": "reject", "rules": [
+			{"policy": "require", "sources": ["10.1.0.0/16"]}
+		]}
+	],
+	"required_tlvs": ["authority", "0xEA"],
+	"read_header_timeout": "5s",
+	"min_header_rate": 512,
+	"min_header_rate_grace": "1s"
+}`
+
+func tcpAddr(ip string, port int) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: port}
+}
+
+func TestParse(t *testing.T) {
+	b, err := policyconfig.Parse([]byte(document))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b.ReadHeaderTimeout != 5*time.Second || b.MinHeaderRate != 512 || b.MinHeaderRateGrace != time.Second {
+		t.Fatalf("bad: %+v", b)
+	}
+
+	tests := []struct {
+		upstream string
+		port     int
+		want     proxyproto.Policy
+	}{
+		{"10.2.3.4", 80, proxyproto.USE},
+		{"192.168.1.1", 80, proxyproto.USE},
+		{"192.168.1.2", 80, proxyproto.IGNORE},
+		{"127.0.0.1", 80, proxyproto.SKIP},
+		{"::ffff:127.0.0.1", 80, proxyproto.SKIP},
+		{"10.1.2.3", 8443, proxyproto.REQUIRE},
+		{"10.2.3.4", 8443, proxyproto.USE},
+		{"192.168.1.2", 8443, proxyproto.REJECT},
+	}
+	for _, tt := range tests {
+		policy, err := b.ConnPolicy(proxyproto.ConnPolicyOptions{
+			Upstream:   tcpAddr(tt.upstream, 1000),
+			Downstream: tcpAddr("127.0.0.1", tt.port),
+		})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if policy != tt.want {
+			t.Fatalf("bad: %s on port %d got %v, want %v", tt.upstream, tt.port, policy, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b, err := policyconfig.Parse([]byte(document))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	header := &proxyproto.Header{Version: 2, Command: proxyproto.LOCAL, TransportProtocol: proxyproto.UNSPEC}
+	header.SetTLVs([]proxyproto.TLV{{Type: proxyproto.PP2_TYPE_AUTHORITY, Value: []byte("example.org")}})
+
+	var missing *policyconfig.MissingTLVError
+	if err := b.Validate(header); !errors.As(err, &missing) || missing.Type != 0xEA {
+		t.Fatalf("bad: %v", err)
+	}
+
+	header.SetTLVs([]proxyproto.TLV{
+		{Type: proxyproto.PP2_TYPE_AUTHORITY, Value: []byte("example.org")},
+		{Type: 0xEA, Value: []byte{0x01}},
+	})
+	if err := b.Validate(header); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLoadAndApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"default": "require", "read_header_timeout": "2s"}`), 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := policyconfig.Load(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l := &proxyproto.Listener{Policy: func(net.Addr) (proxyproto.Policy, error) { return proxyproto.USE, nil }}
+	b.Apply(l)
+	opts := l.Options()
+	if opts.Policy != nil || opts.ConnPolicy == nil || opts.ValidateHeader != nil || opts.ReadHeaderTimeout != 2*time.Second {
+		t.Fatalf("bad: %+v", opts)
+	}
+	if policy, _ := opts.ConnPolicy(proxyproto.ConnPolicyOptions{Upstream: tcpAddr("10.0.0.1", 1000)}); policy != proxyproto.REQUIRE {
+		t.Fatalf("bad: %v", policy)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"default": "trust"}`,
+		`{"rules": [{"policy": "use", "sources": ["10.0.0.0/33"]}]}`,
+		`{"rules": [{"sources": ["10.0.0.1"]}]}`,
+		`{"ports": [{"port": 0}]}`,
+		`{"ports": [{"port": 80}, {"port": 80}]}`,
+		`{"required_tlvs": ["authorty"]}`,
+		`{"read_header_timeout": 5}`,
+		`{"read_header_timeout": "-1s"}`,
+		`{"defualt": "use"}`,
+	} {
+		if _, err := policyconfig.Parse([]byte(doc)); err == nil {
+			t.Fatalf("bad: %s accepted", doc)
+		}
+	}
+}
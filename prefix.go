@@ -0,0 +1,49 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+)
+
+// WithPrefix makes a connection read prefix before the bytes of the
+// underlying connection when passed as option to NewConn(), e.g. the bytes
+// a server consumed while sniffing the protocol of the connection before
+// deciding to hand it over. The header is parsed from prefix followed by the
+// connection, and what's left of prefix past the header is returned by Read
+// first. The bytes are copied.
+func WithPrefix(prefix []byte) func(*Conn) {
+	return func(c *Conn) {
+		c.prefix.rest = bytes.Clone(prefix)
+		// A version 1 header must be received in a single read
+		c.prefix.join = (bytes.HasPrefix(prefix, SIGV1) || bytes.HasPrefix(SIGV1, prefix)) &&
+			bytes.IndexByte(prefix, '\n') < 0
+	}
+}
+
+// prefixReader sits between the connection and its buffered reader,
+// returning the bytes given to WithPrefix before those of the connection.
+type prefixReader struct {
+	rest []byte
+	next io.Reader
+	// join reads from next along with the end of the prefix, for prefixes
+	// ending within a version 1 header
+	join bool
+}
+
+func (r *prefixReader) Read(b []byte) (int, error) {
+	if len(r.rest) == 0 {
+		return r.next.Read(b)
+	}
+	n := copy(b, r.rest)
+	r.rest = r.rest[n:]
+	if r.join && len(r.rest) == 0 && n < len(b) {
+		m, err := r.next.Read(b[n:])
+		return n + m, err
+	}
+	return n, nil
+}
+
+// pending reports whether some of the prefix hasn't been read yet.
+func (r *prefixReader) pending() bool {
+	return len(r.rest) > 0
+}
@@ -0,0 +1,129 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWithPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		live   string
+	}{
+		{"partial header", "PROXY TCP", "4 10.1.1.1 20.2.2.2 1000 2000\r\nping"},
+		{"whole header", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", "ping"},
+		{"header and payload", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\npi", "ng"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write([]byte(tt.live))
+
+			prefix := []byte(tt.prefix)
+			conn := NewConn(server, WithPrefix(prefix), WithMinHeaderRate(1, time.Minute))
+			defer conn.Close()
+			// The prefix was copied
+			copy(prefix, "XXXXX")
+
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(conn, b); err != nil || string(b) != "ping" {
+				t.Fatalf("bad: %q, %v", b, err)
+			}
+			if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+				t.Fatalf("bad: %v", conn.RemoteAddr())
+			}
+		})
+	}
+}
+
+func TestWithPrefixNoHeader(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte(" / HTTP/1.1\r\n"))
+
+	conn := NewConn(server, WithPrefix([]byte("GET")))
+	defer conn.Close()
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "GET / HTTP/1.1\r\n" {
+		t.Fatalf("bad: %q, %v", b, err)
+	}
+	if conn.ProxyHeader() != nil {
+		t.Fatalf("bad: unexpected header")
+	}
+}
+
+func TestWithPrefixDetach(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConn(server, WithPrefix([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping")))
+	raw, buffered, err := conn.Detach()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer raw.Close()
+	if string(buffered) != "ping" {
+		t.Fatalf("bad: %q", buffered)
+	}
+}
+
+func TestWithPrefixLargerThanReader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	dial := func() (net.Conn, net.Conn) {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server, err := l.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return client, server
+	}
+
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	prefix := append([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"), payload...)
+	for _, tc := range []struct {
+		name string
+		copy func(dst net.Conn, src *Conn) (int64, error)
+	}{
+		{"write to", func(dst net.Conn, src *Conn) (int64, error) { return src.WriteTo(dst) }},
+		{"io copy", func(dst net.Conn, src *Conn) (int64, error) { return io.Copy(dst, src) }},
+		{"zero copy", func(dst net.Conn, src *Conn) (int64, error) { return ZeroCopy(src, dst) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := dial()
+			dst, backend := dial()
+			defer backend.Close()
+			go func() {
+				client.Write([]byte("live"))
+				client.Close()
+			}()
+			received := make(chan []byte, 1)
+			go func() {
+				b, _ := io.ReadAll(backend)
+				received <- b
+			}()
+
+			src := NewConn(server, WithPrefix(prefix))
+			defer src.Close()
+			want := append(bytes.Clone(payload), "live"...)
+			if n, err := tc.copy(dst, src); err != nil || n != int64(len(want)) {
+				t.Fatalf("bad: %d, %v", n, err)
+			}
+			dst.Close()
+			if got := <-received; !bytes.Equal(got, want) {
+				t.Fatalf("bad: received %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
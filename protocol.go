@@ -3,9 +3,10 @@ package proxyproto
 import (
 	"bufio"
 	"errors"
-	"fmt"
 	"io"
+	"log"
 	"net"
+	"net/netip"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -21,7 +22,7 @@ var (
 
 	// ErrInvalidUpstream should be returned when an upstream connection address
 	// is not trusted, and therefore is invalid.
-	ErrInvalidUpstream = fmt.Errorf("proxyproto: upstream connection address not trusted for PROXY information")
+	ErrInvalidUpstream = newError(ErrCodePolicyReject, "proxyproto: upstream connection address not trusted for PROXY information")
 
 	// bufferPool is a pool of reusable buffers to reduce memory allocations
 	bufferPool = sync.Pool{
@@ -45,6 +46,10 @@ var (
 
 	// Platform optimization flags
 	isLinux = runtime.GOOS == "linux"
+
+	// Pool usage counters, see GetPoolStats
+	readersAcquired atomic.Uint64
+	readersReleased atomic.Uint64
 )
 
 // getOptimalBufferSize returns the optimal buffer size for the platform
@@ -71,6 +76,7 @@ func putBuffer(b *[]byte) {
 func getReader(r io.Reader) *bufio.Reader {
 	br := readerPool.Get().(*bufio.Reader)
 	br.Reset(r)
+	readersAcquired.Add(1)
 	return br
 }
 
@@ -78,6 +84,36 @@ func getReader(r io.Reader) *bufio.Reader {
 func putReader(br *bufio.Reader) {
 	br.Reset(nil)
 	readerPool.Put(br)
+	readersReleased.Add(1)
+}
+
+// PoolStats describes the usage of the package's pooled resources.
+type PoolStats struct {
+	// ReadersAcquired is the number of bufio.Readers taken from the pool.
+	ReadersAcquired uint64
+	// ReadersReleased is the number of bufio.Readers returned to the pool.
+	ReadersReleased uint64
+	// ReadersOutstanding is the number of readers currently owned by
+	// connections. A value that keeps growing points at Conns which are
+	// never closed.
+	ReadersOutstanding int64
+	// PooledConns is the number of Conns taken from their pool by
+	// NewPooledConn which haven't been returned to it yet.
+	PooledConns int64
+}
+
+// GetPoolStats returns a snapshot of the pooled resource counters.
+func GetPoolStats() PoolStats {
+	// Load released first so a concurrent acquire/release pair can't make
+	// the outstanding count negative
+	released := readersReleased.Load()
+	acquired := readersAcquired.Load()
+	return PoolStats{
+		ReadersAcquired:    acquired,
+		ReadersReleased:    released,
+		ReadersOutstanding: int64(acquired - released),
+		PooledConns:        pooledConns.Load(),
+	}
 }
 
 // Listener is used to wrap an underlying listener,
@@ -88,15 +124,169 @@ func putReader(br *bufio.Reader) {
 // is set, a default of 10s will be used. This can be disabled by setting the
 // timeout to < 0.
 //
-// Only one of Policy or ConnPolicy should be provided. If both are provided then
-// a panic would occur during accept.
+// Only one of Policy, ConnPolicy or PolicySource should be provided. If more
+// than one is provided then a panic would occur during accept.
+//
+// The fields covered by ListenerOptions can be changed while the listener
+// runs with UpdateOptions. The others, e.g. Registry, Clock or ConnTuning,
+// must not be changed once Accept has been called.
 type Listener struct {
 	Listener net.Listener
 	// Deprecated: use ConnPolicyFunc instead. This will be removed in future release.
-	Policy            PolicyFunc
-	ConnPolicy        ConnPolicyFunc
-	ValidateHeader    Validator
-	ReadHeaderTimeout time.Duration
+	Policy     PolicyFunc
+	ConnPolicy ConnPolicyFunc
+	// PolicySource, if set, provides the ConnPolicyFunc applied to each
+	// accepted connection, allowing it to change while the listener runs.
+	PolicySource   PolicySource
+	ValidateHeader Validator
+	// ValidateUpstreamHeader, if set, validates headers along with the
+	// address of the upstream which sent them, see ClaimedSourceValidator.
+	ValidateUpstreamHeader UpstreamValidator
+	ReadHeaderTimeout      time.Duration
+	// KeepLocalAddr makes accepted connections report the address of their
+	// socket as LocalAddr, see the KeepLocalAddr option.
+	KeepLocalAddr bool
+	// PreserveTimeoutErrors makes accepted connections return the error of
+	// their read header timeout, see the PreserveTimeoutError option.
+	PreserveTimeoutErrors bool
+	// HeaderPolicy, if set, decides whether to accept the connections
+	// once their header has been read, see HeaderPolicyFunc.
+	HeaderPolicy HeaderPolicyFunc
+	// HeaderTransforms, if set, are applied in order to the headers of
+	// accepted connections, see WithHeaderTransforms.
+	HeaderTransforms []HeaderTransform
+	// SNIPolicy, if set, is consulted after the PROXY header has been read
+	// with the server name of the TLS ClientHello that follows it. Only set
+	// it on listeners whose clients speak TLS first: the ClientHello is
+	// waited for until the read header timeout expires.
+	SNIPolicy SNIPolicyFunc
+	// FailureLimiter, if set, records header failures of accepted
+	// connections per upstream IP address. Connections from banned sources
+	// are closed by Accept without being returned.
+	FailureLimiter *FailureLimiter
+	// MinHeaderRate, if > 0, is the minimum rate in bytes per second at
+	// which accepted connections must send the header once
+	// MinHeaderRateGrace (DefaultMinHeaderRateGrace if unset) has elapsed.
+	// See WithMinHeaderRate.
+	MinHeaderRate      int
+	MinHeaderRateGrace time.Duration
+	// Enricher, if set, is applied to the header of each accepted
+	// connection, see Conn.Enrichment.
+	Enricher Enricher
+	// Clock, if set, measures the read header timeout and minimum header
+	// rate of accepted connections instead of the system clock.
+	Clock Clock
+	// OnError, if set, is called with the upstream address and the error of
+	// each connection refused: by the policy or the FailureLimiter in
+	// Accept, which closes them without returning them, or when reading
+	// the header, e.g. a malformed header or one rejected by a validator.
+	// Upstreams going away before sending anything aren't reported. It must
+	// not block, as it runs in Accept and on the first read.
+	OnError func(addr net.Addr, err error)
+	// RejectResponse, if set, is written to the upstreams of the
+	// connections refused by the policy in Accept, and of those whose
+	// header is refused, see the WithRejectResponse option.
+	RejectResponse []byte
+	// ErrorLog, if set, receives the errors logged by Serve instead of
+	// log.Default().
+	ErrorLog *log.Logger
+	// DisableV1 and DisableV2 stop accepted connections from looking for
+	// the signature of the given protocol version, see the DisableV1 option.
+	DisableV1 bool
+	DisableV2 bool
+	// AllowedVersions, if set, restricts the protocol versions of the
+	// headers of accepted connections, see the WithAllowedVersions option.
+	AllowedVersions Versions
+	// AllowedCommands, if set, restricts the commands of the headers of
+	// accepted connections, see the WithAllowedCommands option.
+	AllowedCommands []ProtocolVersionAndCommand
+	// AllowedFamilies, if set, restricts the address families and
+	// protocols of the headers of accepted connections, see the
+	// WithAllowedFamilies option. The refusals are reported to OnError.
+	AllowedFamilies []AddressFamilyAndProtocol
+	// HardenedMode applies the strictest checks to the headers of accepted
+	// connections, see the HardenedMode option.
+	HardenedMode bool
+	// StrictTLVs refuses the headers of accepted connections whose TLVs
+	// don't follow the spec to the letter, see the StrictTLVs option.
+	StrictTLVs bool
+	// MaxTLVBytes and MaxTLVCount bound the TLVs of the headers of accepted
+	// connections, see the WithTLVLimits option. Zero means no limit.
+	MaxTLVBytes int
+	MaxTLVCount int
+	// MsgZeroCopyThreshold, if positive, makes the writes of at least that
+	// many bytes to accepted connections use MSG_ZEROCOPY, see the
+	// WithMsgZeroCopy option.
+	MsgZeroCopyThreshold int
+	// ConnTuning, if set, replaces DefaultConnTuning for accepted
+	// connections, see the WithConnTuning option. Setting it to the zero
+	// ConnTuning leaves their sockets untouched.
+	ConnTuning *ConnTuning
+	// Registry, if set, tracks the accepted connections by client, see
+	// WithRegistry.
+	Registry *Registry
+	// IPv6Zone, if set, is the zone of the link-local IPv6 addresses in the
+	// headers of accepted connections, see the WithIPv6Zone option.
+	IPv6Zone string
+	// RejectUnspecifiedAddresses refuses headers of accepted connections
+	// with a zero source port or an unspecified address, see the
+	// RejectUnspecifiedAddresses option.
+	RejectUnspecifiedAddresses bool
+	// HeaderReadHook, if set, is called with the stats of each accepted
+	// connection once its header has been handled.
+	HeaderReadHook HeaderReadHook
+	// HeaderTracer, if set, traces the handling of the header of each
+	// accepted connection, see WithHeaderTracer.
+	HeaderTracer HeaderTracer
+	// Workers, if > 0, is the number of goroutines handling the connections
+	// accepted by Serve, which queue up when all are busy. Otherwise, each
+	// connection gets its own goroutine.
+	Workers int
+	// MaxPending, if > 0, is the number of connections accepted by Serve
+	// and not done with, queued or being handled, above which Serve pauses
+	// accepting, or closes new connections if ShedLoad is set.
+	MaxPending int
+	// MaxConns, if > 0, is the number of live connections wrapped in a Conn,
+	// accepted and not closed yet, above which Accept waits for one to be
+	// closed, leaving new connections in the backlog of the underlying
+	// listener, or closes new connections if ShedLoad is set. This bounds
+	// the memory used by connections sending their header slowly, or none.
+	MaxConns int
+	ShedLoad bool
+	// TrackConns makes the listener keep track of the connections wrapped in
+	// a Conn it accepted, until they are closed or detached, for Shutdown
+	// to wait for them and Conns to count them. It is implied by MaxConns.
+	// Otherwise, accepting takes no lock and the listener keeps no
+	// reference to the connections it returned.
+	TrackConns bool
+	// PoolConns recycles the connections once closed, see NewPooledConn for
+	// the restrictions this puts on their use.
+	PoolConns bool
+	// EagerHeaders, if > 0, makes Accept read and validate the headers in
+	// the background, up to EagerHeaders at once, and only return the
+	// connections whose header was handled successfully, so that a server
+	// handing them to a pool or only calling RemoteAddr doesn't block on
+	// them later. The failing connections are closed, and reported to the
+	// FailureLimiter and HeaderReadHook as usual.
+	EagerHeaders int
+
+	options   atomic.Pointer[ListenerOptions]
+	optionsMu sync.Mutex
+
+	eagerOnce sync.Once
+	eager     atomic.Pointer[eagerAccept]
+
+	connsMu   sync.Mutex
+	conns     map[*Conn]struct{}
+	drained   chan struct{} // closed once conns is empty, see Shutdown
+	connFreed *sync.Cond    // signaled when a conn is untracked, see MaxConns
+	stopped   bool          // set by Close
+
+	stats listenerStats
+
+	pending atomic.Int64
+	active  atomic.Int64
+	shed    atomic.Uint64
 }
 
 // Conn is used to wrap and underlying connection which
@@ -104,16 +294,53 @@ type Listener struct {
 // return the address of the client instead of the proxy address. Each connection
 // will have its own readHeaderTimeout and readDeadline set by the Accept() call.
 type Conn struct {
-	readDeadline      atomic.Value // time.Time
-	once              sync.Once
-	readErr           error
-	conn              net.Conn
-	bufReader         *bufio.Reader
-	reader            io.Reader
-	header            *Header
-	ProxyHeaderPolicy Policy
-	Validate          Validator
-	readHeaderTimeout time.Duration
+	readDeadline       atomic.Value // time.Time
+	once               sync.Once
+	readErr            error
+	readErrCode        ErrorCode
+	conn               net.Conn
+	bufReader          *bufio.Reader
+	prefix             prefixReader
+	readerRefs         atomic.Int32 // pins bufReader, see acquireReader
+	closed             atomic.Bool
+	detached           atomic.Bool
+	header             *Header
+	ProxyHeaderPolicy  Policy
+	Validate           Validator
+	ValidateUpstream   UpstreamValidator
+	SNIPolicy          SNIPolicyFunc
+	Enricher           Enricher
+	enrichment         map[string]any
+	readHeaderTimeout  time.Duration
+	preserveTimeout    bool
+	keepLocalAddr      bool
+	lazyHeader         *lazyHeader
+	failures           *FailureLimiter
+	headerRate         *headerRateReader
+	clock              Clock
+	headerDeadline     clockDeadline
+	cancel             headerCancel
+	parseOpts          parseOptions
+	rejectUnspecified  bool
+	pooled             bool
+	recycledByServe    bool // see closeServed
+	headerReadHook     HeaderReadHook
+	headerReadDuration time.Duration
+	headerDoneMu       sync.Mutex
+	headerDone         chan struct{}
+	headerFinished     bool
+	registry           *Registry
+	registryClient     netip.Addr // guarded by registry.mu
+	registryID         string     // guarded by registry.mu
+	msgZeroCopy        *msgZeroCopy
+	tuning             *ConnTuning
+	headerPolicy       HeaderPolicyFunc
+	headerTransforms   []HeaderTransform
+	rejectResponse     []byte
+	listener           *Listener // that accepted the connection
+	tracked            bool      // by the listener, see TrackConns
+	headerVersion      byte      // of the header received, if any
+	tracer             HeaderTracer
 }
 
 // Validator receives a header and decides whether it is a valid one
@@ -129,6 +356,24 @@ func ValidateHeader(v Validator) func(*Conn) {
 	}
 }
 
+// DisableV1 stops a connection from looking for a version 1 header when
+// passed as option to NewConn(), for fleets where upstreams only send
+// version 2. A version 1 header is then handled as if no header was sent:
+// it is refused under the REQUIRE policy and left in the data otherwise.
+func DisableV1() func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.disableV1 = true
+	}
+}
+
+// DisableV2 stops a connection from looking for a version 2 header when
+// passed as option to NewConn(), see DisableV1.
+func DisableV2() func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.disableV2 = true
+	}
+}
+
 // SetReadHeaderTimeout sets the readHeaderTimeout for a connection when passed as option to NewConn()
 func SetReadHeaderTimeout(t time.Duration) func(*Conn) {
 	return func(c *Conn) {
@@ -138,37 +383,108 @@ func SetReadHeaderTimeout(t time.Duration) func(*Conn) {
 	}
 }
 
+// PreserveTimeoutError makes a connection return the net.Error of its read
+// header timeout when passed as option to NewConn(), instead of handling the
+// timeout as if no header was sent, so that network stalls don't go
+// unnoticed under the USE policy. Its code is ErrCodeTimeout whatever the
+// policy.
+func PreserveTimeoutError() func(*Conn) {
+	return func(c *Conn) {
+		c.preserveTimeout = true
+	}
+}
+
+// KeepLocalAddr makes LocalAddr return the address of the socket when passed
+// as option to NewConn(), instead of the destination address of the header,
+// e.g. for servers routing connections by the port they were accepted on.
+// RemoteAddr still returns the source address of the header.
+func KeepLocalAddr() func(*Conn) {
+	return func(c *Conn) {
+		c.keepLocalAddr = true
+	}
+}
+
 // Accept waits for and returns the next valid connection to the listener.
 func (p *Listener) Accept() (net.Conn, error) {
+	if p.EagerHeaders > 0 {
+		return p.acceptEager()
+	}
+	return p.acceptConn()
+}
+
+// acceptConn accepts the next connection and wraps it, without reading its
+// header.
+func (p *Listener) acceptConn() (net.Conn, error) {
 	for {
+		if !p.ShedLoad && !p.waitForConnSlot() {
+			return nil, net.ErrClosed
+		}
+
 		// Get the underlying connection
 		conn, err := p.Listener.Accept()
 		if err != nil {
 			return nil, err
 		}
+		p.stats.accepted.Add(1)
 
-		// Apply platform-specific optimizations immediately
-		InitConn(conn)
+		if p.ShedLoad && p.connsFull() {
+			p.shed.Add(1)
+			conn.Close()
+			continue
+		}
+
+		// Drop connections from sources banned for failing too often
+		if p.FailureLimiter != nil && p.FailureLimiter.Banned(conn.RemoteAddr()) {
+			p.stats.policyRejected.Add(1)
+			p.reportError(conn.RemoteAddr(), ErrSourceBanned)
+			conn.Close()
+			continue
+		}
+
+		// Apply platform-specific optimizations immediately, unless tuned
+		// otherwise
+		tuning := DefaultConnTuning()
+		if p.ConnTuning != nil {
+			tuning = *p.ConnTuning
+		}
+		tuning.Apply(conn)
+
+		// Options may be swapped concurrently, stick to one version of them
+		opts := p.Options()
 
 		proxyHeaderPolicy := USE
-		if p.Policy != nil && p.ConnPolicy != nil {
+		if opts.Policy != nil && opts.ConnPolicy != nil {
 			panic("only one of policy or connpolicy must be provided.")
 		}
 
+		connPolicy := opts.ConnPolicy
+		if p.PolicySource != nil {
+			if opts.Policy != nil || opts.ConnPolicy != nil {
+				panic("only one of policy, connpolicy or policysource must be provided.")
+			}
+			connPolicy = p.PolicySource.Get()
+		}
+
 		// Fast path for policy determination
 		var policyErr error
-		if p.Policy != nil || p.ConnPolicy != nil {
-			if p.Policy != nil {
-				proxyHeaderPolicy, policyErr = p.Policy(conn.RemoteAddr())
+		if opts.Policy != nil || connPolicy != nil {
+			if opts.Policy != nil {
+				proxyHeaderPolicy, policyErr = opts.Policy(conn.RemoteAddr())
 			} else {
-				proxyHeaderPolicy, policyErr = p.ConnPolicy(ConnPolicyOptions{
+				proxyHeaderPolicy, policyErr = connPolicy(ConnPolicyOptions{
 					Upstream:   conn.RemoteAddr(),
 					Downstream: conn.LocalAddr(),
+					Conn:       conn,
 				})
 			}
 
 			if policyErr != nil {
 				// can't decide the policy, we can't accept the connection
+				p.stats.policyRejected.Add(1)
+				p.reportError(conn.RemoteAddr(), policyErr)
+				if opts.RejectResponse != nil {
+					writeRejectResponse(conn, opts.RejectResponse)
+				}
 				conn.Close()
 
 				if errors.Is(policyErr, ErrInvalidUpstream) {
@@ -181,26 +497,67 @@ func (p *Listener) Accept() (net.Conn, error) {
 
 			// Handle a connection as a regular one - fast path return
 			if proxyHeaderPolicy == SKIP {
+				p.stats.noProxy.Add(1)
 				return conn, nil
 			}
 		}
 
 		// Create a new connection with our optimized reader
-		newConn := NewConn(
+		newConnFunc := NewConn
+		if p.PoolConns {
+			newConnFunc = NewPooledConn
+		}
+		newConn := newConnFunc(
 			conn,
 			WithPolicy(proxyHeaderPolicy),
-			ValidateHeader(p.ValidateHeader),
+			ValidateHeader(opts.ValidateHeader),
+			ValidateUpstreamHeader(opts.ValidateUpstreamHeader),
+			WithSNIPolicy(opts.SNIPolicy),
+			WithMinHeaderRate(opts.MinHeaderRate, opts.MinHeaderRateGrace),
+			WithEnricher(opts.Enricher),
+			WithHeaderPolicy(opts.HeaderPolicy),
+			WithHeaderTransforms(opts.HeaderTransforms...),
+			WithRejectResponse(opts.RejectResponse),
+			WithClock(p.Clock),
+			// Already tuned above
+			WithConnTuning(ConnTuning{}),
 		)
+		newConn.parseOpts = parseOptions{
+			disableV1:   opts.DisableV1,
+			disableV2:   opts.DisableV2,
+			versions:    opts.AllowedVersions,
+			commands:    opts.AllowedCommands,
+			families:    opts.AllowedFamilies,
+			zone:        p.IPv6Zone,
+			hardened:    opts.HardenedMode,
+			strictTLVs:  opts.StrictTLVs,
+			maxTLVBytes: opts.MaxTLVBytes,
+			maxTLVCount: opts.MaxTLVCount,
+		}
+		newConn.rejectUnspecified = opts.RejectUnspecifiedAddresses
+		newConn.preserveTimeout = p.PreserveTimeoutErrors
+		newConn.keepLocalAddr = p.KeepLocalAddr
+		newConn.registry = p.Registry
+		newConn.headerReadHook = p.HeaderReadHook
+		newConn.tracer = p.HeaderTracer
+		if p.MsgZeroCopyThreshold > 0 {
+			WithMsgZeroCopy(p.MsgZeroCopyThreshold)(newConn)
+		}
 
 		// If the ReadHeaderTimeout for the listener is unset, use the default timeout.
 		// This avoids a time.Duration comparison which can be expensive
-		readHeaderTimeout := p.ReadHeaderTimeout
+		readHeaderTimeout := opts.ReadHeaderTimeout
 		if readHeaderTimeout == 0 {
 			readHeaderTimeout = DefaultReadHeaderTimeout
 		}
 
 		// Set the readHeaderTimeout of the new conn to the value of the listener
 		newConn.readHeaderTimeout = readHeaderTimeout
+		newConn.failures = p.FailureLimiter
+		newConn.listener = p
+		if p.TrackConns || p.MaxConns > 0 {
+			p.track(newConn)
+		}
 
 		return newConn, nil
 	}
@@ -208,9 +565,20 @@ func (p *Listener) Accept() (net.Conn, error) {
 
 // Close closes the underlying listener.
 func (p *Listener) Close() error {
+	if e := p.eager.Load(); e != nil {
+		e.close()
+	}
+	p.stopWaitingForConns()
 	return p.Listener.Close()
 }
 
+// reportError passes the error of a connection refused to OnError, if set.
+func (p *Listener) reportError(addr net.Addr, err error) {
+	if p.OnError != nil {
+		p.OnError(addr, err)
+	}
+}
+
 // Addr returns the underlying listener's network address.
 func (p *Listener) Addr() net.Addr {
 	return p.Listener.Addr()
@@ -226,22 +594,41 @@ func InitConn(conn net.Conn) {
 // NewConn is used to wrap a net.Conn that may be speaking
 // the proxy protocol into a proxyproto.Conn
 func NewConn(conn net.Conn, opts ...func(*Conn)) *Conn {
-	// Apply platform-specific optimizations to the connection
-	InitConn(conn)
+	return initConn(new(Conn), conn, opts)
+}
 
+// initConn sets up pConn, a zero Conn, to wrap conn.
+func initConn(pConn *Conn, conn net.Conn, opts []func(*Conn)) *Conn {
 	// Use reader from pool instead of creating a new one
 	br := getReader(conn)
 
-	pConn := &Conn{
-		bufReader: br,
-		reader:    io.MultiReader(br, conn),
-		conn:      conn,
-	}
+	pConn.bufReader = br
+	pConn.conn = conn
+	// The connection itself holds the first reference to the pooled reader,
+	// which is dropped by Close
+	pConn.readerRefs.Store(1)
 
 	for _, opt := range opts {
 		opt(pConn)
 	}
 
+	// Read the prefix first, from whatever reader the options set up
+	if pConn.prefix.pending() {
+		pConn.prefix.next = conn
+		if pConn.headerRate != nil {
+			pConn.prefix.next = pConn.headerRate
+		}
+		br.Reset(&pConn.prefix)
+	}
+
+	// Apply platform-specific optimizations to the connection, unless
+	// tuned otherwise
+	if pConn.tuning != nil {
+		pConn.tuning.Apply(conn)
+	} else {
+		InitConn(conn)
+	}
+
 	return pConn
 }
 
@@ -249,30 +636,131 @@ func NewConn(conn net.Conn, opts ...func(*Conn)) *Conn {
 // the initial scan. If there is an error parsing the header,
 // it is returned and the socket is closed.
 func (p *Conn) Read(b []byte) (int, error) {
+	// Pin the pooled reader so a concurrent Close can't return it to the
+	// pool while this read is using it
+	if !p.acquireReader() {
+		return 0, io.EOF
+	}
+	defer p.releaseReader()
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		return 0, p.readErr
+	}
+
+	// Drain the bytes buffered along with the header and the rest of the
+	// prefix first, then read from the connection directly
+	if p.bufReader.Buffered() > 0 || p.prefix.pending() {
+		return p.bufReader.Read(b)
+	}
+	return p.conn.Read(b)
+}
+
+// readHeaderOnce reads the proxy header the first time it is called and
+// records the outcome in readErr.
+func (p *Conn) readHeaderOnce() {
+	handled := false
 	p.once.Do(func() {
+		handled = true
+		defer p.finishHeader()
+
+		if !p.acquireReader() {
+			p.readErr = io.EOF
+			return
+		}
+		defer p.releaseReader()
+
+		if p.tracer != nil {
+			if end := p.tracer.StartHeader(p); end != nil {
+				defer func() { end(p.headerTrace()) }()
+			}
+		}
+
+		clock := clockOrSystem(p.clock)
+		start := clock.Now()
 		p.readErr = p.readHeader()
+		p.headerReadDuration = clock.Now().Sub(start)
+		if cancelErr := p.endHeaderRead(); cancelErr != nil {
+			// Interrupted by EnsureHeader, whatever was read is unusable
+			p.header = nil
+			p.readErr, p.readErrCode = cancelErr, ErrCodeNone
+		}
+		if p.readErr != nil && p.readErrCode == ErrCodeNone {
+			p.readErrCode = CodeOf(p.readErr)
+		}
+
+		if p.readErr == nil && p.header != nil && p.Enricher != nil {
+			p.enrichment = p.Enricher(p.header)
+		}
+
+		if p.readErr == nil && p.registry != nil {
+			if p.readErr = p.registry.add(p); p.readErr != nil {
+				p.readErrCode = CodeOf(p.readErr)
+			}
+		}
 
-		// After reading the header, optimize the reader setup for zero-copy
-		if p.readErr == nil && p.bufReader != nil {
-			// If there's no error and no data left in the buffer reader,
-			// we can bypass the MultiReader entirely and read directly from conn
-			if p.bufReader.Buffered() == 0 {
-				// Replace reader with direct conn for zero-copy reads
-				p.reader = p.conn
+		if p.rejectResponse != nil && rejectsWithResponse(p.readErrCode) {
+			writeRejectResponse(p.conn, p.rejectResponse)
+		}
+
+		// Report the outcome to the listener's failure limiter. A peer
+		// going away before sending anything isn't held against it.
+		if p.failures != nil {
+			if p.readErr == nil {
+				p.failures.Success(p.conn.RemoteAddr())
+			} else if p.readErr != io.EOF {
+				p.failures.Failure(p.conn.RemoteAddr())
+			}
+		}
+		if p.listener != nil {
+			p.listener.stats.recordHeader(p)
+			if p.readErr != nil && p.readErr != io.EOF {
+				p.listener.reportError(p.conn.RemoteAddr(), p.readErr)
 			}
 		}
 	})
 
-	if p.readErr != nil {
-		return 0, p.readErr
+	// The hook may use the connection, so it is called once the header
+	// has been handled
+	if handled && p.headerReadHook != nil {
+		p.headerReadHook(p, ConnStats{
+			HeaderReadDuration: p.headerReadDuration,
+			ErrorCode:          p.readErrCode,
+		})
 	}
+}
 
-	// Forward to the optimized reader
-	if p.reader == nil {
-		return 0, io.EOF
-		// return 0, io.ErrClosedPipe
+// acquireReader takes a reference on the pooled bufio.Reader, preventing it
+// from being returned to the pool while in use. It returns false once the
+// reader has been released, i.e. after Close.
+func (p *Conn) acquireReader() bool {
+	for {
+		refs := p.readerRefs.Load()
+		if refs <= 0 {
+			return false
+		}
+		if p.readerRefs.CompareAndSwap(refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// releaseReader drops a reference taken by acquireReader, or the initial one
+// owned by the connection. The last reference returns the reader to the pool.
+func (p *Conn) releaseReader() {
+	if p.readerRefs.Add(-1) != 0 {
+		return
+	}
+
+	// Return the bufio.Reader to the pool if it exists
+	if p.bufReader != nil {
+		putReader(p.bufReader)
+		p.bufReader = nil
+	}
+
+	if p.pooled && !p.detached.Load() && !p.recycledByServe {
+		p.recycle()
 	}
-	return p.reader.Read(b)
 }
 
 // Write wraps original conn.Write with optimizations for large writes
@@ -282,6 +770,19 @@ func (p *Conn) Write(b []byte) (int, error) {
 		// return 0, io.ErrClosedPipe
 	}
 
+	// The first payload carries the lazy header
+	if p.lazyHeader != nil {
+		if n, written, err := p.writeLazyHeader(b); written || err != nil {
+			return n, err
+		}
+	}
+
+	if z := p.msgZeroCopy; z != nil && len(b) >= z.threshold {
+		if n, handled, err := z.write(p.conn, b); handled {
+			return n, err
+		}
+	}
+
 	// Fast path for small writes
 	if len(b) < 4096 {
 		return p.conn.Write(b)
@@ -298,25 +799,51 @@ func (p *Conn) Write(b []byte) (int, error) {
 	}
 }
 
-// Close wraps original conn.Close
+// Close wraps original conn.Close. It is safe to call Close more than once
+// and concurrently with Read: the pooled reader is released exactly once,
+// after any in-flight read has returned.
 func (p *Conn) Close() error {
-	// Return the bufio.Reader to the pool if it exists
-	if p.bufReader != nil {
-		putReader(p.bufReader)
-		p.bufReader = nil
+	// A pooled Conn may be recycled as soon as the reader is released
+	conn := p.conn
+	if p.closed.CompareAndSwap(false, true) {
+		p.finishHeader()
+		if p.registry != nil {
+			p.registry.remove(p)
+		}
+		if p.tracked {
+			p.listener.untrack(p)
+		}
+		p.releaseReader()
+	} else if p.detached.Load() {
+		// The underlying connection belongs to whoever detached it
+		return nil
 	}
 
-	// Clear references to help with garbage collection
-	p.reader = nil
-
 	// Close the underlying connection
-	return p.conn.Close()
+	return conn.Close()
+}
+
+// ErrorCode returns the code classifying the error met while reading the
+// proxy header, or ErrCodeNone if there was none. Unlike CodeOf, it tells
+// validator rejections and timeouts under the REQUIRE policy apart.
+func (p *Conn) ErrorCode() ErrorCode {
+	p.readHeaderOnce()
+	return p.readErrCode
+}
+
+// HeaderError returns the error met while reading the proxy header, reading
+// it first if needed, or nil if there was none. It tells a connection which
+// didn't send a header, for which both it and ProxyHeader return nil, from
+// one whose header was refused. EnsureHeader returns both at once.
+func (p *Conn) HeaderError() error {
+	p.readHeaderOnce()
+	return p.readErr
 }
 
 // ProxyHeader returns the proxy protocol header, if any. If an error occurs
-// while reading the proxy header, nil is returned.
+// while reading the proxy header, nil is returned, see HeaderError.
 func (p *Conn) ProxyHeader() *Header {
-	p.once.Do(func() { p.readErr = p.readHeader() })
+	p.readHeaderOnce()
 	return p.header
 }
 
@@ -325,9 +852,13 @@ func (p *Conn) ProxyHeader() *Header {
 // the socket server. In case an error happens on reading the
 // proxy header the original LocalAddr is returned, not the one
 // from the proxy header even if the proxy header itself is
-// syntactically correct.
+// syntactically correct. With the KeepLocalAddr option, the original
+// LocalAddr is always returned, without reading the header.
 func (p *Conn) LocalAddr() net.Addr {
-	p.once.Do(func() { p.readErr = p.readHeader() })
+	if p.keepLocalAddr {
+		return p.conn.LocalAddr()
+	}
+	p.readHeaderOnce()
 	if p.header == nil || p.header.Command.IsLocal() || p.readErr != nil {
 		return p.conn.LocalAddr()
 	}
@@ -342,7 +873,7 @@ func (p *Conn) LocalAddr() net.Addr {
 // from the proxy header even if the proxy header itself is
 // syntactically correct.
 func (p *Conn) RemoteAddr() net.Addr {
-	p.once.Do(func() { p.readErr = p.readHeader() })
+	p.readHeaderOnce()
 	if p.header == nil || p.header.Command.IsLocal() || p.readErr != nil {
 		return p.conn.RemoteAddr()
 	}
@@ -358,6 +889,19 @@ func (p *Conn) Raw() net.Conn {
 	return p.conn
 }
 
+// NetConn returns the underlying connection, following the convention of
+// tls.Conn so that middleware can discover it without knowing this package.
+// Bytes already buffered while reading the proxy header can't be read from
+// it.
+func (p *Conn) NetConn() net.Conn {
+	return p.conn
+}
+
+// Unwrap returns the underlying connection, like NetConn.
+func (p *Conn) Unwrap() net.Conn {
+	return p.conn
+}
+
 // TCPConn returns the underlying TCP connection,
 // allowing access to specialized functions.
 //
@@ -406,40 +950,92 @@ func (p *Conn) SetWriteDeadline(t time.Time) error {
 }
 
 func (p *Conn) readHeader() error {
+	// Connections skipping the protocol are used as they are
+	if p.ProxyHeaderPolicy == SKIP {
+		return nil
+	}
+
 	// Fast path: if no readHeaderTimeout is set, avoid time.Now() and SetReadDeadline call
 	var origDeadline time.Time
+	var newDeadline time.Time
 
-	if p.readHeaderTimeout > 0 {
+	if p.readHeaderTimeout > 0 || p.headerRate != nil {
 		// Store the original deadline value to restore it later
 		storedDeadline := p.readDeadline.Load()
 		if storedDeadline != nil {
 			origDeadline = storedDeadline.(time.Time)
 		}
+		p.headerDeadline.init(p.clock, p.setHeaderReadDeadline)
+	}
 
+	if p.readHeaderTimeout > 0 {
 		// Set temporary deadline for header read
-		newDeadline := time.Now().Add(p.readHeaderTimeout)
-		if err := p.conn.SetReadDeadline(newDeadline); err != nil {
+		newDeadline = p.headerDeadline.clock.Now().Add(p.readHeaderTimeout)
+		if err := p.headerDeadline.arm(newDeadline); err != nil {
 			return err
 		}
 	}
 
-	header, err := Read(p.bufReader)
+	// The rate reader moves the deadline as bytes arrive, never past the
+	// header timeout
+	if p.headerRate != nil {
+		p.headerRate.begin(&p.headerDeadline, newDeadline)
+	}
+
+	header, err := p.parseOpts.read(p.bufReader)
+
+	// Let the SNI policy decide on the connection's policy while the header
+	// deadline still bounds the wait for the ClientHello
+	var sniErr error
+	if p.SNIPolicy != nil && (err == nil || err == ErrNoProxyProtocol) {
+		var policy Policy
+		if policy, sniErr = p.SNIPolicy(peekServerName(p.bufReader), header); sniErr == nil {
+			p.ProxyHeaderPolicy = policy
+		}
+	}
+
+	tooSlow := p.headerRate != nil && p.headerRate.end()
+	timedOut := false
 
 	// Always reset the deadline if we've changed it
-	if p.readHeaderTimeout > 0 {
+	if p.readHeaderTimeout > 0 || p.headerRate != nil {
 		// Restore original deadline, ignoring errors since we can't do much about them
+		p.headerDeadline.disarm()
 		p.conn.SetReadDeadline(origDeadline)
-
-		// If we got a timeout error, translate it to ErrNoProxyProtocol for consistent handling
+	}
+	if p.readHeaderTimeout > 0 {
+		// If we got a timeout error, translate it to ErrNoProxyProtocol for
+		// consistent handling, unless it is preserved
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			err = ErrNoProxyProtocol
+			if !p.preserveTimeout {
+				err = ErrNoProxyProtocol
+			}
+			timedOut = true
 		}
 	}
 
+	if tooSlow {
+		return ErrHeaderTooSlow
+	}
+
+	if sniErr != nil {
+		p.readErrCode = ErrCodePolicyReject
+		return sniErr
+	}
+
+	if timedOut && p.preserveTimeout {
+		p.readErrCode = ErrCodeTimeout
+		return err
+	}
+
 	// Handle ErrNoProxyProtocol - act as if there was no error when proxy protocol is not required
 	if err == ErrNoProxyProtocol {
-		// Unless we're in REQUIRE mode, in which case it's an error
-		if p.ProxyHeaderPolicy == REQUIRE {
+		// Unless we're in REQUIRE mode, in which case it's an error, as is
+		// a timeout in hardened mode
+		if p.ProxyHeaderPolicy == REQUIRE || (timedOut && p.parseOpts.hardened) {
+			if timedOut {
+				p.readErrCode = ErrCodeTimeout
+			}
 			return err
 		}
 		return nil
@@ -447,15 +1043,45 @@ func (p *Conn) readHeader() error {
 
 	// Process a successfully read header
 	if err == nil && header != nil {
+		p.headerVersion = header.Version
 		switch p.ProxyHeaderPolicy {
 		case REJECT:
 			return ErrSuperfluousProxyHeader
-		case USE, REQUIRE:
+		case USE, REQUIRE, SKIP:
+			// SKIP only gets here from the SNI policy, once the header was
+			// read: it is used as with USE
+			if len(p.headerTransforms) > 0 {
+				if header, err = p.transformHeader(header); err != nil {
+					return err
+				}
+			}
+			if p.rejectUnspecified {
+				if checkErr := CheckSpecifiedAddresses(header); checkErr != nil {
+					return checkErr
+				}
+			}
 			if p.Validate != nil {
 				if validateErr := p.Validate(header); validateErr != nil {
+					p.readErrCode = ErrCodeValidatorReject
 					return validateErr
 				}
 			}
+			if p.ValidateUpstream != nil {
+				if validateErr := p.ValidateUpstream(p.conn.RemoteAddr(), header); validateErr != nil {
+					p.readErrCode = ErrCodeValidatorReject
+					return validateErr
+				}
+			}
+			if p.headerPolicy != nil {
+				if policyErr := p.headerPolicy(ConnPolicyOptions{
+					Upstream:   p.conn.RemoteAddr(),
+					Downstream: p.conn.LocalAddr(),
+					Conn:       p.conn,
+				}, header); policyErr != nil {
+					p.readErrCode = ErrCodePolicyReject
+					return policyErr
+				}
+			}
 			p.header = header
 		}
 	}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -1533,6 +1534,47 @@ func Test_MisconfiguredTLSServerRespondsWithUnderlyingError(t *testing.T) {
 	}
 }
 
+func TestConnCloseReleasesReaderOnce(t *testing.T) {
+	before := GetPoolStats()
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConn(server)
+	if stats := GetPoolStats(); stats.ReadersAcquired != before.ReadersAcquired+1 {
+		t.Fatalf("bad: acquired %d, expected %d", stats.ReadersAcquired, before.ReadersAcquired+1)
+	}
+
+	// Close while a read is blocked on the connection
+	readResult := make(chan error)
+	go func() {
+		_, err := conn.Read(make([]byte, 4))
+		readResult <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := <-readResult; err == nil {
+		t.Fatal("err: read should fail once the connection is closed")
+	}
+
+	// Closing again must not return the reader to the pool a second time
+	conn.Close()
+	stats := GetPoolStats()
+	if released := stats.ReadersReleased - before.ReadersReleased; released != 1 {
+		t.Fatalf("bad: released %d readers, expected 1", released)
+	}
+	if stats.ReadersOutstanding != before.ReadersOutstanding {
+		t.Fatalf("bad: %d readers outstanding, expected %d", stats.ReadersOutstanding, before.ReadersOutstanding)
+	}
+
+	if _, err := conn.Read(make([]byte, 4)); err != io.EOF {
+		t.Fatalf("bad: expected %v, actual %v", io.EOF, err)
+	}
+}
+
 type testConn struct {
 	readFromCalledWith io.Reader
 	reads              int
@@ -1557,6 +1599,21 @@ func (c *testConn) Read(p []byte) (int, error) {
 	return 1, nil
 }
 
+func TestConnUnwrap(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server)
+	defer conn.Close()
+
+	var c net.Conn = conn
+	if nc, ok := c.(interface{ NetConn() net.Conn }); !ok || nc.NetConn() != server {
+		t.Fatal("bad: NetConn doesn't return the underlying connection")
+	}
+	if u, ok := c.(interface{ Unwrap() net.Conn }); !ok || u.Unwrap() != server {
+		t.Fatal("bad: Unwrap doesn't return the underlying connection")
+	}
+}
+
 func TestCopyToWrappedConnection(t *testing.T) {
 	innerConn := &testConn{}
 	wrappedConn := NewConn(innerConn)
@@ -1596,6 +1653,184 @@ func TestCopyFromWrappedConnectionToWrappedConnection(t *testing.T) {
 	}
 }
 
+func TestReadDrainsBufferedPayload(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, SetReadHeaderTimeout(0))
+	defer conn.Close()
+
+	go func() {
+		// The start of the payload is buffered along with the header, the
+		// rest is read from the connection
+		client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nfirst"))
+		client.Write([]byte("second"))
+	}()
+
+	b := make([]byte, 3)
+	var got []byte
+	for len(got) < len("firstsecond") {
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		got = append(got, b[:n]...)
+	}
+	if string(got) != "firstsecond" {
+		t.Fatalf("bad: %q", got)
+	}
+	if conn.bufReader.Buffered() != 0 {
+		t.Fatalf("bad: %d bytes left buffered", conn.bufReader.Buffered())
+	}
+}
+
+func TestCopyFromConnectionFlushesBufferedPayload(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+	defer pl.Close()
+
+	payload := bytes.Repeat([]byte("payload"), 1000)
+	go func() {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Sent in a single write so that the payload is buffered along
+		// with the header
+		_, _ = conn.Write(append([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"), payload...))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	dst, peer := net.Pipe()
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(peer)
+		received <- b
+	}()
+
+	n, err := conn.(*Conn).WriteTo(dst)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	dst.Close()
+
+	if n != int64(len(payload)) {
+		t.Fatalf("bad: copied %d bytes, expected %d", n, len(payload))
+	}
+	if got := <-received; !bytes.Equal(got, payload) {
+		t.Fatalf("bad: received %d bytes, expected the %d bytes payload", len(got), len(payload))
+	}
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+}
+
+func TestZeroCopyFlushesBufferedPayload(t *testing.T) {
+	src, client := net.Pipe()
+	go func() {
+		defer client.Close()
+		_, _ = client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nhello"))
+	}()
+
+	conn := NewConn(src)
+	defer conn.Close()
+
+	dst, peer := net.Pipe()
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(peer)
+		received <- b
+	}()
+
+	before := GetZeroCopyStats()
+	if _, err := ZeroCopy(conn, dst); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	dst.Close()
+
+	if got := <-received; string(got) != "hello" {
+		t.Fatalf("bad: %q", got)
+	}
+
+	// Pipes can't be spliced, everything goes through userspace
+	stats := GetZeroCopyStats()
+	if buffered := stats.BufferedBytes - before.BufferedBytes; buffered != 5 {
+		t.Fatalf("bad: %d buffered bytes, expected 5", buffered)
+	}
+	fallbacks := stats.FallbacksUnavailable + stats.FallbacksUnsupportedConn -
+		before.FallbacksUnavailable - before.FallbacksUnsupportedConn
+	if fallbacks != 1 {
+		t.Fatalf("bad: %d fallbacks, expected 1", fallbacks)
+	}
+	if stats.ZeroCopyBytes != before.ZeroCopyBytes {
+		t.Fatalf("bad: %d bytes reported as zero-copied", stats.ZeroCopyBytes-before.ZeroCopyBytes)
+	}
+}
+
+func TestZeroCopyTCP(t *testing.T) {
+	// Whatever the backend compiled in, a relay between TCP connections
+	// delivers everything
+	tcpPair := func() (net.Conn, net.Conn) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer l.Close()
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server, err := l.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return client, server
+	}
+	client, src := tcpPair()
+	dst, peer := tcpPair()
+	defer src.Close()
+	defer peer.Close()
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	go func() {
+		defer client.Close()
+		client.Write(payload)
+	}()
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(peer)
+		received <- b
+	}()
+
+	before := GetZeroCopyStats()
+	n, err := ZeroCopy(src, dst)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// The sockets are still in non-blocking mode, which deadlines rely on
+	dst.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := dst.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("bad: %v", err)
+	}
+	dst.Close()
+	if got := <-received; n != int64(len(payload)) || !bytes.Equal(got, payload) {
+		t.Fatalf("bad: %d bytes relayed, %d received", n, len(got))
+	}
+	if ZeroCopyAvailable() {
+		if sent := GetZeroCopyStats().ZeroCopyBytes - before.ZeroCopyBytes; sent != uint64(len(payload)) {
+			t.Fatalf("bad: %d bytes reported as zero-copied", sent)
+		}
+	}
+}
+
 func benchmarkTCPProxy(size int, b *testing.B) {
 	// create and start the echo backend
 	backend, err := net.Listen("tcp", "127.0.0.1:0")
@@ -1771,3 +2006,188 @@ y2ptGsuSmgUtWj3NM9xuwYPm+Z/F84K6+ARYiZ6PYj013sovGKUFfYAqVXVlxtIX
 qyUBnu3X9ps8ZfjLZO7BAkEAlT4R5Yl6cGhaJQYZHOde3JEMhNRcVFMO8dJDaFeo
 f9Oeos0UUothgiDktdQHxdNEwLjQf7lJJBzV+5OtwswCWA==
 -----END RSA PRIVATE KEY-----`)
+
+func TestDisableVersion(t *testing.T) {
+	v2, err := (&Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}).Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	v1 := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+
+	tests := []struct {
+		name    string
+		header  []byte
+		opt     func(*Conn)
+		proxied bool
+	}{
+		{"v1 with v1 disabled", v1, DisableV1(), false},
+		{"v2 with v1 disabled", v2, DisableV1(), true},
+		{"v1 with v2 disabled", v1, DisableV2(), true},
+		{"v2 with v2 disabled", v2, DisableV2(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write(tt.header)
+
+			conn := NewConn(server, tt.opt)
+			defer conn.Close()
+			if proxied := conn.ProxyHeader() != nil; proxied != tt.proxied {
+				t.Fatalf("bad: proxied %v", proxied)
+			}
+			if !tt.proxied {
+				// The header is left in the data
+				b := make([]byte, len(tt.header))
+				if _, err := io.ReadFull(conn, b); err != nil || !bytes.Equal(b, tt.header) {
+					t.Fatalf("bad: %q, %v", b, err)
+				}
+			}
+		})
+	}
+}
+
+func TestListenerOnError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	type report struct {
+		addr net.Addr
+		err  error
+	}
+	reports := make(chan report, 2)
+	refuse := atomic.Bool{}
+	refuse.Store(true)
+	pl := &Listener{
+		Listener: l,
+		Policy: func(upstream net.Addr) (Policy, error) {
+			if refuse.CompareAndSwap(true, false) {
+				return REJECT, ErrInvalidUpstream
+			}
+			return REQUIRE, nil
+		},
+		OnError: func(addr net.Addr, err error) {
+			reports <- report{addr, err}
+		},
+	}
+	defer pl.Close()
+
+	// The first connection is refused by the policy, the second sends no
+	// header
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+	}
+	clients[1].Write([]byte("GET / HTTP/1.1\r\n"))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if r := <-reports; r.err != ErrInvalidUpstream || r.addr.String() != clients[0].LocalAddr().String() {
+		t.Fatalf("bad: %v, %v", r.addr, r.err)
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+	if r := <-reports; r.err != ErrNoProxyProtocol || r.addr.String() != clients[1].LocalAddr().String() {
+		t.Fatalf("bad: %v, %v", r.addr, r.err)
+	}
+}
+
+func TestConnHeaderError(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		header  bool
+		err     error
+	}{
+		{"header", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping", true, nil},
+		{"no header", "GET / HTTP/1.1\r\n", false, nil},
+		{"malformed header", "PROXY TCP4 10.1.1.1 20.2.2.2 1000\r\nping", false, ErrCantReadAddressFamilyAndProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+			go client.Write([]byte(tt.payload))
+
+			conn := NewConn(server, WithPolicy(USE))
+			defer conn.Close()
+			if err := conn.HeaderError(); err != tt.err {
+				t.Fatalf("bad: %v", err)
+			}
+			if header := conn.ProxyHeader(); (header != nil) != tt.header {
+				t.Fatalf("bad: %v", header)
+			}
+		})
+	}
+}
+
+func TestPreserveTimeoutError(t *testing.T) {
+	for _, policy := range []Policy{USE, REQUIRE} {
+		server, client := net.Pipe()
+		defer client.Close()
+
+		conn := NewConn(server, WithPolicy(policy), SetReadHeaderTimeout(20*time.Millisecond), PreserveTimeoutError())
+		defer conn.Close()
+		_, err := conn.Read(make([]byte, 1))
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Fatalf("bad: %v", err)
+		}
+		if conn.ErrorCode() != ErrCodeTimeout {
+			t.Fatalf("bad: %v", conn.ErrorCode())
+		}
+	}
+
+	// Without the option, the timeout is hidden under the USE policy
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConn(server, WithPolicy(USE), SetReadHeaderTimeout(20*time.Millisecond))
+	defer conn.Close()
+	if err := conn.HeaderError(); err != nil {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestKeepLocalAddr(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, KeepLocalAddr: true}
+	defer pl.Close()
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if conn.LocalAddr().String() != client.RemoteAddr().String() {
+		t.Fatalf("bad: %v", conn.LocalAddr())
+	}
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+}
@@ -0,0 +1,88 @@
+package proxyproto
+
+import "net"
+
+// Redaction configures how Header.RedactedWith masks a header, e.g. to log
+// it without keeping personal data.
+type Redaction struct {
+	// IPv4Bits and IPv6Bits are the number of leading bits of the addresses
+	// which are kept, the others are zeroed.
+	IPv4Bits int
+	IPv6Bits int
+	// Destination also masks the destination address, which is usually
+	// the proxy's own.
+	Destination bool
+	// KeepTLVs keeps the TLVs, which may carry client data such as the TLS
+	// client certificate's CN. They are removed by default.
+	KeepTLVs bool
+}
+
+// DefaultRedaction zeroes the last octet of IPv4 source addresses, keeps
+// the /48 prefix of IPv6 ones and removes the TLVs.
+var DefaultRedaction = Redaction{IPv4Bits: 24, IPv6Bits: 48}
+
+// Redacted returns a copy of the header masked with DefaultRedaction.
+func (header *Header) Redacted() *Header {
+	return header.RedactedWith(DefaultRedaction)
+}
+
+// RedactedWith returns a copy of the header masked as configured by r.
+// Ports and unix socket addresses are kept as is.
+func (header *Header) RedactedWith(r Redaction) *Header {
+	if header == nil {
+		return nil
+	}
+
+	redacted := &Header{
+		Version:           header.Version,
+		Command:           header.Command,
+		TransportProtocol: header.TransportProtocol,
+		SourceAddr:        r.redactAddr(header.SourceAddr),
+		DestinationAddr:   header.DestinationAddr,
+	}
+	if r.Destination {
+		redacted.DestinationAddr = r.redactAddr(header.DestinationAddr)
+	}
+	if r.KeepTLVs && len(header.rawTLVs) > 0 {
+		redacted.setRawTLVs(append([]byte(nil), header.rawTLVs...))
+	}
+	return redacted
+}
+
+func (r Redaction) redactAddr(addr net.Addr) net.Addr {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		if addr == nil {
+			return addr
+		}
+		return &net.TCPAddr{IP: r.redactIP(addr.IP), Port: addr.Port}
+	case *net.UDPAddr:
+		if addr == nil {
+			return addr
+		}
+		return &net.UDPAddr{IP: r.redactIP(addr.IP), Port: addr.Port}
+	}
+	return addr
+}
+
+// redactIP returns a copy of ip keeping only its configured prefix. Zones
+// are dropped as they may identify the host.
+func (r Redaction) redactIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(clampBits(r.IPv4Bits, 32), 32))
+	}
+	if len(ip) == net.IPv6len {
+		return ip.Mask(net.CIDRMask(clampBits(r.IPv6Bits, 128), 128))
+	}
+	return nil
+}
+
+func clampBits(bits, max int) int {
+	if bits < 0 {
+		return 0
+	}
+	if bits > max {
+		return max
+	}
+	return bits
+}
@@ -0,0 +1,66 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestHeaderRedacted(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        &net.TCPAddr{IP: net.ParseIP("10.1.1.123"), Port: 1000},
+		DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	}
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	redacted := header.Redacted()
+	if redacted.SourceAddr.String() != "10.1.1.0:1000" {
+		t.Fatalf("bad: %v", redacted.SourceAddr)
+	}
+	if redacted.DestinationAddr.String() != "20.2.2.2:2000" {
+		t.Fatalf("bad: %v", redacted.DestinationAddr)
+	}
+	if tlvs, err := redacted.TLVs(); err != nil || len(tlvs) != 0 {
+		t.Fatalf("bad: %v, %v", tlvs, err)
+	}
+
+	// The original header is left untouched
+	if header.SourceAddr.String() != "10.1.1.123:1000" {
+		t.Fatalf("bad: %v", header.SourceAddr)
+	}
+	if tlvs, err := header.TLVs(); err != nil || len(tlvs) != 1 {
+		t.Fatalf("bad: %v, %v", tlvs, err)
+	}
+}
+
+func TestHeaderRedactedWith(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: UDPv6,
+		SourceAddr:        &net.UDPAddr{IP: net.ParseIP("2001:db8:1234:5678::1"), Port: 1000},
+		DestinationAddr:   &net.UDPAddr{IP: net.ParseIP("2001:db8:abcd:ef01::2"), Port: 2000},
+	}
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	redacted := header.RedactedWith(Redaction{IPv6Bits: 32, Destination: true, KeepTLVs: true})
+	if redacted.SourceAddr.String() != "[2001:db8::]:1000" {
+		t.Fatalf("bad: %v", redacted.SourceAddr)
+	}
+	if redacted.DestinationAddr.String() != "[2001:db8::]:2000" {
+		t.Fatalf("bad: %v", redacted.DestinationAddr)
+	}
+	if tlvs, err := redacted.TLVs(); err != nil || len(tlvs) != 1 {
+		t.Fatalf("bad: %v, %v", tlvs, err)
+	}
+
+	if DefaultRedaction.redactIP(net.ParseIP("2001:db8:1234:5678::1")).String() != "2001:db8:1234::" {
+		t.Fatal("bad: default IPv6 redaction")
+	}
+}
@@ -0,0 +1,187 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// ErrTooManyConns is returned when a client claimed by the proxy header
+// already has Registry.MaxPerClient connections.
+var ErrTooManyConns = newError(ErrCodePolicyReject, "proxyproto: too many connections from client")
+
+// Registry tracks the open connections by the client they carry, as
+// claimed by their proxy header rather than the address of the load
+// balancer, e.g. to kick a client or cap its connections. Connections
+// without a header are tracked by their remote address. The zero value
+// isn't usable, see NewRegistry.
+//
+// A connection is registered once its header has been read successfully,
+// and unregistered when it is closed or detached.
+type Registry struct {
+	// MaxPerClient, if > 0, is the number of connections a client may
+	// have open at once. Reading the header of one more fails with
+	// ErrTooManyConns. It must not be changed once in use.
+	MaxPerClient int
+
+	mu       sync.Mutex
+	byClient map[netip.Addr]map[*Conn]struct{}
+	byID     map[string]*Conn
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byClient: make(map[netip.Addr]map[*Conn]struct{}),
+		byID:     make(map[string]*Conn),
+	}
+}
+
+// WithRegistry registers the connection in r once its header has been read
+// when passed as option to NewConn().
+func WithRegistry(r *Registry) func(*Conn) {
+	return func(c *Conn) {
+		if r != nil {
+			c.registry = r
+		}
+	}
+}
+
+// add registers p, which is being read, unless it was closed meanwhile.
+func (r *Registry) add(p *Conn) error {
+	client, hasClient := registryClient(p)
+	id, hasID := registryID(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Checked under the lock, as unregistering happens after closing
+	if p.closed.Load() {
+		return nil
+	}
+	if hasClient {
+		conns := r.byClient[client]
+		if r.MaxPerClient > 0 && len(conns) >= r.MaxPerClient {
+			return ErrTooManyConns
+		}
+		if conns == nil {
+			conns = make(map[*Conn]struct{})
+			r.byClient[client] = conns
+		}
+		conns[p] = struct{}{}
+		p.registryClient = client
+	}
+	if hasID {
+		r.byID[id] = p
+		p.registryID = id
+	}
+	return nil
+}
+
+// remove unregisters p, if it was registered.
+func (r *Registry) remove(p *Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if conns, ok := r.byClient[p.registryClient]; ok {
+		delete(conns, p)
+		if len(conns) == 0 {
+			delete(r.byClient, p.registryClient)
+		}
+	}
+	if p.registryID != "" && r.byID[p.registryID] == p {
+		delete(r.byID, p.registryID)
+	}
+}
+
+// Len returns the number of connections registered.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, conns := range r.byClient {
+		n += len(conns)
+	}
+	return n
+}
+
+// Clients returns the addresses of the clients with open connections.
+func (r *Registry) Clients() []netip.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := make([]netip.Addr, 0, len(r.byClient))
+	for client := range r.byClient {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Count returns the number of open connections of client.
+func (r *Registry) Count(client netip.Addr) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.byClient[client.Unmap()])
+}
+
+// Conns returns the open connections of client.
+func (r *Registry) Conns(client netip.Addr) []*Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conns := make([]*Conn, 0, len(r.byClient[client.Unmap()]))
+	for conn := range r.byClient[client.Unmap()] {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Lookup returns the open connection whose header carries the given
+// PP2_TYPE_UNIQUE_ID TLV value.
+func (r *Registry) Lookup(uniqueID []byte) (*Conn, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conn, ok := r.byID[string(uniqueID)]
+	return conn, ok
+}
+
+// CloseClient closes the open connections of client, and returns how many
+// there were.
+func (r *Registry) CloseClient(client netip.Addr) int {
+	conns := r.Conns(client)
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return len(conns)
+}
+
+// registryClient returns the client of p, the source of its header or its
+// remote address.
+func registryClient(p *Conn) (netip.Addr, bool) {
+	addr := p.conn.RemoteAddr()
+	if p.header != nil && p.header.Command == PROXY {
+		addr = p.header.SourceAddr
+	}
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return netip.Addr{}, false
+	}
+	client, ok := netip.AddrFromSlice(ip)
+	return client.Unmap(), ok
+}
+
+// registryID returns the PP2_TYPE_UNIQUE_ID TLV value of the header of p.
+func registryID(p *Conn) (string, bool) {
+	if p.header == nil {
+		return "", false
+	}
+	id, ok := p.header.UniqueID()
+	return string(id), ok && len(id) > 0
+}
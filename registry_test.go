@@ -0,0 +1,69 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.MaxPerClient = 2
+
+	newConn := func(source string, tlvs ...TLV) *Conn {
+		t.Helper()
+		header := &Header{
+			Version:           2,
+			Command:           PROXY,
+			TransportProtocol: TCPv4,
+			SourceAddr:        &net.TCPAddr{IP: net.ParseIP(source), Port: 1000},
+			DestinationAddr:   &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+		}
+		if err := header.SetTLVs(tlvs); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		server, client := net.Pipe()
+		t.Cleanup(func() { client.Close() })
+		go header.WriteTo(client)
+		return NewConn(server, WithRegistry(registry))
+	}
+
+	first := newConn("10.1.1.1", TLV{Type: PP2_TYPE_UNIQUE_ID, Value: []byte("id-1")})
+	second := newConn("10.1.1.1")
+	other := newConn("10.3.3.3")
+	for _, conn := range []*Conn{first, second, other} {
+		if conn.ProxyHeader() == nil {
+			t.Fatalf("bad: %v", conn.ErrorCode())
+		}
+	}
+
+	// The client is at its limit
+	third := newConn("10.1.1.1")
+	if _, err := third.Read(make([]byte, 1)); err != ErrTooManyConns {
+		t.Fatalf("bad: %v", err)
+	}
+	third.Close()
+
+	client := netip.MustParseAddr("10.1.1.1")
+	if registry.Count(client) != 2 || registry.Len() != 3 || len(registry.Clients()) != 2 {
+		t.Fatalf("bad: %d, %d, %v", registry.Count(client), registry.Len(), registry.Clients())
+	}
+	if conn, ok := registry.Lookup([]byte("id-1")); !ok || conn != first {
+		t.Fatalf("bad: %v, %v", conn, ok)
+	}
+
+	if n := registry.CloseClient(client); n != 2 {
+		t.Fatalf("bad: closed %d", n)
+	}
+	if registry.Count(client) != 0 || registry.Len() != 1 {
+		t.Fatalf("bad: %d, %d", registry.Count(client), registry.Len())
+	}
+	if _, ok := registry.Lookup([]byte("id-1")); ok {
+		t.Fatal("bad: closed connection still registered")
+	}
+
+	other.Close()
+	if registry.Len() != 0 {
+		t.Fatalf("bad: %d", registry.Len())
+	}
+}
@@ -0,0 +1,39 @@
+package proxyproto
+
+import (
+	"net"
+	"time"
+)
+
+// rejectResponseTimeout bounds the write of the reject response, so that an
+// upstream which doesn't read can't hold up the connection or Accept.
+const rejectResponseTimeout = time.Second
+
+// WithRejectResponse makes a connection write resp to the upstream when its
+// header is refused, by the REJECT policy, a validator or a header policy,
+// when passed as option to NewConn(). This gives misconfigured upstreams a
+// diagnosable error, e.g. an HTTP 400 or an SMTP 554 banner, instead of an
+// abrupt close. Malformed headers and timeouts get no response.
+//
+// The response is written when the header is read, before the first read
+// returns the error. Its write is bounded by a one second deadline, after
+// which the write deadline of the connection is cleared.
+func WithRejectResponse(resp []byte) func(*Conn) {
+	return func(c *Conn) {
+		c.rejectResponse = resp
+	}
+}
+
+// rejectsWithResponse tells whether the connections refused with code get
+// the reject response.
+func rejectsWithResponse(code ErrorCode) bool {
+	return code == ErrCodePolicyReject || code == ErrCodeValidatorReject
+}
+
+// writeRejectResponse writes resp to conn, ignoring errors since the
+// connection is refused anyway.
+func writeRejectResponse(conn net.Conn, resp []byte) {
+	conn.SetWriteDeadline(time.Now().Add(rejectResponseTimeout))
+	conn.Write(resp)
+	conn.SetWriteDeadline(time.Time{})
+}
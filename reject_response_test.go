@@ -0,0 +1,88 @@
+package proxyproto
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRejectResponse(t *testing.T) {
+	resp := []byte("HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n")
+	errRefused := errors.New("refused")
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		opts  []func(*Conn)
+		resp  bool
+	}{
+		{"validator", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", []func(*Conn){
+			ValidateHeader(func(*Header) error { return errRefused }),
+		}, true},
+		{"reject policy", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", []func(*Conn){
+			WithPolicy(REJECT),
+		}, true},
+		{"header policy", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", []func(*Conn){
+			WithHeaderPolicy(func(ConnPolicyOptions, *Header) error { return errRefused }),
+		}, true},
+		{"malformed", "PROXY TCP4 10.1.1.1\r\n", nil, false},
+		{"accepted", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer client.Close()
+
+			received := make(chan []byte, 1)
+			go func() {
+				client.Write([]byte(tc.input))
+				client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				b, _ := io.ReadAll(client)
+				received <- b
+			}()
+
+			conn := NewConn(server, append(tc.opts, WithRejectResponse(resp))...)
+			conn.ProxyHeader()
+			conn.Close()
+
+			got := <-received
+			if tc.resp && string(got) != string(resp) {
+				t.Fatalf("bad: %q", got)
+			}
+			if !tc.resp && len(got) != 0 {
+				t.Fatalf("bad: unexpected response %q", got)
+			}
+		})
+	}
+}
+
+func TestListenerRejectResponse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{
+		Listener: l,
+		ConnPolicy: func(ConnPolicyOptions) (Policy, error) {
+			return REJECT, ErrInvalidUpstream
+		},
+		RejectResponse: []byte("554 untrusted upstream\r\n"),
+	}
+	defer pl.Close()
+	go pl.Accept()
+
+	conn, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(got) != "554 untrusted upstream\r\n" {
+		t.Fatalf("bad: %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package proxyproto
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// serveRetryBaseDelay is the delay before retrying the first of a series of
+// temporary accept errors, doubled for each following one up to
+// serveRetryMaxDelay.
+const (
+	serveRetryBaseDelay = 5 * time.Millisecond
+	serveRetryMaxDelay  = time.Second
+)
+
+// Serve accepts connections and calls handler for each of them in its own
+// goroutine, or in one of Workers goroutines if set, until ctx is done or
+// accepting fails. The connection is closed once handler returns;
+// connections accepted with the SKIP policy are wrapped in a Conn which
+// doesn't read any header.
+//
+// Once MaxPending connections are pending, that is accepted but not done
+// with, Serve stops accepting until one is done, or accepts and closes new
+// connections right away if ShedLoad is set.
+//
+// Temporary accept errors are retried with an exponential backoff, and a
+// panicking handler only closes its connection. Both are logged to ErrorLog.
+//
+// When ctx is done, the listener is closed and Serve waits for the pending
+// connections to be handled before returning ctx.Err(). Otherwise, the error
+// which stopped accepting is returned, once they are done as well.
+func (p *Listener) Serve(ctx context.Context, handler func(*Conn)) error {
+	stop := context.AfterFunc(ctx, func() {
+		p.Close()
+	})
+	defer stop()
+
+	// Signalled whenever a connection is done with, to resume accepting
+	freed := make(chan struct{}, 1)
+	serve := func(conn *Conn) {
+		p.active.Add(1)
+		p.serveConn(conn, handler)
+		p.active.Add(-1)
+		p.pending.Add(-1)
+		select {
+		case freed <- struct{}{}:
+		default:
+		}
+	}
+
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
+
+	var queue chan *Conn
+	if p.Workers > 0 {
+		queue = make(chan *Conn, max(p.MaxPending, p.Workers))
+		defer close(queue)
+		handlers.Add(p.Workers)
+		for i := 0; i < p.Workers; i++ {
+			go func() {
+				defer handlers.Done()
+				for conn := range queue {
+					serve(conn)
+				}
+			}()
+		}
+	}
+
+	var delay time.Duration
+	for {
+		// Apply backpressure by not accepting while too many are pending
+		for !p.ShedLoad && p.full() {
+			select {
+			case <-freed:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		conn, err := p.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Accept can fail for temporary failures, e.g. too many open
+			// files. In that case, wait and retry later, like net/http.
+			if isTimeout(err) || isTemporary(err) {
+				if delay == 0 {
+					delay = serveRetryBaseDelay
+				} else {
+					delay *= 2
+				}
+				if delay > serveRetryMaxDelay {
+					delay = serveRetryMaxDelay
+				}
+				p.errorLog().Printf("proxyproto: listener %q: accept error (retrying in %v): %v", p.Addr(), delay, err)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		if p.full() {
+			// Only reached when shedding load
+			p.shed.Add(1)
+			conn.Close()
+			continue
+		}
+
+		proxyConn := asConn(conn)
+		// Only Serve recycles the Conn, once the handler is done with it
+		proxyConn.recycledByServe = proxyConn.pooled
+		p.pending.Add(1)
+		if queue != nil {
+			queue <- proxyConn
+			continue
+		}
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			serve(proxyConn)
+		}()
+	}
+}
+
+// full reports whether MaxPending connections are pending.
+func (p *Listener) full() bool {
+	return p.MaxPending > 0 && p.pending.Load() >= int64(p.MaxPending)
+}
+
+// Pending returns the number of connections accepted by Serve which haven't
+// been handled yet, whether queued for a worker or being handled.
+func (p *Listener) Pending() int {
+	return int(p.pending.Load())
+}
+
+// Queued returns the number of connections accepted by Serve waiting for a
+// worker.
+func (p *Listener) Queued() int {
+	return max(0, int(p.pending.Load()-p.active.Load()))
+}
+
+// Shed returns the number of connections closed right away because
+// MaxPending connections were pending in Serve, or MaxConns were live.
+func (p *Listener) Shed() uint64 {
+	return p.shed.Load()
+}
+
+func (p *Listener) serveConn(conn *Conn, handler func(*Conn)) {
+	defer conn.closeServed()
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 64<<10)
+			buf = buf[:runtime.Stack(buf, false)]
+			p.errorLog().Printf("proxyproto: panic serving %v: %v\n%s", conn.Raw().RemoteAddr(), r, buf)
+		}
+	}()
+	handler(conn)
+}
+
+func (p *Listener) errorLog() *log.Logger {
+	if errorLog := p.Options().ErrorLog; errorLog != nil {
+		return errorLog
+	}
+	return log.Default()
+}
+
+// isTemporary reports whether err is a temporary accept error, as flagged
+// by the deprecated net.Error.Temporary which still covers e.g. EMFILE.
+func isTemporary(err error) bool {
+	var te interface{ Temporary() bool }
+	return errors.As(err, &te) && te.Temporary()
+}
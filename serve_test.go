@@ -0,0 +1,246 @@
+package proxyproto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenerServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan string, 1)
+	release := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- pl.Serve(ctx, func(conn *Conn) {
+			b, _ := io.ReadAll(conn)
+			served <- conn.RemoteAddr().String() + " " + string(b)
+			<-release
+		})
+	}()
+
+	conn, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nping"))
+	conn.(*net.TCPConn).CloseWrite()
+	defer conn.Close()
+
+	if got := <-served; got != "10.1.1.1:1000 ping" {
+		t.Fatalf("bad: %q", got)
+	}
+
+	// Serve waits for the running handlers before returning
+	cancel()
+	select {
+	case err := <-errc:
+		t.Fatalf("bad: returned before the handler: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestListenerServeSkipAndPanic(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var logs lockedBuffer
+	pl := &Listener{
+		Listener: l,
+		Policy:   func(net.Addr) (Policy, error) { return SKIP, nil },
+		ErrorLog: log.New(&logs, "", 0),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan string, 2)
+	go pl.Serve(ctx, func(conn *Conn) {
+		b := make([]byte, 5)
+		io.ReadFull(conn, b)
+		if string(b) == "panic" {
+			panic("handler failed")
+		}
+		served <- string(b)
+	})
+
+	for _, payload := range []string{"panic", "PROXY"} {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+		conn.Write([]byte(payload))
+		if payload == "panic" {
+			// The panicking handler closes the connection
+			if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+				t.Fatalf("bad: %v", err)
+			}
+		}
+	}
+
+	// The header isn't parsed for skipped connections
+	if got := <-served; got != "PROXY" {
+		t.Fatalf("bad: %q", got)
+	}
+	if !strings.Contains(logs.String(), "panic serving") || !strings.Contains(logs.String(), "handler failed") {
+		t.Fatalf("bad: %q", logs.String())
+	}
+}
+
+func TestListenerServeMaxPending(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, Workers: 1, MaxPending: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	served := make(chan string, 3)
+	go pl.Serve(ctx, func(conn *Conn) {
+		<-release
+		served <- conn.RemoteAddr().String()
+	})
+
+	for i := 1; i <= 3; i++ {
+		conn, err := net.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "PROXY TCP4 10.1.1.%d 20.2.2.2 1000 2000\r\n", i)
+	}
+
+	// One connection is handled, one is queued and the last isn't accepted
+	waitFor(t, func() bool { return pl.Pending() == 2 && pl.Queued() == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if pl.Pending() != 2 {
+		t.Fatalf("bad: %d pending", pl.Pending())
+	}
+
+	close(release)
+	for i := 1; i <= 3; i++ {
+		if got, want := <-served, fmt.Sprintf("10.1.1.%d:1000", i); got != want {
+			t.Fatalf("bad: %q, want %q", got, want)
+		}
+	}
+	waitFor(t, func() bool { return pl.Pending() == 0 })
+}
+
+func TestListenerServeShedLoad(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, MaxPending: 1, ShedLoad: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	go pl.Serve(ctx, func(conn *Conn) {
+		<-release
+	})
+
+	first, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer first.Close()
+	waitFor(t, func() bool { return pl.Pending() == 1 })
+
+	second, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer second.Close()
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("bad: shed connection wasn't closed")
+	}
+	if pl.Shed() != 1 || pl.Pending() != 1 {
+		t.Fatalf("bad: %d shed, %d pending", pl.Shed(), pl.Pending())
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListenerServePooledConns(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, PoolConns: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		// The handler closes the connection, as does Serve once it returns
+		errc <- pl.Serve(ctx, func(conn *Conn) {
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(conn, b); err == nil {
+				fmt.Fprintf(conn, "%s %s", conn.RemoteAddr(), b)
+			}
+			conn.Close()
+		})
+	}()
+
+	const clients = 50
+	results := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		go func(port int) {
+			conn, err := net.Dial("tcp", pl.Addr().String())
+			if err != nil {
+				results <- err
+				return
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "PROXY TCP4 10.1.1.1 20.2.2.2 %d 2000\r\n", port)
+			// Let other connections come and go meanwhile
+			time.Sleep(time.Duration(port%5) * time.Millisecond)
+			conn.Write([]byte("ping"))
+			b, err := io.ReadAll(conn)
+			if want := fmt.Sprintf("10.1.1.1:%d ping", port); err != nil || string(b) != want {
+				results <- fmt.Errorf("got %q, want %q: %v", b, want, err)
+				return
+			}
+			results <- nil
+		}(1000 + i)
+	}
+	for i := 0; i < clients; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("bad: %v", err)
+		}
+	}
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+	waitFor(t, func() bool { return GetPoolStats().PooledConns == 0 })
+}
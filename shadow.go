@@ -0,0 +1,145 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultShadowQueueSize is the number of pending writes a Shadow queues
+// when no size is given.
+var DefaultShadowQueueSize = 64
+
+// Shadow duplicates a stream to a secondary destination, e.g. a canary
+// backend, on a best-effort basis: writes are queued and sent in the
+// background, and dropped whenever the queue is full so that the shadow
+// destination can never slow down the relay. Once writing to the
+// destination fails or the Shadow is closed, everything else is dropped.
+type Shadow struct {
+	w     io.Writer
+	queue chan []byte
+
+	sentBytes     atomic.Uint64
+	droppedBytes  atomic.Uint64
+	droppedWrites atomic.Uint64
+	failed        atomic.Bool
+	stopped       atomic.Bool
+
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// ShadowStats holds the counters of a Shadow.
+type ShadowStats struct {
+	// SentBytes is the number of bytes written to the shadow destination.
+	SentBytes uint64
+	// DroppedBytes is the number of bytes which weren't sent.
+	DroppedBytes uint64
+	// DroppedWrites is the number of writes which weren't sent.
+	DroppedWrites uint64
+}
+
+// NewShadow returns a Shadow sending to w, queueing at most queueSize
+// writes. A queueSize <= 0 uses DefaultShadowQueueSize.
+func NewShadow(w io.Writer, queueSize int) *Shadow {
+	if queueSize <= 0 {
+		queueSize = DefaultShadowQueueSize
+	}
+
+	s := &Shadow{
+		w:     w,
+		queue: make(chan []byte, queueSize),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues a copy of b for the shadow destination. It never blocks and
+// never fails, dropped data is only reflected by Stats.
+func (s *Shadow) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed || s.failed.Load() {
+		s.drop(len(b))
+		return len(b), nil
+	}
+
+	chunk := make([]byte, len(b))
+	copy(chunk, b)
+	select {
+	case s.queue <- chunk:
+	default:
+		s.drop(len(b))
+	}
+	return len(b), nil
+}
+
+// Stats returns a snapshot of the shadow's counters.
+func (s *Shadow) Stats() ShadowStats {
+	return ShadowStats{
+		SentBytes:     s.sentBytes.Load(),
+		DroppedBytes:  s.droppedBytes.Load(),
+		DroppedWrites: s.droppedWrites.Load(),
+	}
+}
+
+// Close stops accepting writes, drops the queued ones and closes the
+// destination if it is an io.Closer. It doesn't wait for the write in
+// progress, if any, so that a stuck shadow destination can't hold up the
+// relay: closing the destination usually interrupts it.
+func (s *Shadow) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.stopped.Store(true)
+		close(s.queue)
+		s.mu.Unlock()
+
+		// Share the draining with the sender, which may be stuck
+		for chunk := range s.queue {
+			s.drop(len(chunk))
+		}
+		if c, ok := s.w.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
+
+func (s *Shadow) drop(n int) {
+	s.droppedBytes.Add(uint64(n))
+	s.droppedWrites.Add(1)
+}
+
+func (s *Shadow) run() {
+	for chunk := range s.queue {
+		if s.failed.Load() || s.stopped.Load() {
+			s.drop(len(chunk))
+			continue
+		}
+		n, err := s.w.Write(chunk)
+		s.sentBytes.Add(uint64(n))
+		if err != nil {
+			s.failed.Store(true)
+			if n < len(chunk) {
+				s.drop(len(chunk) - n)
+			}
+		}
+	}
+}
+
+// CopyWithShadow transfers data from src to dst like ZeroCopy, duplicating
+// it to shadow along the way. As the data has to pass through userspace to
+// be duplicated, the zero-copy implementation isn't used.
+func CopyWithShadow(src, dst net.Conn, shadow *Shadow) (int64, error) {
+	buf := make([]byte, 64*1024)
+	return io.CopyBuffer(dst, io.TeeReader(src, shadow), buf)
+}
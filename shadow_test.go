@@ -0,0 +1,142 @@
+package proxyproto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCopyWithShadow(t *testing.T) {
+	src, client := net.Pipe()
+	go func() {
+		defer client.Close()
+		_, _ = client.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nhello shadow"))
+	}()
+	conn := NewConn(src)
+	defer conn.Close()
+
+	dst, peer := net.Pipe()
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(peer)
+		received <- b
+	}()
+
+	shadowDst := &lockedBuffer{}
+	shadow := NewShadow(shadowDst, 0)
+
+	if _, err := CopyWithShadow(conn, dst, shadow); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	dst.Close()
+	// Close drops what wasn't sent yet
+	waitFor(t, func() bool { return shadow.Stats().SentBytes == 12 })
+	if err := shadow.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if got := <-received; string(got) != "hello shadow" {
+		t.Fatalf("bad: %q", got)
+	}
+	if shadowDst.String() != "hello shadow" {
+		t.Fatalf("bad: shadow received %q", shadowDst.String())
+	}
+	if stats := shadow.Stats(); stats.SentBytes != 12 || stats.DroppedBytes != 0 {
+		t.Fatalf("bad: %+v", stats)
+	}
+}
+
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return 0, errors.New("shadow backend gone")
+}
+
+func TestShadowDropsWhenFull(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	shadow := NewShadow(w, 1)
+
+	// The first write is picked up by the sender and blocks it, the second
+	// fills the queue, the rest are dropped
+	for i := 0; i < 10; i++ {
+		if n, err := shadow.Write([]byte("x")); n != 1 || err != nil {
+			t.Fatalf("bad: %d, %v", n, err)
+		}
+	}
+	close(w.release)
+	shadow.Close()
+
+	// The blocked write is accounted for once it returns
+	waitFor(t, func() bool { return shadow.Stats().DroppedWrites == 10 })
+	if stats := shadow.Stats(); stats.SentBytes != 0 || stats.DroppedBytes != 10 {
+		t.Fatalf("bad: %+v", stats)
+	}
+}
+
+func TestShadowCloseDoesNotWait(t *testing.T) {
+	// A shadow backend which never reads
+	shadowDst, peer := net.Pipe()
+	defer peer.Close()
+	shadow := NewShadow(shadowDst, 4)
+	for i := 0; i < 4; i++ {
+		shadow.Write([]byte("data"))
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- shadow.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: Close waited for the shadow backend")
+	}
+
+	// Nothing was sent, the write in progress was interrupted by closing
+	// the backend and the queued ones dropped
+	waitFor(t, func() bool { return shadow.Stats().DroppedWrites == 4 })
+	if stats := shadow.Stats(); stats.SentBytes != 0 || stats.DroppedBytes != 16 {
+		t.Fatalf("bad: %+v", stats)
+	}
+
+	// A destination which can't be closed is left behind
+	w := &blockingWriter{release: make(chan struct{})}
+	defer close(w.release)
+	stuck := NewShadow(w, 1)
+	stuck.Write([]byte("x"))
+	go func() {
+		closed <- stuck.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad: Close waited for the shadow backend")
+	}
+}
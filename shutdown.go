@@ -0,0 +1,80 @@
+package proxyproto
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Shutdown gracefully shuts the listener down: it closes it, then waits for
+// the connections it accepted to be closed. Once ctx is done, the remaining
+// connections are closed and its error is returned. As for
+// http.Server.Shutdown, the connections aren't interrupted until then, so
+// the handlers must notice the shutdown by themselves to wrap up, e.g. by
+// watching a context canceled along with Shutdown.
+//
+// Only the connections wrapped in a Conn are waited for, and only if
+// TrackConns or MaxConns is set: otherwise Shutdown just closes the
+// listener. The ones skipping the PROXY protocol, as the policy decided, are
+// returned as they are and belong to the caller.
+func (p *Listener) Shutdown(ctx context.Context) error {
+	err := p.Close()
+	if errors.Is(err, net.ErrClosed) {
+		// Closed by Serve or a previous call
+		err = nil
+	}
+
+	p.connsMu.Lock()
+	if len(p.conns) == 0 {
+		p.connsMu.Unlock()
+		return err
+	}
+	if p.drained == nil {
+		p.drained = make(chan struct{})
+	}
+	drained := p.drained
+	p.connsMu.Unlock()
+
+	select {
+	case <-drained:
+		return err
+	case <-ctx.Done():
+	}
+
+	p.connsMu.Lock()
+	conns := make([]*Conn, 0, len(p.conns))
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	p.connsMu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return ctx.Err()
+}
+
+// track records conn as accepted by the listener until it is closed or
+// detached.
+func (p *Listener) track(conn *Conn) {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+	if p.conns == nil {
+		p.conns = make(map[*Conn]struct{})
+	}
+	p.conns[conn] = struct{}{}
+	conn.tracked = true
+}
+
+// untrack forgets conn, waking Shutdown up once no connection is left.
+func (p *Listener) untrack(conn *Conn) {
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+	delete(p.conns, conn)
+	if p.connFreed != nil {
+		p.connFreed.Signal()
+	}
+	if len(p.conns) == 0 && p.drained != nil {
+		close(p.drained)
+		p.drained = nil
+	}
+}
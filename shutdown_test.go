@@ -0,0 +1,116 @@
+package proxyproto
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenerShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, TrackConns: true}
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pl.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("bad: returned before the connection was closed: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if _, err := pl.Accept(); err == nil {
+		t.Fatal("bad: still accepting")
+	}
+
+	conn.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown didn't return once the connection was closed")
+	}
+}
+
+func TestListenerShutdownDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, TrackConns: true}
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pl.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The connection left was closed
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := pl.Shutdown(context.Background()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestListenerUntrackedConns(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	// Nothing refers to the connection, which may be collected if dropped
+	if len(pl.conns) != 0 || conn.(*Conn).tracked {
+		t.Fatal("bad: connection tracked")
+	}
+	if pl.Conns() != 0 {
+		t.Fatalf("bad: %d", pl.Conns())
+	}
+	// Shutdown doesn't wait for it
+	if err := pl.Shutdown(context.Background()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
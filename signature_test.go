@@ -0,0 +1,50 @@
+package proxyproto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignatureVersion(t *testing.T) {
+	v2 := append(bytes.Clone(SIGV2), 0x21, 0x11, 0x00, 0x0C)
+	v1 := []byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n")
+
+	var inputs [][]byte
+	for _, b := range [][]byte{v2, v1, []byte("GET / HTTP/1.1\r\n")} {
+		// Every length, with every byte of the signature flipped
+		for n := 0; n <= len(b); n++ {
+			inputs = append(inputs, b[:n])
+			for i := 0; i < n && i < len(SIGV2); i++ {
+				flipped := bytes.Clone(b[:n])
+				flipped[i] ^= 0x01
+				inputs = append(inputs, flipped)
+			}
+		}
+	}
+
+	for _, b := range inputs {
+		want := byte(0)
+		if bytes.HasPrefix(b, SIGV2) {
+			want = 2
+		} else if bytes.HasPrefix(b, SIGV1) {
+			want = 1
+		}
+		if got := SignatureVersion(b); got != want {
+			t.Fatalf("bad: %d for %q, want %d", got, b, want)
+		}
+	}
+}
+
+func BenchmarkSignatureVersion(b *testing.B) {
+	datagram := append(bytes.Clone(SIGV2), make([]byte, 64)...)
+	b.Run("SignatureVersion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			SignatureVersion(datagram)
+		}
+	})
+	b.Run("bytes.Equal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = bytes.Equal(datagram[:len(SIGV2)], SIGV2)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+)
+
+// TLS constants needed to locate the server_name extension, see RFC 8446
+// section 4 and RFC 6066 section 3.
+const (
+	tlsRecordTypeHandshake  = 0x16
+	tlsHandshakeClientHello = 0x01
+	tlsExtensionServerName  = 0x0000
+	tlsServerNameHostName   = 0x00
+	tlsRecordHeaderLen      = 5
+)
+
+// SNIPolicyFunc can be used to decide how to treat the PROXY header based on
+// the server name the client asks for in its TLS ClientHello. It is called
+// after the header has been read, with a nil header when none was sent, and
+// an empty serverName when the client didn't send a ClientHello or didn't
+// use SNI.
+//
+// The returned policy replaces the connection's policy. As the header has
+// already been read, SKIP is handled as USE: the header, if any, is used.
+// In case an error is returned the connection is denied.
+type SNIPolicyFunc func(serverName string, header *Header) (Policy, error)
+
+// WithSNIPolicy adds given SNI policy to a connection when passed as option to NewConn()
+func WithSNIPolicy(f SNIPolicyFunc) func(*Conn) {
+	return func(c *Conn) {
+		if f != nil {
+			c.SNIPolicy = f
+		}
+	}
+}
+
+// peekServerName returns the SNI host name of the TLS ClientHello found at
+// the head of the reader, without consuming it. An empty string is returned
+// if the bytes aren't a ClientHello or carry no server name.
+//
+// Only the first TLS record is inspected, up to the reader's buffer size.
+// This is enough in practice: clients send the server_name extension early
+// and in a single record.
+func peekServerName(reader *bufio.Reader) string {
+	hdr, err := reader.Peek(tlsRecordHeaderLen)
+	if err != nil || hdr[0] != tlsRecordTypeHandshake {
+		return ""
+	}
+
+	size := tlsRecordHeaderLen + int(binary.BigEndian.Uint16(hdr[3:5]))
+	if size > reader.Size() {
+		size = reader.Size()
+	}
+
+	// A short peek returns what is available along with the error, which is
+	// fine to parse as far as it goes
+	record, _ := reader.Peek(size)
+	return parseServerName(record[tlsRecordHeaderLen:])
+}
+
+// parseServerName extracts the SNI host name from a ClientHello handshake
+// message. Truncated or malformed messages yield an empty string.
+func parseServerName(hs []byte) string {
+	// Handshake type (1) + length (3) + client version (2) + random (32)
+	if len(hs) < 38 || hs[0] != tlsHandshakeClientHello {
+		return ""
+	}
+	b := hs[38:]
+
+	// Session ID
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return ""
+	}
+	b = b[1+int(b[0]):]
+
+	// Cipher suites
+	if len(b) < 2 {
+		return ""
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return ""
+	}
+	b = b[2+n:]
+
+	// Compression methods
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return ""
+	}
+	b = b[1+int(b[0]):]
+
+	// Extensions
+	if len(b) < 2 {
+		return ""
+	}
+	b = b[2:]
+	for len(b) >= 4 {
+		extType := binary.BigEndian.Uint16(b)
+		extLen := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+		if len(b) < extLen {
+			return ""
+		}
+		if extType == tlsExtensionServerName {
+			return parseServerNameList(b[:extLen])
+		}
+		b = b[extLen:]
+	}
+
+	return ""
+}
+
+// parseServerNameList returns the first host_name of a server_name extension.
+func parseServerNameList(b []byte) string {
+	if len(b) < 2 {
+		return ""
+	}
+	b = b[2:]
+	for len(b) >= 3 {
+		nameType := b[0]
+		nameLen := int(binary.BigEndian.Uint16(b[1:]))
+		b = b[3:]
+		if len(b) < nameLen {
+			return ""
+		}
+		if nameType == tlsServerNameHostName {
+			return string(b[:nameLen])
+		}
+		b = b[nameLen:]
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+// clientHello returns the first flight a TLS client sends for serverName.
+func clientHello(t *testing.T, serverName string) []byte {
+	server, client := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		tlsConn := tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		_ = tlsConn.Handshake()
+		client.Close()
+	}()
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestPeekServerName(t *testing.T) {
+	hello := clientHello(t, "example.com")
+
+	reader := bufio.NewReader(bytes.NewReader(hello))
+	if serverName := peekServerName(reader); serverName != "example.com" {
+		t.Fatalf("bad: %q", serverName)
+	}
+	if reader.Buffered() != len(hello) {
+		t.Fatalf("bad: ClientHello was consumed, %d bytes left", reader.Buffered())
+	}
+
+	// Truncated ClientHellos and other protocols yield no server name
+	for _, b := range [][]byte{hello[:20], hello[:60], []byte("GET / HTTP/1.1\r\n\r\n")} {
+		if serverName := peekServerName(bufio.NewReader(bytes.NewReader(b))); serverName != "" {
+			t.Fatalf("bad: %q", serverName)
+		}
+	}
+}
+
+func TestSNIPolicy(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr: &net.TCPAddr{
+			IP:   net.ParseIP("10.1.1.1"),
+			Port: 1000,
+		},
+		DestinationAddr: &net.TCPAddr{
+			IP:   net.ParseIP("20.2.2.2"),
+			Port: 2000,
+		},
+	}
+
+	policy := func(serverName string, h *Header) (Policy, error) {
+		if h == nil {
+			t.Error("err: policy should receive the parsed header")
+		}
+		switch serverName {
+		case "internal.example.com":
+			return REJECT, nil
+		case "skipped.example.com":
+			return SKIP, nil
+		}
+		return USE, nil
+	}
+
+	tests := []struct {
+		serverName  string
+		expectedErr error
+	}{
+		{serverName: "public.example.com"},
+		{serverName: "internal.example.com", expectedErr: ErrSuperfluousProxyHeader},
+		// The header already read is used, as with USE
+		{serverName: "skipped.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			hello := clientHello(t, tt.serverName)
+
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			pl := &Listener{Listener: l, SNIPolicy: policy}
+			defer pl.Close()
+
+			go func() {
+				conn, err := net.Dial("tcp", pl.Addr().String())
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				raw, _ := header.Format()
+				_, _ = conn.Write(append(raw, hello...))
+				_, _ = conn.Read(make([]byte, 1))
+			}()
+
+			conn, err := pl.Accept()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			defer conn.Close()
+
+			recv := make([]byte, len(hello))
+			_, err = conn.Read(recv)
+			if err != tt.expectedErr {
+				t.Fatalf("bad: expected %v, actual %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+
+			// The ClientHello is still there for the TLS server to read
+			if !bytes.Equal(recv[:tlsRecordHeaderLen], hello[:tlsRecordHeaderLen]) {
+				t.Fatalf("bad: %v", recv[:tlsRecordHeaderLen])
+			}
+			if addr := conn.RemoteAddr().String(); addr != "10.1.1.1:1000" {
+				t.Fatalf("bad: %v", addr)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package proxyproto
+
+import (
+	"net"
+	"syscall"
+)
+
+// setQuickAck enables TCP_QUICKACK on the socket backing tcpConn.
+//
+// The option is set through the connection's syscall.RawConn so the runtime
+// keeps ownership of the descriptor. TCPConn.File() must not be used here: it
+// duplicates the descriptor and switches the socket to blocking mode, which
+// silently breaks read deadlines on the original connection.
+func setQuickAck(tcpConn *net.TCPConn) error {
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		// TCP_QUICKACK (12) - enable quickack mode
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, 12, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
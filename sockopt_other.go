@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package proxyproto
+
+import "net"
+
+// setQuickAck is a no-op on platforms without TCP_QUICKACK.
+func setQuickAck(tcpConn *net.TCPConn) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// NewConnFromReadWriter wraps a stream which isn't a net.Conn, e.g. a
+// WebSocket tunnel, an SSH channel or a vsock wrapper, into a Conn. local and
+// remote are the addresses reported in place of the ones of a socket,
+// typically those of the transport, and may be nil.
+//
+// Deadlines are forwarded to rwc if it has SetDeadline, SetReadDeadline or
+// SetWriteDeadline methods, and ignored otherwise: reading the header of a
+// stream without read deadlines can't time out.
+func NewConnFromReadWriter(rwc io.ReadWriteCloser, local, remote net.Addr, opts ...func(*Conn)) *Conn {
+	if local == nil {
+		local = streamAddr("local")
+	}
+	if remote == nil {
+		remote = streamAddr("remote")
+	}
+	return NewConn(&streamConn{ReadWriteCloser: rwc, local: local, remote: remote}, opts...)
+}
+
+// streamAddr is the address of a stream with unknown endpoints.
+type streamAddr string
+
+func (a streamAddr) Network() string { return "stream" }
+func (a streamAddr) String() string  { return string(a) }
+
+// streamConn adapts an io.ReadWriteCloser to net.Conn.
+type streamConn struct {
+	io.ReadWriteCloser
+	local, remote net.Addr
+}
+
+func (c *streamConn) LocalAddr() net.Addr  { return c.local }
+func (c *streamConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *streamConn) SetDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetDeadline(time.Time) error }); ok {
+		return d.SetDeadline(t)
+	}
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *streamConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (c *streamConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.ReadWriteCloser.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type pipeStream struct {
+	io.Reader
+	io.Writer
+}
+
+func (pipeStream) Close() error { return nil }
+
+func TestNewConnFromReadWriter(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\nhello"))
+		w.Close()
+	}()
+
+	transport := &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 22}
+	conn := NewConnFromReadWriter(pipeStream{Reader: r, Writer: io.Discard}, nil, transport, SetReadHeaderTimeout(0))
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("bad: %q", b)
+	}
+	if conn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", conn.RemoteAddr())
+	}
+	if conn.Raw().RemoteAddr() != transport || conn.Raw().LocalAddr().Network() != "stream" {
+		t.Fatalf("bad: %v, %v", conn.Raw().RemoteAddr(), conn.Raw().LocalAddr())
+	}
+}
+
+func TestNewConnFromReadWriterDeadlines(t *testing.T) {
+	// A net.Conn passed as a stream keeps its deadlines
+	server, client := net.Pipe()
+	defer client.Close()
+
+	conn := NewConnFromReadWriter(server, nil, nil, WithPolicy(REQUIRE), SetReadHeaderTimeout(10*time.Millisecond))
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err != ErrNoProxyProtocol {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package proxyproto
+
+import "fmt"
+
+var (
+	// ErrDuplicateTLV is returned for a registered TLV type found more than
+	// once in a header, see StrictTLVs.
+	ErrDuplicateTLV = newError(ErrCodeMalformedTLV, "proxyproto: duplicate TLV")
+	// ErrEmptyTLV is returned for an empty TLV of a type whose value can't
+	// be empty, see StrictTLVs.
+	ErrEmptyTLV = newError(ErrCodeMalformedTLV, "proxyproto: empty TLV")
+	// ErrTLVTooLong is returned for a TLV longer than the spec allows for
+	// its type, see StrictTLVs.
+	ErrTLVTooLong = newError(ErrCodeOverflow, "proxyproto: TLV too long")
+)
+
+// TLVError is returned when a TLV of a header fails the checks of
+// StrictTLVs. Err is ErrDuplicateTLV, ErrEmptyTLV, ErrTLVTooLong or
+// ErrMalformedTLV.
+type TLVError struct {
+	Type PP2Type
+	Err  error
+}
+
+func (e *TLVError) Error() string {
+	return fmt.Sprintf("%v: type 0x%02x", e.Err, byte(e.Type))
+}
+
+func (e *TLVError) Unwrap() error {
+	return e.Err
+}
+
+// StrictTLVs checks the TLVs of the header of a connection with
+// CheckStrictTLVs when passed as option to NewConn(). The header is refused
+// with the returned *TLVError.
+func StrictTLVs() func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.strictTLVs = true
+	}
+}
+
+// CheckStrictTLVs returns a *TLVError if the TLVs of header don't follow the
+// spec to the letter:
+//
+//   - Registered types other than NOOP appear at most once. Custom and
+//     experimental types may repeat, e.g. with different subtypes.
+//   - ALPN, AUTHORITY, NETNS and SSL values aren't empty.
+//   - A UNIQUE_ID value is at most MaxUniqueIDLen bytes long.
+//   - A CRC32C value is exactly 4 bytes long.
+//
+// TLVs which can't be split fail with the error of TLVs. It can be used as
+// Validator.
+func CheckStrictTLVs(header *Header) error {
+	tlvs, err := header.TLVs()
+	if err != nil {
+		return err
+	}
+
+	var seen [256]bool
+	for _, tlv := range tlvs {
+		if tlv.Type.Registered() && tlv.Type != PP2_TYPE_NOOP {
+			if seen[tlv.Type] {
+				return &TLVError{Type: tlv.Type, Err: ErrDuplicateTLV}
+			}
+			seen[tlv.Type] = true
+		}
+
+		switch tlv.Type {
+		case PP2_TYPE_ALPN, PP2_TYPE_AUTHORITY, PP2_TYPE_NETNS, PP2_TYPE_SSL:
+			if len(tlv.Value) == 0 {
+				return &TLVError{Type: tlv.Type, Err: ErrEmptyTLV}
+			}
+		case PP2_TYPE_UNIQUE_ID:
+			if len(tlv.Value) > MaxUniqueIDLen {
+				return &TLVError{Type: tlv.Type, Err: ErrTLVTooLong}
+			}
+		case PP2_TYPE_CRC32C:
+			if len(tlv.Value) != 4 {
+				return &TLVError{Type: tlv.Type, Err: ErrMalformedTLV}
+			}
+		}
+	}
+	return nil
+}
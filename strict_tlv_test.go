@@ -0,0 +1,64 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestStrictTLVs(t *testing.T) {
+	tests := []struct {
+		name string
+		tlvs []TLV
+		err  error
+		typ  PP2Type
+	}{
+		{"valid", []TLV{
+			{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")},
+			{Type: PP2_TYPE_NOOP}, {Type: PP2_TYPE_NOOP},
+			{Type: PP2Type(0xEA), Value: []byte{0x01}}, {Type: PP2Type(0xEA), Value: []byte{0x02}},
+		}, nil, 0},
+		{"duplicate", []TLV{
+			{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")},
+			{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.net")},
+		}, ErrDuplicateTLV, PP2_TYPE_AUTHORITY},
+		{"empty", []TLV{{Type: PP2_TYPE_ALPN}}, ErrEmptyTLV, PP2_TYPE_ALPN},
+		{"unique ID too long", []TLV{{Type: PP2_TYPE_UNIQUE_ID, Value: make([]byte, MaxUniqueIDLen+1)}}, ErrTLVTooLong, PP2_TYPE_UNIQUE_ID},
+		{"short checksum", []TLV{{Type: PP2_TYPE_CRC32C, Value: []byte{1, 2}}}, ErrMalformedTLV, PP2_TYPE_CRC32C},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := HeaderProxyFromAddrs(2,
+				&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+				&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+			)
+			if err := header.SetTLVs(tt.tlvs); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			raw, err := header.Format()
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+
+			if _, err := Read(bufio.NewReader(bytes.NewReader(raw))); err != nil {
+				t.Fatalf("bad: lenient parsing returned %v", err)
+			}
+			_, err = (parseOptions{strictTLVs: true}).read(bufio.NewReader(bytes.NewReader(raw)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("bad: %v, want %v", err, tt.err)
+			}
+			if tt.err == nil {
+				return
+			}
+			var tlvErr *TLVError
+			if !errors.As(err, &tlvErr) || tlvErr.Type != tt.typ {
+				t.Fatalf("bad: %#v", err)
+			}
+			if CodeOf(err) == ErrCodeUnknown {
+				t.Fatalf("bad: no code for %v", err)
+			}
+		})
+	}
+}
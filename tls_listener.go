@@ -0,0 +1,168 @@
+package proxyproto
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// TLSListener accepts TLS connections whose PROXY header precedes the TLS
+// handshake, as sent by TCP load balancers in front of TLS servers. The
+// proxy policies apply to the TCP connections, before any TLS byte is read.
+type TLSListener struct {
+	proxy  *Listener
+	config *tls.Config
+	// budget is the time.Duration of SetBudget
+	budget atomic.Int64
+}
+
+// NewTLSListener returns a TLSListener wrapping inner, terminating TLS with
+// config once the PROXY header has been handled according to opts, see
+// Listener.UpdateOptions. Listener returns the underlying Listener for the
+// settings not covered by ListenerOptions.
+//
+// It does what tls.NewListener(&Listener{Listener: inner}, config) does,
+// whereas nesting the listeners the other way around would wrongly look for
+// the PROXY header in the decrypted stream.
+func NewTLSListener(inner net.Listener, config *tls.Config, opts ...ListenerOption) *TLSListener {
+	proxy := &Listener{Listener: inner}
+	if len(opts) > 0 {
+		proxy.UpdateOptions(opts...)
+	}
+	return &TLSListener{proxy: proxy, config: config}
+}
+
+// Accept waits for and returns the next connection, a *TLSConn. As with
+// tls.Listener, the handshake happens on the first read or write, or when
+// calling Handshake.
+func (l *TLSListener) Accept() (net.Conn, error) {
+	conn, err := l.proxy.Accept()
+	if err != nil {
+		return nil, err
+	}
+	proxyConn := asConn(conn)
+	tlsConn := &TLSConn{Conn: tls.Server(proxyConn, l.config), proxy: proxyConn}
+	if budget := l.Budget(); budget > 0 {
+		tlsConn.deadline = time.Now().Add(budget)
+	}
+	return tlsConn, nil
+}
+
+// SetBudget bounds the time from accepting a connection to the end of its
+// TLS handshake, PROXY header read included, so that a client can't take
+// the read header timeout and then a handshake timeout back to back. The
+// connection is closed once it runs out. Zero means no budget. It may be
+// called while the listener is accepting, and applies to the connections
+// accepted afterwards.
+func (l *TLSListener) SetBudget(budget time.Duration) {
+	l.budget.Store(int64(budget))
+}
+
+// Budget returns the budget set by SetBudget.
+func (l *TLSListener) Budget() time.Duration {
+	return time.Duration(l.budget.Load())
+}
+
+// Close closes the underlying listener.
+func (l *TLSListener) Close() error {
+	return l.proxy.Close()
+}
+
+// Addr returns the underlying listener's network address.
+func (l *TLSListener) Addr() net.Addr {
+	return l.proxy.Addr()
+}
+
+// Listener returns the proxy protocol listener the TLS connections are
+// accepted from.
+func (l *TLSListener) Listener() *Listener {
+	return l.proxy
+}
+
+// TLSConn is a TLS connection accepted by a TLSListener. Its RemoteAddr and
+// LocalAddr are the ones of the PROXY header, if any, and ConnectionState
+// describes the TLS layer.
+type TLSConn struct {
+	*tls.Conn
+	proxy *Conn
+	// deadline ends the budget of the handshake, if any
+	deadline time.Time
+	// handshaken is set once the handshake has succeeded, sparing the
+	// reads and writes a call to HandshakeContext
+	handshaken atomic.Bool
+}
+
+// Handshake runs the TLS handshake, reading the PROXY header first, if it
+// hasn't been yet. See HandshakeContext.
+func (c *TLSConn) Handshake() error {
+	return c.HandshakeContext(context.Background())
+}
+
+// HandshakeContext runs the TLS handshake, reading the PROXY header first,
+// if it hasn't been yet. It fails once ctx is done or the budget of the
+// listener is exhausted.
+func (c *TLSConn) HandshakeContext(ctx context.Context) error {
+	if c.handshaken.Load() {
+		return nil
+	}
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	if err := c.Conn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	c.handshaken.Store(true)
+	return nil
+}
+
+// handshake runs the handshake within the budget of the listener, if any,
+// before the first read or write. The handshake tls.Conn runs on its own
+// isn't bounded by the budget.
+func (c *TLSConn) handshake() error {
+	if c.deadline.IsZero() || c.handshaken.Load() {
+		return nil
+	}
+	return c.Handshake()
+}
+
+// Read reads data from the connection, running the handshake first if
+// needed.
+func (c *TLSConn) Read(b []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// Write writes data to the connection, running the handshake first if
+// needed.
+func (c *TLSConn) Write(b []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// ProxyHeader returns the proxy protocol header, if any.
+func (c *TLSConn) ProxyHeader() *Header {
+	return c.proxy.ProxyHeader()
+}
+
+// ProxyConn returns the proxy protocol connection carrying the TLS layer.
+func (c *TLSConn) ProxyConn() *Conn {
+	return c.proxy
+}
+
+// asConn returns conn, accepted by a Listener, as a Conn: connections
+// accepted with the SKIP policy are wrapped in a Conn which doesn't read any
+// header.
+func asConn(conn net.Conn) *Conn {
+	if proxyConn, ok := conn.(*Conn); ok {
+		return proxyConn
+	}
+	return NewConn(conn, WithPolicy(SKIP))
+}
@@ -0,0 +1,178 @@
+package proxyproto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTLSListener(t *testing.T) {
+	cert, err := tls.X509KeyPair(LocalhostCert, LocalhostKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tl := NewTLSListener(l, &tls.Config{Certificates: []tls.Certificate{cert}}, func(o *ListenerOptions) {
+		o.Policy = func(net.Addr) (Policy, error) { return REQUIRE, nil }
+	})
+	defer tl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", tl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+		certpool := x509.NewCertPool()
+		certpool.AppendCertsFromPEM(LocalhostCert)
+		client := tls.Client(conn, &tls.Config{RootCAs: certpool, ServerName: "127.0.0.1"})
+		client.Write([]byte("ping"))
+		client.Close()
+	}()
+
+	conn, err := tl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	tlsConn := conn.(*TLSConn)
+
+	b, err := io.ReadAll(tlsConn)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(b) != "ping" {
+		t.Fatalf("bad: %q", b)
+	}
+	if !tlsConn.ConnectionState().HandshakeComplete {
+		t.Fatal("bad: handshake not complete")
+	}
+	if tlsConn.ProxyHeader() == nil || tlsConn.RemoteAddr().String() != "10.1.1.1:1000" {
+		t.Fatalf("bad: %v", tlsConn.RemoteAddr())
+	}
+}
+
+func TestTLSListenerBudget(t *testing.T) {
+	cert, err := tls.X509KeyPair(LocalhostCert, LocalhostKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tl := NewTLSListener(l, &tls.Config{Certificates: []tls.Certificate{cert}})
+	tl.SetBudget(50 * time.Millisecond)
+	defer tl.Close()
+
+	for _, sent := range []string{"", "PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"} {
+		// The client stalls within the header, or after it
+		client, err := net.Dial("tcp", tl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer client.Close()
+		client.Write([]byte(sent))
+
+		conn, err := tl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+
+		start := time.Now()
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Fatal("bad: read succeeded")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("bad: budget exceeded by %v", elapsed)
+		}
+	}
+}
+
+func TestTLSListenerBudgetHandshakeOnce(t *testing.T) {
+	cert, err := tls.X509KeyPair(LocalhostCert, LocalhostKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tl := NewTLSListener(l, &tls.Config{Certificates: []tls.Certificate{cert}})
+	tl.SetBudget(5 * time.Second)
+	defer tl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", tl.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n"))
+
+		certpool := x509.NewCertPool()
+		certpool.AppendCertsFromPEM(LocalhostCert)
+		client := tls.Client(conn, &tls.Config{RootCAs: certpool, ServerName: "127.0.0.1"})
+		io.Copy(io.Discard, client)
+	}()
+
+	conn, err := tl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	tlsConn := conn.(*TLSConn)
+	if _, err := tlsConn.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Once done, the handshake costs the reads and writes nothing
+	if allocs := testing.AllocsPerRun(100, func() {
+		if err := tlsConn.handshake(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}); allocs != 0 {
+		t.Fatalf("bad: %v allocations", allocs)
+	}
+}
+
+func TestTLSListenerSetBudgetWhileAccepting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tl := NewTLSListener(l, &tls.Config{})
+	defer tl.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := tl.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	// SetBudget may be called while Accept runs, checked by go test -race
+	tl.SetBudget(time.Second)
+	client, err := net.Dial("tcp", tl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	tl.SetBudget(2 * time.Second)
+	if err := <-accepted; err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tl.Budget() != 2*time.Second {
+		t.Fatalf("bad: %v", tl.Budget())
+	}
+}
@@ -4,7 +4,6 @@
 package proxyproto
 
 import (
-	"errors"
 	"fmt"
 	"math"
 )
@@ -34,9 +33,10 @@ const (
 )
 
 var (
-	ErrTruncatedTLV    = errors.New("proxyproto: truncated TLV")
-	ErrMalformedTLV    = errors.New("proxyproto: malformed TLV Value")
-	ErrIncompatibleTLV = errors.New("proxyproto: incompatible TLV type")
+	ErrTruncatedTLV    = newError(ErrCodeTruncatedTLV, "proxyproto: truncated TLV")
+	ErrMalformedTLV    = newError(ErrCodeMalformedTLV, "proxyproto: malformed TLV Value")
+	ErrIncompatibleTLV = newError(ErrCodeMalformedTLV, "proxyproto: incompatible TLV type")
+	ErrTooManyTLVs     = newError(ErrCodeTooManyTLVs, "proxyproto: too many TLVs")
 )
 
 // PP2Type is the proxy protocol v2 type
@@ -50,6 +50,14 @@ type TLV struct {
 
 // SplitTLVs splits the Type-Length-Value vector with minimal copying.
 func SplitTLVs(raw []byte) ([]TLV, error) {
+	return SplitTLVsLimit(raw, 0)
+}
+
+// SplitTLVsLimit acts as SplitTLVs, but fails with ErrTooManyTLVs once the
+// vector holds more than maxCount TLVs, before splitting the rest, so that
+// a crafted vector of thousands of tiny TLVs can't be expanded. Zero means
+// no limit.
+func SplitTLVsLimit(raw []byte, maxCount int) ([]TLV, error) {
 	if len(raw) == 0 {
 		return nil, nil
 	}
@@ -59,6 +67,10 @@ func SplitTLVs(raw []byte) ([]TLV, error) {
 
 	// Process the byte slice directly without intermediate allocations
 	for i := 0; i < len(raw); {
+		if maxCount > 0 && len(tlvs) == maxCount {
+			return nil, ErrTooManyTLVs
+		}
+
 		// Ensure we have at least 3 bytes (type + 2-byte length)
 		if len(raw)-i < 3 {
 			return nil, ErrTruncatedTLV
@@ -77,8 +89,11 @@ func SplitTLVs(raw []byte) ([]TLV, error) {
 			return nil, ErrTruncatedTLV
 		}
 
-		// Process the value
-		if tlvType != PP2_TYPE_NOOP {
+		// Process the value. NOOP TLVs are kept so callers see the full
+		// vector, but their padding is never copied.
+		if tlvType == PP2_TYPE_NOOP {
+			tlvs = append(tlvs, TLV{Type: tlvType})
+		} else {
 			var tlvValue []byte
 
 			// For small values, make a copy to avoid referencing the larger raw buffer
@@ -104,6 +119,19 @@ func SplitTLVs(raw []byte) ([]TLV, error) {
 	return tlvs, nil
 }
 
+// countTLVs returns the number of TLVs in raw, stopping early once more than
+// limit have been seen. A truncated trailing TLV isn't counted.
+func countTLVs(raw []byte, limit int) int {
+	count := 0
+	for i := 0; i+3 <= len(raw) && count <= limit; count++ {
+		i += 3 + ((int(raw[i+1]) << 8) | int(raw[i+2]))
+		if i > len(raw) {
+			break
+		}
+	}
+	return count
+}
+
 // JoinTLVs joins multiple Type-Length-Value records with minimal copying.
 func JoinTLVs(tlvs []TLV) ([]byte, error) {
 	if len(tlvs) == 0 {
@@ -190,3 +218,38 @@ func (p PP2Type) Future() bool {
 func (p PP2Type) Spec() bool {
 	return p.Registered() || p.App() || p.Experiment() || p.Future()
 }
+
+// tlvValue returns the value of the first TLV of type t of the header, and
+// whether there is one.
+func (header *Header) tlvValue(t PP2Type) ([]byte, bool) {
+	tlvs, err := header.TLVs()
+	if err != nil {
+		return nil, false
+	}
+	for _, tlv := range tlvs {
+		if tlv.Type == t {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// replaceTLV replaces the TLVs of type t of the header with one carrying
+// value, keeping the other TLVs. An empty value removes them.
+func (header *Header) replaceTLV(t PP2Type, value []byte) error {
+	tlvs, err := header.TLVs()
+	if err != nil {
+		return err
+	}
+
+	kept := tlvs[:0]
+	for _, tlv := range tlvs {
+		if tlv.Type != t {
+			kept = append(kept, tlv)
+		}
+	}
+	if len(value) > 0 {
+		kept = append(kept, TLV{Type: t, Value: value})
+	}
+	return header.SetTLVs(kept)
+}
@@ -0,0 +1,36 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+)
+
+// WithTLVLimits bounds the TLVs of the version 2 header of a connection
+// when passed as option to NewConn(): headers carrying more than maxBytes
+// bytes of TLVs fail with ErrHeaderTooLarge before anything is buffered for
+// them, and headers carrying more than maxCount TLVs fail with
+// ErrTooManyTLVs. Zero means no limit.
+//
+// Without limits, a hostile upstream can make every connection hold up to
+// 64 KiB of TLVs.
+func WithTLVLimits(maxBytes, maxCount int) func(*Conn) {
+	return func(c *Conn) {
+		c.parseOpts.maxTLVBytes = maxBytes
+		c.parseOpts.maxTLVCount = maxCount
+	}
+}
+
+// checkTLVBytes checks the length of the TLVs of the version 2 header at
+// the start of reader from its length field. Headers which are truncated or
+// have an invalid length are left to parseVersion2, which fails for them.
+func checkTLVBytes(reader *bufio.Reader, maxBytes int) error {
+	prefix, err := reader.Peek(v2HeaderPrefixLen)
+	if err != nil {
+		return nil
+	}
+	length := int(binary.BigEndian.Uint16(prefix[14:v2HeaderPrefixLen]))
+	if length-addressBlockLen(AddressFamilyAndProtocol(prefix[13])) > maxBytes {
+		return ErrHeaderTooLarge
+	}
+	return nil
+}
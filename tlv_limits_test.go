@@ -0,0 +1,85 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestWithTLVLimits(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetTLVs([]TLV{
+		{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")},
+		{Type: PP2_TYPE_UNIQUE_ID, Value: []byte("req-42")},
+	}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	tlvBytes := len(header.rawTLVs)
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		maxCount int
+		err      error
+	}{
+		{"no limit", 0, 0, nil},
+		{"within limits", tlvBytes, 2, nil},
+		{"too many bytes", tlvBytes - 1, 0, ErrHeaderTooLarge},
+		{"too many TLVs", 0, 1, ErrTooManyTLVs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(bytes.NewReader(raw))
+			opts := parseOptions{maxTLVBytes: tt.maxBytes, maxTLVCount: tt.maxCount}
+			if _, err := opts.read(reader); err != tt.err {
+				t.Fatalf("bad: %v, want %v", err, tt.err)
+			}
+			// The TLVs aren't buffered when there are too many bytes
+			if tt.err == ErrHeaderTooLarge && reader.Buffered() != len(raw) {
+				t.Fatalf("bad: %d bytes consumed", len(raw)-reader.Buffered())
+			}
+		})
+	}
+}
+
+func TestListenerTLVLimits(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l, MaxTLVBytes: 16}
+	defer pl.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetTLVs([]TLV{{Type: PP2Type(0xE0), Value: make([]byte, 1024)}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	client, err := net.Dial("tcp", pl.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer client.Close()
+	if _, err := header.WriteTo(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err != ErrHeaderTooLarge {
+		t.Fatalf("bad: %v", err)
+	}
+}
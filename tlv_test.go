@@ -148,3 +148,43 @@ func TestJoinTLVs(t *testing.T) {
 		})
 	}
 }
+
+func TestMaxTLVCount(t *testing.T) {
+	emptyTLV := []byte{byte(PP2_TYPE_MIN_CUSTOM), 0x00, 0x00}
+	raw := bytes.Repeat(emptyTLV, 3)
+	opts := parseOptions{maxTLVCount: 2}
+
+	r := newBufioReader(append(append(SIGV2, byte(PROXY), byte(TCPv4)), fixtureWithTLV(lengthV4Bytes, fixtureIPv4Address, raw)...))
+	if _, err := opts.read(r); err != ErrTooManyTLVs {
+		t.Fatalf("expected %v, got %v", ErrTooManyTLVs, err)
+	}
+
+	r = newBufioReader(append(append(SIGV2, byte(PROXY), byte(TCPv4)), fixtureWithTLV(lengthV4Bytes, fixtureIPv4Address, raw[:6])...))
+	if _, err := opts.read(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The limit only applies to the connections it is given to
+	r = newBufioReader(append(append(SIGV2, byte(PROXY), byte(TCPv4)), fixtureWithTLV(lengthV4Bytes, fixtureIPv4Address, raw)...))
+	header, err := Read(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tlvs, err := header.TLVs(); err != nil || len(tlvs) != 3 {
+		t.Fatalf("unexpected result %v, %v", tlvs, err)
+	}
+	if _, err := header.TLVsLimit(2); err != ErrTooManyTLVs {
+		t.Fatalf("expected %v, got %v", ErrTooManyTLVs, err)
+	}
+	if tlvs, err := header.TLVsLimit(3); err != nil || len(tlvs) != 3 {
+		t.Fatalf("unexpected result %v, %v", tlvs, err)
+	}
+
+	// As for direct callers of SplitTLVsLimit
+	if _, err := SplitTLVsLimit(raw, 2); err != ErrTooManyTLVs {
+		t.Fatalf("expected %v, got %v", ErrTooManyTLVs, err)
+	}
+	if tlvs, err := SplitTLVsLimit(raw, 3); err != nil || len(tlvs) != 3 {
+		t.Fatalf("unexpected result %v, %v", tlvs, err)
+	}
+}
@@ -3,11 +3,7 @@
 
 package tlvparse
 
-import (
-	"regexp"
-
-	"github.com/iqhive/go-proxyproto"
-)
+import "github.com/iqhive/go-proxyproto"
 
 const (
 	// Amazon's extension
@@ -15,21 +11,14 @@ const (
 	PP2_SUBTYPE_AWS_VPCE_ID = 0x01
 )
 
-var vpceRe = regexp.MustCompile("^[A-Za-z0-9-]*$")
-
 func IsAWSVPCEndpointID(tlv proxyproto.TLV) bool {
-	return tlv.Type == PP2_TYPE_AWS && len(tlv.Value) > 0 && tlv.Value[0] == PP2_SUBTYPE_AWS_VPCE_ID
+	_, err := proxyproto.ParseAWSVPCEndpointID(tlv)
+	return err != proxyproto.ErrIncompatibleTLV
 }
 
+// AWSVPCEndpointID is proxyproto.ParseAWSVPCEndpointID.
 func AWSVPCEndpointID(tlv proxyproto.TLV) (string, error) {
-	if !IsAWSVPCEndpointID(tlv) {
-		return "", proxyproto.ErrIncompatibleTLV
-	}
-	vpce := string(tlv.Value[1:])
-	if !vpceRe.MatchString(vpce) {
-		return "", proxyproto.ErrMalformedTLV
-	}
-	return vpce, nil
+	return proxyproto.ParseAWSVPCEndpointID(tlv)
 }
 
 // FindAWSVPCEndpointID returns the first AWS VPC ID in the TLV if it exists and is well-formed.
@@ -75,6 +75,28 @@ func (s PP2SSL) SSLCipher() (string, bool) {
 	return "", false
 }
 
+// SSLSigAlg returns the US-ASCII string name of the algorithm used to sign the certificate presented by the
+// frontend, e.g. "SHA256", and whether that extension exists.
+func (s PP2SSL) SSLSigAlg() (string, bool) {
+	for _, tlv := range s.TLV {
+		if tlv.Type == proxyproto.PP2_SUBTYPE_SSL_SIG_ALG {
+			return string(tlv.Value), true
+		}
+	}
+	return "", false
+}
+
+// SSLKeyAlg returns the US-ASCII string name of the algorithm used to generate the key of the certificate
+// presented by the frontend, e.g. "RSA2048", and whether that extension exists.
+func (s PP2SSL) SSLKeyAlg() (string, bool) {
+	for _, tlv := range s.TLV {
+		if tlv.Type == proxyproto.PP2_SUBTYPE_SSL_KEY_ALG {
+			return string(tlv.Value), true
+		}
+	}
+	return "", false
+}
+
 // Marshal formats the PP2SSL structure as a TLV.
 func (s PP2SSL) Marshal() (proxyproto.TLV, error) {
 	v := make([]byte, 5)
@@ -148,10 +170,20 @@ func SSL(t proxyproto.TLV) (PP2SSL, error) {
 			if len(tlv.Value) == 0 || !utf8.Valid(tlv.Value) {
 				return PP2SSL{}, proxyproto.ErrMalformedTLV
 			}
-		case proxyproto.PP2_SUBTYPE_SSL_CIPHER:
+		case proxyproto.PP2_SUBTYPE_SSL_CIPHER, proxyproto.PP2_SUBTYPE_SSL_SIG_ALG, proxyproto.PP2_SUBTYPE_SSL_KEY_ALG:
 			/*
 				The second level TLV PP2_SUBTYPE_SSL_CIPHER provides the US-ASCII string name
 				of the used cipher, for example "ECDHE-RSA-AES128-GCM-SHA256".
+
+				The second level TLV PP2_SUBTYPE_SSL_SIG_ALG provides the US-ASCII string name
+				of the algorithm used to sign the certificate presented by the frontend when
+				the incoming connection was made over an SSL/TLS transport layer, for example
+				"SHA256".
+
+				The second level TLV PP2_SUBTYPE_SSL_KEY_ALG provides the US-ASCII string name
+				of the algorithm used to generate the key of the certificate presented by the
+				frontend when the incoming connection was made over an SSL/TLS transport layer,
+				for example "RSA2048".
 			*/
 			if len(tlv.Value) == 0 || !isASCII(tlv.Value) {
 				return PP2SSL{}, proxyproto.ErrMalformedTLV
@@ -0,0 +1,71 @@
+package tlvparse
+
+import (
+	"crypto/tls"
+
+	"github.com/iqhive/go-proxyproto"
+)
+
+// sslVersionNames are the OpenSSL names of the TLS versions, which the
+// PP2_SUBTYPE_SSL_VERSION sub-TLV usually carries.
+var sslVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLSv1",
+	tls.VersionTLS11: "TLSv1.1",
+	tls.VersionTLS12: "TLSv1.2",
+	tls.VersionTLS13: "TLSv1.3",
+}
+
+// SSLFromConnectionState describes the TLS connection of a client with the
+// PP2SSL of section 2.2.5, for TLS-terminating proxies forwarding it:
+//
+//   - The client field tells whether the handshake completed and the
+//     client presented a certificate, over this connection or only when
+//     the resumed session was established.
+//   - Verify is zero if the client presented a certificate which was
+//     verified, 1 otherwise.
+//   - The sub-TLVs carry the TLS version, e.g. "TLSv1.3", the IANA name of
+//     the cipher suite, e.g. "TLS_AES_128_GCM_SHA256", and the common name
+//     of the client certificate, if any.
+//
+// The algorithms of the certificate of the proxy aren't part of the state:
+// the PP2_SUBTYPE_SSL_SIG_ALG and PP2_SUBTYPE_SSL_KEY_ALG sub-TLVs can be
+// appended to TLV if needed.
+func SSLFromConnectionState(cs *tls.ConnectionState) PP2SSL {
+	ssl := PP2SSL{Verify: 1}
+	if cs == nil || !cs.HandshakeComplete {
+		return ssl
+	}
+
+	ssl.Client = PP2_BITFIELD_CLIENT_SSL
+	if len(cs.PeerCertificates) > 0 {
+		ssl.Client |= PP2_BITFIELD_CLIENT_CERT_SESS
+		if !cs.DidResume {
+			ssl.Client |= PP2_BITFIELD_CLIENT_CERT_CONN
+		}
+		if len(cs.VerifiedChains) > 0 {
+			ssl.Verify = 0
+		}
+	}
+
+	version, ok := sslVersionNames[cs.Version]
+	if !ok {
+		version = tls.VersionName(cs.Version)
+	}
+	ssl.TLV = append(ssl.TLV,
+		proxyproto.TLV{Type: proxyproto.PP2_SUBTYPE_SSL_VERSION, Value: []byte(version)},
+		proxyproto.TLV{Type: proxyproto.PP2_SUBTYPE_SSL_CIPHER, Value: []byte(tls.CipherSuiteName(cs.CipherSuite))},
+	)
+	if len(cs.PeerCertificates) > 0 && cs.PeerCertificates[0].Subject.CommonName != "" {
+		ssl.TLV = append(ssl.TLV, proxyproto.TLV{
+			Type:  proxyproto.PP2_SUBTYPE_SSL_CN,
+			Value: []byte(cs.PeerCertificates[0].Subject.CommonName),
+		})
+	}
+	return ssl
+}
+
+// SSLTLVFromConnectionState returns the PP2_TYPE_SSL TLV describing cs, see
+// SSLFromConnectionState, ready to be set on a header.
+func SSLTLVFromConnectionState(cs *tls.ConnectionState) (proxyproto.TLV, error) {
+	return SSLFromConnectionState(cs).Marshal()
+}
@@ -0,0 +1,52 @@
+package tlvparse
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+func TestSSLFromConnectionState(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client.example.org"}}
+	cs := &tls.ConnectionState{
+		HandshakeComplete: true,
+		Version:           tls.VersionTLS13,
+		CipherSuite:       tls.TLS_AES_128_GCM_SHA256,
+		PeerCertificates:  []*x509.Certificate{cert},
+		VerifiedChains:    [][]*x509.Certificate{{cert}},
+	}
+
+	tlv, err := SSLTLVFromConnectionState(cs)
+	if err != nil {
+		t.Fatalf("SSLTLVFromConnectionState() = %v", err)
+	}
+	ssl, err := SSL(tlv)
+	if err != nil {
+		t.Fatalf("SSL() = %v", err)
+	}
+	if !ssl.ClientSSL() || !ssl.ClientCertConn() || !ssl.ClientCertSess() || !ssl.Verified() {
+		t.Errorf("unexpected client %#x, verify %d", ssl.Client, ssl.Verify)
+	}
+	if version, _ := ssl.SSLVersion(); version != "TLSv1.3" {
+		t.Errorf("SSLVersion() = %q", version)
+	}
+	if cipher, _ := ssl.SSLCipher(); cipher != "TLS_AES_128_GCM_SHA256" {
+		t.Errorf("SSLCipher() = %q", cipher)
+	}
+	if cn, _ := ssl.ClientCN(); cn != "client.example.org" {
+		t.Errorf("ClientCN() = %q", cn)
+	}
+
+	// A resumed session without verified certificate
+	cs.DidResume = true
+	cs.VerifiedChains = nil
+	ssl = SSLFromConnectionState(cs)
+	if ssl.ClientCertConn() || !ssl.ClientCertSess() || ssl.Verified() {
+		t.Errorf("unexpected client %#x, verify %d", ssl.Client, ssl.Verify)
+	}
+
+	if ssl := SSLFromConnectionState(nil); ssl.ClientSSL() || ssl.Verified() {
+		t.Errorf("unexpected client %#x, verify %d", ssl.Client, ssl.Verify)
+	}
+}
@@ -197,3 +197,36 @@ func TestPP2SSLMarshal(t *testing.T) {
 		t.Errorf("PP2SSL.Marshal() = %#v, want %#v", tlv, want)
 	}
 }
+
+func TestPP2SSLAlgorithms(t *testing.T) {
+	pp2 := PP2SSL{
+		Client: PP2_BITFIELD_CLIENT_SSL,
+		TLV: []proxyproto.TLV{
+			{Type: proxyproto.PP2_SUBTYPE_SSL_VERSION, Value: []byte("TLSv1.3")},
+			{Type: proxyproto.PP2_SUBTYPE_SSL_SIG_ALG, Value: []byte("SHA256")},
+			{Type: proxyproto.PP2_SUBTYPE_SSL_KEY_ALG, Value: []byte("RSA2048")},
+		},
+	}
+	tlv, err := pp2.Marshal()
+	if err != nil {
+		t.Fatalf("PP2SSL.Marshal() = %v", err)
+	}
+
+	ssl, err := SSL(tlv)
+	if err != nil {
+		t.Fatalf("SSL() = %v", err)
+	}
+	if sigAlg, ok := ssl.SSLSigAlg(); !ok || sigAlg != "SHA256" {
+		t.Errorf("SSLSigAlg() = %q, %v", sigAlg, ok)
+	}
+	if keyAlg, ok := ssl.SSLKeyAlg(); !ok || keyAlg != "RSA2048" {
+		t.Errorf("SSLKeyAlg() = %q, %v", keyAlg, ok)
+	}
+
+	// The names must be non-empty US-ASCII strings
+	pp2.TLV[2].Value = []byte("RSA\xff")
+	tlv, _ = pp2.Marshal()
+	if _, err := SSL(tlv); err != proxyproto.ErrMalformedTLV {
+		t.Errorf("SSL() = %v, want %v", err, proxyproto.ErrMalformedTLV)
+	}
+}
@@ -0,0 +1,73 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+var (
+	// ErrTransparentUnsupported is returned when dialing transparently on a
+	// platform without IP_TRANSPARENT.
+	ErrTransparentUnsupported = newError(ErrCodeUnsupported, "proxyproto: transparent dialing is not supported on this platform")
+	// ErrTransparentNoSource is returned when dialing transparently for a
+	// header without a TCP or UDP source address.
+	ErrTransparentNoSource = newError(ErrCodeBadAddress, "proxyproto: no client address to dial from")
+)
+
+// DialTransparent dials addr from the client address of header, its source
+// address, rather than from an address of this host, so that the upstream
+// sees the connection coming from the client itself. This is the source
+// spoofing counterpart of forwarding the header: nothing is written on the
+// connection. To do both, see ProxyDialer.Transparent.
+//
+// The socket is bound to the IP of the client with IP_TRANSPARENT, on an
+// ephemeral port. It requires Linux, CAP_NET_ADMIN and routing sending the
+// replies of the upstream back to this host, as set up for TPROXY. dialer
+// may be nil.
+func DialTransparent(ctx context.Context, dialer *net.Dialer, header *Header, network, addr string) (net.Conn, error) {
+	d, err := transparentDialer(dialer, header)
+	if err != nil {
+		return nil, err
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// transparentDialer returns a copy of dialer binding with IP_TRANSPARENT to
+// the IP of the source address of header.
+func transparentDialer(dialer *net.Dialer, header *Header) (*net.Dialer, error) {
+	if header == nil {
+		return nil, ErrNilHeader
+	}
+	source, _, ok := header.IPs()
+	if !ok || header.Command != PROXY {
+		return nil, ErrTransparentNoSource
+	}
+
+	var d net.Dialer
+	if dialer != nil {
+		d = *dialer
+	}
+	if header.TransportProtocol.IsDatagram() {
+		d.LocalAddr = &net.UDPAddr{IP: source}
+	} else {
+		d.LocalAddr = &net.TCPAddr{IP: source}
+	}
+	ipv6 := source.To4() == nil
+	control, controlContext := d.Control, d.ControlContext
+	d.Control = nil
+	d.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		// ControlContext takes precedence over Control, as in net.Dialer
+		var err error
+		if controlContext != nil {
+			err = controlContext(ctx, network, address, c)
+		} else if control != nil {
+			err = control(network, address, c)
+		}
+		if err != nil {
+			return err
+		}
+		return setTransparent(c, ipv6)
+	}
+	return &d, nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package proxyproto
+
+import "syscall"
+
+// ipv6Transparent is IPV6_TRANSPARENT, missing from the syscall package.
+const ipv6Transparent = 75
+
+// setTransparent enables IP_TRANSPARENT, or IPV6_TRANSPARENT, on the socket
+// so that it can be bound to an address of another host.
+func setTransparent(c syscall.RawConn, ipv6 bool) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		if ipv6 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_IPV6, ipv6Transparent, 1)
+		} else {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_TRANSPARENT, 1)
+		}
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package proxyproto
+
+import "syscall"
+
+// setTransparent fails on platforms without IP_TRANSPARENT.
+func setTransparent(c syscall.RawConn, ipv6 bool) error {
+	return ErrTransparentUnsupported
+}
@@ -0,0 +1,69 @@
+package proxyproto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestDialTransparent(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 1000},
+		l.Addr(),
+	)
+	controlled := false
+	dialer := &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		controlled = true
+		return nil
+	}}
+	conn, err := DialTransparent(context.Background(), dialer, header, "tcp", l.Addr().String())
+	if runtime.GOOS != "linux" {
+		if !errors.Is(err, ErrTransparentUnsupported) {
+			t.Fatalf("bad: %v", err)
+		}
+		return
+	}
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("CAP_NET_ADMIN is required")
+	}
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	if !controlled {
+		t.Fatal("bad: the control function of the dialer wasn't called")
+	}
+
+	accepted, err := l.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer accepted.Close()
+	if ip := accepted.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("bad: %v", accepted.RemoteAddr())
+	}
+}
+
+func TestDialTransparentNoSource(t *testing.T) {
+	ctx := context.Background()
+	if _, err := DialTransparent(ctx, nil, HeaderProxyFromAddrs(2, nil, nil), "tcp", "127.0.0.1:1"); err != ErrTransparentNoSource {
+		t.Fatalf("bad: %v", err)
+	}
+	if _, err := DialTransparent(ctx, nil, nil, "tcp", "127.0.0.1:1"); err != ErrNilHeader {
+		t.Fatalf("bad: %v", err)
+	}
+
+	d := &ProxyDialer{Transparent: true}
+	if _, err := d.DialContext(ctx, "tcp", "127.0.0.1:1"); err != ErrTransparentNoSource {
+		t.Fatalf("bad: %v", err)
+	}
+}
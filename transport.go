@@ -0,0 +1,55 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// NewTransportDialer returns a dial function suitable for
+// http.Transport.DialContext which writes the PROXY header returned by
+// headerFor on every new connection, before the first request is sent. This
+// lets any Go HTTP client talk to origin servers which require the PROXY
+// protocol.
+//
+// headerFor receives the context of the dial, which carries the values of
+// the request that triggered it. When it returns nil, no header is written.
+//
+// Note that http.Transport reuses idle connections between requests: when
+// the header differs from request to request, keep-alives must be disabled
+// on the transport.
+func NewTransportDialer(headerFor func(ctx context.Context) *Header) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &ProxyDialer{
+		Dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.dial(ctx, network, addr, headerFor(ctx))
+	}
+}
+
+// TransportDialer acts as NewTransportDialer, building a header of the given
+// version from the client addresses returned by addrsFor, typically taken
+// from a value of the request context:
+//
+//	transport := &http.Transport{
+//		DialContext: proxyproto.TransportDialer(2, func(ctx context.Context) (net.Addr, net.Addr) {
+//			client := ctx.Value(clientKey{}).(net.Conn)
+//			return client.RemoteAddr(), client.LocalAddr()
+//		}),
+//		DisableKeepAlives: true,
+//	}
+//
+// When addrsFor is nil or the addresses are missing or of different types,
+// a LOCAL header is written, see HeaderProxyFromAddrs.
+func TransportDialer(version byte, addrsFor func(ctx context.Context) (source, dest net.Addr)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return NewTransportDialer(func(ctx context.Context) *Header {
+		if addrsFor == nil {
+			return HeaderProxyFromAddrs(version, nil, nil)
+		}
+		source, dest := addrsFor(ctx)
+		return HeaderProxyFromAddrs(version, source, dest)
+	})
+}
@@ -0,0 +1,126 @@
+package proxyproto
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestTransportDialerWritesHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.RemoteAddr)
+		}),
+	}
+	go server.Serve(&Listener{Listener: l})
+	defer server.Close()
+
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr: &net.TCPAddr{
+			IP:   net.ParseIP("10.1.1.1"),
+			Port: 1000,
+		},
+		DestinationAddr: &net.TCPAddr{
+			IP:   net.ParseIP("20.2.2.2"),
+			Port: 2000,
+		},
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: NewTransportDialer(func(context.Context) *Header { return header }),
+	}}
+
+	resp, err := client.Get("http://" + l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(body) != "10.1.1.1:1000" {
+		t.Fatalf("bad: %s", body)
+	}
+}
+
+func TestTransportDialerWithoutHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		accepted <- conn.RemoteAddr()
+	}()
+
+	dial := NewTransportDialer(func(context.Context) *Header { return nil })
+	conn, err := dial(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	if remote := <-accepted; remote.String() != conn.LocalAddr().String() {
+		t.Fatalf("bad: %v", remote)
+	}
+}
+
+func TestTransportDialerFromAddrs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.RemoteAddr)
+		}),
+	}
+	go server.Serve(&Listener{Listener: l})
+	defer server.Close()
+
+	type clientKey struct{}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: TransportDialer(1, func(ctx context.Context) (net.Addr, net.Addr) {
+			return ctx.Value(clientKey{}).(net.Addr), &net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000}
+		}),
+		DisableKeepAlives: true,
+	}}
+
+	ctx := context.WithValue(context.Background(), clientKey{}, &net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000})
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if string(body) != "10.1.1.1:1000" {
+		t.Fatalf("bad: %s", body)
+	}
+}
@@ -0,0 +1,88 @@
+package proxyproto
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// TrustStore is a PolicySource built from a list of trusted upstream
+// networks, which can be replaced while the listeners using it run, e.g.
+// when the published IP ranges of a cloud load balancer are refreshed:
+//
+//	store := proxyproto.NewTrustStore(ranges, proxyproto.USE, proxyproto.REJECT)
+//	l := &proxyproto.Listener{Listener: inner, PolicySource: store}
+//	// later on
+//	store.Replace(newRanges)
+//
+// Reading the list is lock-free: Replace swaps it atomically, and
+// connections accepted afterwards get the new list.
+type TrustStore struct {
+	trusted   Policy
+	untrusted Policy
+
+	prefixes atomic.Pointer[[]netip.Prefix]
+
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// NewTrustStore returns a TrustStore giving connections from an upstream
+// within one of prefixes the trusted policy, all others the untrusted one,
+// as TrustedSubnetsPolicy does.
+func NewTrustStore(prefixes []netip.Prefix, trusted, untrusted Policy) *TrustStore {
+	s := &TrustStore{
+		trusted:   trusted,
+		untrusted: untrusted,
+		changed:   make(chan struct{}),
+	}
+	prefixes = slices.Clone(prefixes)
+	s.prefixes.Store(&prefixes)
+	return s
+}
+
+// Replace replaces the trusted networks.
+func (s *TrustStore) Replace(prefixes []netip.Prefix) {
+	prefixes = slices.Clone(prefixes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefixes.Store(&prefixes)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Prefixes returns the trusted networks.
+func (s *TrustStore) Prefixes() []netip.Prefix {
+	return slices.Clone(*s.prefixes.Load())
+}
+
+// Contains reports whether addr is within one of the trusted networks.
+func (s *TrustStore) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range *s.prefixes.Load() {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the policy, which uses the trusted networks at the time each
+// connection is accepted.
+func (s *TrustStore) Get() ConnPolicyFunc {
+	return s.policy
+}
+
+// Changed returns a channel which is closed the next time the trusted
+// networks are replaced.
+func (s *TrustStore) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+func (s *TrustStore) policy(connOpts ConnPolicyOptions) (Policy, error) {
+	return subnetsPolicy(*s.prefixes.Load(), connOpts.Upstream, s.trusted, s.untrusted)
+}
@@ -0,0 +1,39 @@
+package proxyproto
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestTrustStore(t *testing.T) {
+	ranges := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")}
+	store := NewTrustStore(ranges, USE, REJECT)
+	// The store keeps its own copy
+	ranges[0] = netip.MustParsePrefix("192.168.0.0/16")
+
+	upstream := ConnPolicyOptions{Upstream: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}}
+	policy := store.Get()
+	if got, err := policy(upstream); got != USE || err != nil {
+		t.Fatalf("bad: %v, %v", got, err)
+	}
+	if !store.Contains(netip.MustParseAddr("::ffff:10.0.0.1")) {
+		t.Fatal("bad: IPv4-mapped address not trusted")
+	}
+
+	changed := store.Changed()
+	store.Replace([]netip.Prefix{netip.MustParsePrefix("10.0.1.0/24")})
+	select {
+	case <-changed:
+	default:
+		t.Fatal("bad: no change notification")
+	}
+
+	// Policies obtained before the change see it too
+	if got, err := policy(upstream); got != REJECT || err != nil {
+		t.Fatalf("bad: %v, %v", got, err)
+	}
+	if prefixes := store.Prefixes(); len(prefixes) != 1 || prefixes[0].String() != "10.0.1.0/24" {
+		t.Fatalf("bad: %v", prefixes)
+	}
+}
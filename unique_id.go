@@ -0,0 +1,39 @@
+package proxyproto
+
+import "crypto/rand"
+
+// MaxUniqueIDLen is the maximum length of a PP2_TYPE_UNIQUE_ID value set by
+// the spec.
+const MaxUniqueIDLen = 128
+
+// ErrUniqueIDTooLong is returned when setting a unique ID longer than
+// MaxUniqueIDLen bytes.
+var ErrUniqueIDTooLong = newError(ErrCodeOverflow, "proxyproto: unique ID longer than 128 bytes")
+
+// UniqueID returns the value of the PP2_TYPE_UNIQUE_ID TLV of the header,
+// the opaque identifier of the connection set by the upstream proxy, and
+// whether there is one.
+func (header *Header) UniqueID() ([]byte, bool) {
+	return header.tlvValue(PP2_TYPE_UNIQUE_ID)
+}
+
+// SetUniqueID sets the PP2_TYPE_UNIQUE_ID TLV of the header to id,
+// replacing the previous one if any and keeping the other TLVs. An empty id
+// removes the TLV. It fails with ErrUniqueIDTooLong if id is longer than
+// MaxUniqueIDLen.
+func (header *Header) SetUniqueID(id []byte) error {
+	if len(id) > MaxUniqueIDLen {
+		return ErrUniqueIDTooLong
+	}
+	return header.replaceTLV(PP2_TYPE_UNIQUE_ID, id)
+}
+
+// RandomUniqueID returns 16 random bytes, a unique ID generator for
+// ProxyDialer.UniqueID.
+func RandomUniqueID() ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
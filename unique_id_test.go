@@ -0,0 +1,86 @@
+package proxyproto
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestUniqueID(t *testing.T) {
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if err := header.SetTLVs([]TLV{{Type: PP2_TYPE_AUTHORITY, Value: []byte("example.org")}}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := header.UniqueID(); ok {
+		t.Fatalf("bad: unexpected unique ID")
+	}
+
+	for _, id := range []string{"first", "second"} {
+		if err := header.SetUniqueID([]byte(id)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got, ok := header.UniqueID(); !ok || string(got) != id {
+			t.Fatalf("bad: %q, %v", got, ok)
+		}
+	}
+	// The ID was replaced, and the other TLVs kept
+	tlvs, _ := header.TLVs()
+	if len(tlvs) != 2 || tlvs[0].Type != PP2_TYPE_AUTHORITY {
+		t.Fatalf("bad: %+v", tlvs)
+	}
+
+	if err := header.SetUniqueID(make([]byte, MaxUniqueIDLen+1)); err != ErrUniqueIDTooLong {
+		t.Fatalf("bad: %v", err)
+	}
+	if err := header.SetUniqueID(nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := header.UniqueID(); ok {
+		t.Fatalf("bad: unique ID not removed")
+	}
+}
+
+func TestProxyDialerUniqueID(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pl := &Listener{Listener: l}
+	defer pl.Close()
+
+	fixed := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	d := &ProxyDialer{Header: fixed, UniqueID: RandomUniqueID}
+
+	var ids [][]byte
+	for i := 0; i < 2; i++ {
+		client, err := d.Dial("tcp", pl.Addr().String())
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer client.Close()
+		conn, err := pl.Accept()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+
+		id, ok := conn.(*Conn).ProxyHeader().UniqueID()
+		if !ok || len(id) != 16 {
+			t.Fatalf("bad: %x, %v", id, ok)
+		}
+		ids = append(ids, id)
+	}
+	if bytes.Equal(ids[0], ids[1]) {
+		t.Fatalf("bad: same unique ID %x", ids[0])
+	}
+	// The shared header isn't modified
+	if _, ok := fixed.UniqueID(); ok {
+		t.Fatalf("bad: fixed header modified")
+	}
+}
@@ -0,0 +1,36 @@
+package proxyproto
+
+// ErrUnspecifiedAddress is returned for PROXY headers carrying a zero source
+// port or an unspecified address, see RejectUnspecifiedAddresses.
+var ErrUnspecifiedAddress = newError(ErrCodeBadAddress, "proxyproto: PROXY header has a zero source port or an unspecified address")
+
+// RejectUnspecifiedAddresses refuses headers with the PROXY command whose
+// source port is 0 or whose source or destination address is 0.0.0.0 or ::
+// when passed as option to NewConn(). Load balancers which are misconfigured
+// or lost track of the client commonly send such headers. The check applies
+// to the USE and REQUIRE policies, before the header validators.
+func RejectUnspecifiedAddresses() func(*Conn) {
+	return func(c *Conn) {
+		c.rejectUnspecified = true
+	}
+}
+
+// CheckSpecifiedAddresses returns ErrUnspecifiedAddress if header is a PROXY
+// header with a zero source port or an unspecified IP address. Headers of
+// other commands or without IP addresses pass. It can be used as Validator.
+func CheckSpecifiedAddresses(header *Header) error {
+	if header.Command != PROXY {
+		return nil
+	}
+	sourceIP, destIP, ok := header.IPs()
+	if !ok {
+		return nil
+	}
+	if sourcePort, _, _ := header.Ports(); sourcePort == 0 {
+		return ErrUnspecifiedAddress
+	}
+	if sourceIP.IsUnspecified() || destIP.IsUnspecified() {
+		return ErrUnspecifiedAddress
+	}
+	return nil
+}
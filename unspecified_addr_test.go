@@ -0,0 +1,40 @@
+package proxyproto
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCheckSpecifiedAddresses(t *testing.T) {
+	tests := []struct {
+		header string
+		err    error
+	}{
+		{"PROXY TCP4 10.1.1.1 20.2.2.2 1000 2000\r\n", nil},
+		{"PROXY TCP4 10.1.1.1 20.2.2.2 0 2000\r\n", ErrUnspecifiedAddress},
+		{"PROXY TCP4 0.0.0.0 20.2.2.2 1000 2000\r\n", ErrUnspecifiedAddress},
+		{"PROXY TCP4 10.1.1.1 0.0.0.0 1000 2000\r\n", ErrUnspecifiedAddress},
+		{"PROXY TCP6 :: ::1 1000 2000\r\n", ErrUnspecifiedAddress},
+		{"PROXY UNKNOWN\r\n", nil},
+	}
+	for _, tt := range tests {
+		server, client := net.Pipe()
+		go client.Write([]byte(tt.header))
+
+		conn := NewConn(server, RejectUnspecifiedAddresses())
+		if tt.err == nil {
+			if conn.ProxyHeader() == nil {
+				t.Fatalf("bad: %q refused: %v", tt.header, conn.readErr)
+			}
+		} else {
+			if _, err := conn.Read(make([]byte, 1)); err != tt.err {
+				t.Fatalf("bad: %q got %v", tt.header, err)
+			}
+			if code := conn.ErrorCode(); code != ErrCodeBadAddress {
+				t.Fatalf("bad: %q got code %v", tt.header, code)
+			}
+		}
+		conn.Close()
+		client.Close()
+	}
+}
@@ -45,7 +45,7 @@ func parseVersion1(reader *bufio.Reader) (*Header, error) {
 	for {
 		b, err := reader.ReadByte()
 		if err != nil {
-			return nil, fmt.Errorf(ErrCantReadVersion1Header.Error()+": %v", err)
+			return nil, fmt.Errorf("%w: %w", ErrCantReadVersion1Header, err)
 		}
 		buf = append(buf, b)
 		if b == '\n' {
@@ -223,7 +223,8 @@ func parseV1PortNumber(portStr string) (int, error) {
 
 func parseV1IPAddress(protocol AddressFamilyAndProtocol, addrStr string) (net.IP, error) {
 	addr, err := netip.ParseAddr(addrStr)
-	if err != nil {
+	// Zones are local to a host, hence never sent, see Header.SetIPv6Zone
+	if err != nil || addr.Zone() != "" {
 		return nil, ErrInvalidAddress
 	}
 
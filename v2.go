@@ -4,14 +4,17 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"io"
 	"math"
 	"net"
+	"slices"
 	"sync"
-	"unsafe"
 )
 
+// unixAddrLen is the size of each address of AF_UNIX families, as in
+// sockaddr_un.sun_path.
+const unixAddrLen = 108
+
 var (
 	lengthUnspec      = uint16(0)
 	lengthV4          = uint16(12)
@@ -37,7 +40,7 @@ var (
 		binary.BigEndian.PutUint16(a, lengthUnix)
 		return a
 	}()
-	errUint16Overflow = errors.New("proxyproto: uint16 overflow")
+	errUint16Overflow = newError(ErrCodeOverflow, "proxyproto: uint16 overflow")
 
 	// Pre-allocate port byte buffer to avoid allocations
 	portBytesPool = sync.Pool{
@@ -79,27 +82,18 @@ func putUnixAddrBuffer(b *[]byte) {
 	unixAddrPool.Put(b)
 }
 
-type _ports struct {
-	SrcPort uint16
-	DstPort uint16
-}
-
-type _addr4 struct {
-	Src     [4]byte
-	Dst     [4]byte
-	SrcPort uint16
-	DstPort uint16
-}
-
-type _addr6 struct {
-	Src [16]byte
-	Dst [16]byte
-	_ports
-}
-
-type _addrUnix struct {
-	Src [108]byte
-	Dst [108]byte
+// addressBlockLen returns the length of the address block of a version 2
+// header for the transport, 0 for unspecified or unknown families.
+func addressBlockLen(transport AddressFamilyAndProtocol) int {
+	switch {
+	case transport.IsIPv4():
+		return int(lengthV4)
+	case transport.IsIPv6():
+		return int(lengthV6)
+	case transport.IsUnix():
+		return int(lengthUnix)
+	}
+	return 0
 }
 
 func parseVersion2(reader *bufio.Reader) (header *Header, err error) {
@@ -153,60 +147,60 @@ func parseVersion2(reader *bufio.Reader) (header *Header, err error) {
 		return header, nil
 	}
 
-	if _, err := reader.Peek(int(length)); err != nil {
-		return nil, ErrInvalidLength
+	// Payloads that fit in the reader's buffer are peeked at first so that
+	// truncated headers are detected before anything is consumed. Larger
+	// payloads (spec-legal up to 64KB) can't be peeked at, so they are read
+	// into a dedicated buffer.
+	var payload []byte
+	peeked := int(length) <= reader.Size()
+	if peeked {
+		if payload, err = reader.Peek(int(length)); err != nil {
+			return nil, ErrInvalidLength
+		}
+	} else {
+		payload = make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return nil, ErrInvalidLength
+		}
 	}
 
-	// Length-limited reader for payload section
-	payloadReader := io.LimitReader(reader, int64(length)).(*io.LimitedReader)
-
-	// Read addresses and ports for protocols other than UNSPEC.
-	// Ignore address information for UNSPEC, and skip straight to read TLVs,
-	// since the length is greater than zero.
-	if header.TransportProtocol != UNSPEC {
-		if header.TransportProtocol.IsIPv4() {
-			var addr _addr4
-			if err := binary.Read(payloadReader, binary.BigEndian, &addr); err != nil {
-				return nil, ErrInvalidAddress
-			}
-			header.SourceAddr = newIPAddr(header.TransportProtocol, addr.Src[:], addr.SrcPort)
-			header.DestinationAddr = newIPAddr(header.TransportProtocol, addr.Dst[:], addr.DstPort)
-		} else if header.TransportProtocol.IsIPv6() {
-			var addr _addr6
-			if err := binary.Read(payloadReader, binary.BigEndian, &addr); err != nil {
-				return nil, ErrInvalidAddress
-			}
-			header.SourceAddr = newIPAddr(header.TransportProtocol, addr.Src[:], addr.SrcPort)
-			header.DestinationAddr = newIPAddr(header.TransportProtocol, addr.Dst[:], addr.DstPort)
-		} else if header.TransportProtocol.IsUnix() {
-			var addr _addrUnix
-			if err := binary.Read(payloadReader, binary.BigEndian, &addr); err != nil {
-				return nil, ErrInvalidAddress
-			}
-
-			network := "unix"
-			if header.TransportProtocol.IsDatagram() {
-				network = "unixgram"
-			}
+	// Decode addresses and ports for protocols other than UNSPEC straight
+	// from the payload rather than with binary.Read, which relies on
+	// reflection and allocates. The length was validated above, so the
+	// address block is complete. Ignore address information for UNSPEC, and
+	// skip straight to the TLVs, since the length is greater than zero.
+	if header.TransportProtocol.IsIPv4() {
+		header.SourceAddr = newIPAddr(header.TransportProtocol, bytes.Clone(payload[0:4]), binary.BigEndian.Uint16(payload[8:10]))
+		header.DestinationAddr = newIPAddr(header.TransportProtocol, bytes.Clone(payload[4:8]), binary.BigEndian.Uint16(payload[10:12]))
+	} else if header.TransportProtocol.IsIPv6() {
+		header.SourceAddr = newIPAddr(header.TransportProtocol, bytes.Clone(payload[0:16]), binary.BigEndian.Uint16(payload[32:34]))
+		header.DestinationAddr = newIPAddr(header.TransportProtocol, bytes.Clone(payload[16:32]), binary.BigEndian.Uint16(payload[34:36]))
+	} else if header.TransportProtocol.IsUnix() {
+		network := "unix"
+		if header.TransportProtocol.IsDatagram() {
+			network = "unixgram"
+		}
 
-			header.SourceAddr = &net.UnixAddr{
-				Net:  network,
-				Name: parseUnixName(addr.Src[:]),
-			}
-			header.DestinationAddr = &net.UnixAddr{
-				Net:  network,
-				Name: parseUnixName(addr.Dst[:]),
-			}
+		header.SourceAddr = &net.UnixAddr{
+			Net:  network,
+			Name: parseUnixName(payload[:unixAddrLen]),
+		}
+		header.DestinationAddr = &net.UnixAddr{
+			Net:  network,
+			Name: parseUnixName(payload[unixAddrLen : 2*unixAddrLen]),
 		}
 	}
 
-	// Copy bytes for optional Type-Length-Value vector
-	remainingLength := int(payloadReader.N)
-	if remainingLength > 0 {
-		header.rawTLVs = make([]byte, remainingLength)
-		if _, err = io.ReadFull(payloadReader, header.rawTLVs); err != nil && err != io.EOF {
-			return nil, err
+	// Keep the optional Type-Length-Value vector, copying it out of the
+	// reader's buffer if it was only peeked at
+	if tlvs := payload[addressBlockLen(header.TransportProtocol):]; len(tlvs) > 0 {
+		if peeked {
+			tlvs = bytes.Clone(tlvs)
 		}
+		header.setRawTLVs(tlvs)
+	}
+	if peeked {
+		reader.Discard(len(payload))
 	}
 
 	return header, nil
@@ -215,6 +209,12 @@ func parseVersion2(reader *bufio.Reader) (header *Header, err error) {
 // formatVersion2 serializes a proxy protocol version 2 header
 // This optimized version minimizes copying and reuses buffers
 func (header *Header) formatVersion2() ([]byte, error) {
+	return header.appendVersion2(nil)
+}
+
+// appendVersion2 appends the version 2 header to dst, growing it at most
+// once.
+func (header *Header) appendVersion2(dst []byte) ([]byte, error) {
 	// Pre-calculate the total buffer size to avoid reallocations
 	totalSize := len(SIGV2) + 2 // Signature + command/protocol bytes
 
@@ -233,7 +233,7 @@ func (header *Header) formatVersion2() ([]byte, error) {
 	totalSize += len(header.rawTLVs)
 
 	// Allocate a single buffer of the right size
-	result := make([]byte, 0, totalSize)
+	result := slices.Grow(dst, totalSize)
 
 	// Append signature (no allocation)
 	result = append(result, SIGV2...)
@@ -334,18 +334,15 @@ func (header *Header) formatVersion2() ([]byte, error) {
 		}
 
 		// Use optimized Unix name formatting
-		addrSrc := formatUnixNameZeroCopy(sourceAddr.Name)
-		addrDst := formatUnixNameZeroCopy(destAddr.Name)
+		srcBuf := formatUnixNameZeroCopy(sourceAddr.Name)
+		dstBuf := formatUnixNameZeroCopy(destAddr.Name)
 
-		// These are fully copied values, so we'll need to free them
-		defer func() {
-			if addrSrc != nil {
-				putUnixAddrSlice(addrSrc)
-			}
-			if addrDst != nil {
-				putUnixAddrSlice(addrDst)
-			}
-		}()
+		// These are pooled buffers, so we'll need to return them
+		defer putUnixAddrBuffer(srcBuf)
+		defer putUnixAddrBuffer(dstBuf)
+
+		addrSrc := *srcBuf
+		addrDst := *dstBuf
 
 		// Calculate final length including TLVs
 		totalLength := baseLength
@@ -386,10 +383,10 @@ func (header *Header) formatVersion2() ([]byte, error) {
 		// For UNSPEC, calculate final length with TLVs
 		length := uint16(0)
 		if len(header.rawTLVs) > 0 {
-			length = uint16(len(header.rawTLVs))
-			if length > math.MaxUint16 {
+			if len(header.rawTLVs) > math.MaxUint16 {
 				return nil, errUint16Overflow
 			}
+			length = uint16(len(header.rawTLVs))
 		}
 
 		// Write length directly into result buffer
@@ -407,10 +404,11 @@ func (header *Header) formatVersion2() ([]byte, error) {
 }
 
 // formatUnixNameZeroCopy formats a Unix socket path with minimal copying
-// Returns a slice that must be returned to the pool with putUnixAddrSlice
-func formatUnixNameZeroCopy(name string) []byte {
-	// Create a properly-sized slice
-	slice := getUnixAddrSlice()
+// Returns a buffer that must be returned to the pool with putUnixAddrBuffer
+func formatUnixNameZeroCopy(name string) *[]byte {
+	// Get a properly-sized buffer from the pool
+	bufPtr := getUnixAddrBuffer()
+	slice := (*bufPtr)[:108] // Ensure slice is exactly the right size
 
 	// Copy the name into the slice
 	nameLen := copy(slice, name)
@@ -420,27 +418,8 @@ func formatUnixNameZeroCopy(name string) []byte {
 		slice[i] = 0
 	}
 
-	return slice
-}
-
-// getUnixAddrSlice gets a pre-allocated slice for Unix socket addresses
-func getUnixAddrSlice() []byte {
-	bufPtr := getUnixAddrBuffer()
-	slice := (*bufPtr)[:108] // Ensure slice is exactly the right size
-	return slice
-}
-
-// putUnixAddrSlice returns a Unix address slice to the pool
-func putUnixAddrSlice(slice []byte) {
-	// Find the buffer pointer by calculating its address
-	if cap(slice) >= 108 {
-		// Convert slice to pointer to underlying array
-		bufPtr := &slice[:cap(slice)][0]
-		// Convert to *[]byte (this is a bit hacky but avoids allocating a new buffer)
-		sliceHeader := (*[]byte)(unsafe.Pointer(&bufPtr))
-		// Put it back in the pool
-		putUnixAddrBuffer(sliceHeader)
-	}
+	*bufPtr = slice
+	return bufPtr
 }
 
 func (header *Header) validateLength(length uint16) bool {
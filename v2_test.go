@@ -436,6 +436,50 @@ func TestV2EqualsToTLV(t *testing.T) {
 	}
 }
 
+func TestParseV2LargerThanReaderBuffer(t *testing.T) {
+	header := &Header{
+		Version:           2,
+		Command:           PROXY,
+		TransportProtocol: TCPv4,
+		SourceAddr:        v4addr,
+		DestinationAddr:   v4addr,
+	}
+	tlvs := []TLV{{Type: PP2_TYPE_MIN_CUSTOM, Value: make([]byte, 60000)}}
+	if err := header.SetTLVs(tlvs); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	raw, err := header.Format()
+	if err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	reader := newBufioReader(append(raw, arbitraryTailBytes...))
+	if len(raw) <= reader.Size() {
+		t.Fatalf("header of %d bytes fits in the %d bytes reader buffer", len(raw), reader.Size())
+	}
+
+	newHeader, err := Read(reader)
+	if err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if !newHeader.EqualsTo(header) {
+		t.Fatalf("expected %#v, actual %#v", header, newHeader)
+	}
+
+	nextBytes, err := reader.Peek(len(arbitraryTailBytes))
+	if err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if !reflect.DeepEqual(nextBytes, arbitraryTailBytes) {
+		t.Fatalf("expected %#v, actual %#v", arbitraryTailBytes, nextBytes)
+	}
+
+	// A truncated oversized header is still reported as an invalid length
+	if _, err := Read(newBufioReader(raw[:len(raw)-1])); err != ErrInvalidLength {
+		t.Fatalf("expected %s, actual %v", ErrInvalidLength, err)
+	}
+}
+
 var tlvFormatTests = []struct {
 	desc   string
 	header *Header
@@ -520,3 +564,49 @@ func fixtureWithTLV(cur []byte, addr []byte, tlv []byte) []byte {
 
 	return append(append(tlen, addr...), tlv...)
 }
+
+func BenchmarkParseVersion2(b *testing.B) {
+	for _, tc := range []struct {
+		name   string
+		header *Header
+	}{
+		{"TCPv4", &Header{
+			Version:           2,
+			Command:           PROXY,
+			TransportProtocol: TCPv4,
+			SourceAddr:        v4addr,
+			DestinationAddr:   v4addr,
+		}},
+		{"TCPv6", &Header{
+			Version:           2,
+			Command:           PROXY,
+			TransportProtocol: TCPv6,
+			SourceAddr:        v6addr,
+			DestinationAddr:   v6addr,
+		}},
+		{"UnixStream", &Header{
+			Version:           2,
+			Command:           PROXY,
+			TransportProtocol: UnixStream,
+			SourceAddr:        unixStreamAddr,
+			DestinationAddr:   unixStreamAddr,
+		}},
+	} {
+		raw, err := tc.header.Format()
+		if err != nil {
+			b.Fatalf("err: %v", err)
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			r := bytes.NewReader(raw)
+			br := bufio.NewReader(r)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.Reset(raw)
+				br.Reset(r)
+				if _, err := parseVersion2(br); err != nil {
+					b.Fatalf("err: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package proxyproto
 
 import (
+	"errors"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 // Define the zero-copy function type
@@ -15,6 +19,22 @@ var (
 
 	// zeroCopyAvailable indicates if any optimized zero-copy method is available
 	zeroCopyAvailable bool = false
+
+	// zeroCopyBackend names the compiled-in zero-copy implementation
+	zeroCopyBackend = "none"
+
+	// zeroCopyProbe, if set by the backend, checks that the syscalls it
+	// relies on actually work in the current environment. Containers,
+	// gVisor or seccomp filters may refuse them even though the kernel
+	// supports them.
+	zeroCopyProbe func() error
+
+	// zeroCopyProbeOnce guards the single run of zeroCopyProbe
+	zeroCopyProbeOnce sync.Once
+
+	// zeroCopyDisabled is set once the backend is known not to work, after
+	// which all transfers use fallbackCopy
+	zeroCopyDisabled atomic.Bool
 )
 
 // init sets up the default fallback implementation
@@ -23,9 +43,117 @@ func init() {
 	zeroCopyImpl = fallbackCopy
 }
 
-// ZeroCopyAvailable returns true if an optimized zero-copy implementation is available
+// ZeroCopyAvailable returns true if an optimized zero-copy implementation is available.
+// The first call probes the compiled-in implementation, which is disabled if
+// it doesn't work in the current environment.
 func ZeroCopyAvailable() bool {
-	return zeroCopyAvailable
+	return zeroCopyUsable()
+}
+
+// zeroCopyUsable probes the backend on first use and reports whether it can
+// be used.
+func zeroCopyUsable() bool {
+	if !zeroCopyAvailable {
+		return false
+	}
+	zeroCopyProbeOnce.Do(func() {
+		if zeroCopyProbe != nil && zeroCopyProbe() != nil {
+			zeroCopyDisabled.Store(true)
+		}
+	})
+	return !zeroCopyDisabled.Load()
+}
+
+// zeroCopyTransfer copies with the zero-copy backend, falling back to a
+// regular copy when the backend can't handle the connections. Errors showing
+// the syscalls are refused altogether disable the backend for good, others
+// only affect this transfer.
+func zeroCopyTransfer(src, dst net.Conn, buf []byte) (int64, error) {
+	// The backends work on the raw connections. Writes to a proxied
+	// connection aren't buffered, but reads are: flush those bytes first.
+	// A lazy header must go out before anything bypasses the Conn.
+	if c, ok := dst.(*Conn); ok {
+		if c.msgZeroCopy != nil {
+			return c.relayMsgZeroCopy(src, buf)
+		}
+		if err := c.flushLazyHeader(); err != nil {
+			return 0, err
+		}
+		dst = c.conn
+	}
+	if c, ok := src.(*Conn); ok {
+		n, err := c.drainBuffered(dst)
+		if err != nil {
+			return n, err
+		}
+		m, err := zeroCopyTransfer(c.conn, dst, buf)
+		return n + m, err
+	}
+
+	if !zeroCopyUsable() {
+		return fallbackUnavailable.record(fallbackCopy(src, dst, buf))
+	}
+	if !isTCPConn(src) || !isTCPConn(dst) {
+		return fallbackUnsupportedConn.record(fallbackCopy(src, dst, buf))
+	}
+
+	n, err := zeroCopyImpl(src, dst, buf)
+	if n != 0 || err == nil {
+		return recordZeroCopy(n, err)
+	}
+
+	switch {
+	case errors.Is(err, syscall.ENOSYS), errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		zeroCopyDisabled.Store(true)
+		return fallbackRefused.record(fallbackCopy(src, dst, buf))
+	case errors.Is(err, syscall.EINVAL), errors.Is(err, syscall.EOPNOTSUPP):
+		return fallbackConnError.record(fallbackCopy(src, dst, buf))
+	}
+	return recordZeroCopy(n, err)
+}
+
+// rawConns returns the raw connections of src and dst, through which the
+// backends work on the descriptors owned by the runtime. TCPConn.File must
+// not be used: it duplicates the descriptor, which leaks under load, and
+// switches the socket to blocking mode behind the runtime poller.
+func rawConns(src, dst net.Conn) (srcRaw, dstRaw syscall.RawConn, err error) {
+	srcConn, ok := src.(syscall.Conn)
+	if !ok {
+		return nil, nil, syscall.EINVAL
+	}
+	dstConn, ok := dst.(syscall.Conn)
+	if !ok {
+		return nil, nil, syscall.EINVAL
+	}
+	if srcRaw, err = srcConn.SyscallConn(); err != nil {
+		return nil, nil, err
+	}
+	if dstRaw, err = dstConn.SyscallConn(); err != nil {
+		return nil, nil, err
+	}
+	return srcRaw, dstRaw, nil
+}
+
+// controlBoth runs fn with the descriptors of both raw connections, which
+// stay open until it returns even if the connections are closed meanwhile.
+// The descriptors are non-blocking, as the runtime sets them.
+func controlBoth(srcRaw, dstRaw syscall.RawConn, fn func(srcFd, dstFd int)) error {
+	var dstErr error
+	srcErr := srcRaw.Control(func(srcFd uintptr) {
+		dstErr = dstRaw.Control(func(dstFd uintptr) {
+			fn(int(srcFd), int(dstFd))
+		})
+	})
+	if srcErr != nil {
+		return srcErr
+	}
+	return dstErr
+}
+
+// isTCPConn reports whether the backends can work on conn
+func isTCPConn(conn net.Conn) bool {
+	_, ok := conn.(*net.TCPConn)
+	return ok
 }
 
 // ZeroCopy transfers data from src to dst using the most efficient available method
@@ -34,13 +162,13 @@ func ZeroCopyAvailable() bool {
 func ZeroCopy(src, dst net.Conn) (int64, error) {
 	// Use a 64KB buffer for optimal transfers
 	buf := make([]byte, 64*1024)
-	return zeroCopyImpl(src, dst, buf)
+	return zeroCopyTransfer(src, dst, buf)
 }
 
 // ZeroCopyWithBuffer transfers data from src to dst using the provided buffer
 // and the most efficient available method with minimized memory copying.
 func ZeroCopyWithBuffer(src, dst net.Conn, buf []byte) (int64, error) {
-	return zeroCopyImpl(src, dst, buf)
+	return zeroCopyTransfer(src, dst, buf)
 }
 
 // fallbackCopy is the standard fallback implementation used when optimized
@@ -50,28 +178,102 @@ func fallbackCopy(src, dst net.Conn, buf []byte) (int64, error) {
 	return io.CopyBuffer(dst, src, buf)
 }
 
-// Update the Conn.WriteTo method to use our zero-copy implementation
+// WriteTo reads the proxy header if needed, then writes the data already
+// buffered while reading it to w before transferring the rest with the
+// zero-copy implementation when possible.
 func (p *Conn) WriteTo(w io.Writer) (int64, error) {
-	dstConn, ok := w.(net.Conn)
-
-	// If we have a direct connection and zero-copy is available, use it
-	if ok && zeroCopyAvailable {
-		return ZeroCopy(p.conn, dstConn)
+	n, err := p.drainBuffered(w)
+	if err != nil {
+		return n, err
 	}
 
-	// Fall back to standard io.Copy
-	return io.Copy(w, p.conn)
+	var m int64
+	dstConn, ok := w.(net.Conn)
+	switch {
+	case ok && zeroCopyUsable():
+		// If we have a direct connection and zero-copy is available, use it
+		m, err = ZeroCopy(p.conn, dstConn)
+	case ok:
+		// Fall back to standard io.Copy
+		m, err = fallbackUnavailable.record(io.Copy(w, p.conn))
+	default:
+		m, err = fallbackUnsupportedConn.record(io.Copy(w, p.conn))
+	}
+	return n + m, err
 }
 
-// Update the Conn.ReadFrom method to use our zero-copy implementation
+// ReadFrom transfers the data of r to the connection, with the zero-copy
+// implementation when possible. Regular files, possibly behind an
+// io.LimitedReader, are sent with sendfile(2) where the net package does,
+// and the rest with MSG_ZEROCOPY if enabled, see WithMsgZeroCopy.
 func (p *Conn) ReadFrom(r io.Reader) (int64, error) {
+	// The lazy header goes first, on its own
+	if err := p.flushLazyHeader(); err != nil {
+		return 0, err
+	}
+	if n, handled, err := p.readFromFile(r); handled {
+		return n, err
+	}
+	if p.msgZeroCopy != nil {
+		return p.relayMsgZeroCopy(r, nil)
+	}
+
+	// A proxied source must first flush what it buffered, which its
+	// WriteTo takes care of
+	if src, ok := r.(*Conn); ok {
+		return src.WriteTo(p.conn)
+	}
+
 	srcConn, ok := r.(net.Conn)
 
 	// If we have a direct connection and zero-copy is available, use it
-	if ok && zeroCopyAvailable {
+	if ok && zeroCopyUsable() {
 		return ZeroCopy(srcConn, p.conn)
 	}
 
 	// Fall back to standard io.Copy
-	return io.Copy(p.conn, r)
+	if ok {
+		return fallbackUnavailable.record(io.Copy(p.conn, r))
+	}
+	return fallbackUnsupportedConn.record(io.Copy(p.conn, r))
+}
+
+// drainBuffered reads the proxy header if needed and writes the bytes that
+// were buffered past it to w, followed by the rest of the prefix given to
+// WithPrefix, so that the rest of the stream can be read straight from the
+// underlying connection.
+func (p *Conn) drainBuffered(w io.Writer) (int64, error) {
+	if !p.acquireReader() {
+		return 0, io.EOF
+	}
+	defer p.releaseReader()
+
+	p.readHeaderOnce()
+	if p.readErr != nil {
+		return 0, p.readErr
+	}
+
+	var n int64
+	if buffered := p.bufReader.Buffered(); buffered > 0 {
+		b, _ := p.bufReader.Peek(buffered)
+		m, err := w.Write(b)
+		p.bufReader.Discard(m)
+		bufferedBytes.Add(uint64(m))
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	// What the reader didn't get to of the prefix comes next, as in Detach
+	if p.prefix.pending() {
+		m, err := w.Write(p.prefix.rest)
+		p.prefix.rest = p.prefix.rest[m:]
+		bufferedBytes.Add(uint64(m))
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
 }
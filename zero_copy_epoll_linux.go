@@ -17,45 +17,48 @@ const EpollZeroCopy = true
 func init() {
 	zeroCopyImpl = epollZeroCopy
 	zeroCopyAvailable = true
+	zeroCopyBackend = "epoll"
+	zeroCopyProbe = probeEpoll
+}
+
+// probeEpoll checks that epoll instances can be created
+func probeEpoll() error {
+	epfd, err := syscall.EpollCreate1(0)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(epfd)
 }
 
 // epollZeroCopy implements zero-copy data transfer using Linux's epoll syscall directly
 // This provides maximum efficiency by directly using the kernel's event notification system
 func epollZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
-	// Get file descriptors for the connections
-	srcTCP, srcOK := src.(*net.TCPConn)
-	dstTCP, dstOK := dst.(*net.TCPConn)
+	_, srcOK := src.(*net.TCPConn)
+	_, dstOK := dst.(*net.TCPConn)
 
 	if !srcOK || !dstOK {
 		// Fall back to standard copy if not TCP connections
 		return io.CopyBuffer(dst, src, buf)
 	}
 
-	// Extract file descriptors
-	srcFile, err := srcTCP.File()
+	srcRaw, dstRaw, err := rawConns(src, dst)
 	if err != nil {
 		return 0, err
 	}
-	defer srcFile.Close()
 
-	dstFile, err := dstTCP.File()
-	if err != nil {
-		srcFile.Close()
-		return 0, err
-	}
-	defer dstFile.Close()
-
-	srcFd := int(srcFile.Fd())
-	dstFd := int(dstFile.Fd())
-
-	// Make sockets non-blocking
-	if err := syscall.SetNonblock(srcFd, true); err != nil {
-		return 0, err
-	}
-	if err := syscall.SetNonblock(dstFd, true); err != nil {
-		return 0, err
+	var total int64
+	var copyErr error
+	if err := controlBoth(srcRaw, dstRaw, func(srcFd, dstFd int) {
+		total, copyErr = epollCopy(srcFd, dstFd, buf)
+	}); err != nil {
+		return total, err
 	}
+	return total, copyErr
+}
 
+// epollCopy copies from srcFd to dstFd, the non-blocking descriptors of the
+// connections, waiting for them with its own epoll instance.
+func epollCopy(srcFd, dstFd int, buf []byte) (int64, error) {
 	// Optimize socket settings
 	if err := syscall.SetsockoptInt(srcFd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1); err != nil {
 		return 0, err
@@ -0,0 +1,85 @@
+//go:build linux && (splice || epoll || netpoll)
+// +build linux
+// +build splice epoll netpoll
+
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// tcpPair returns both ends of a TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return client, server
+}
+
+func TestZeroCopyLazyHeader(t *testing.T) {
+	srcClient, src := tcpPair(t)
+	defer src.Close()
+	dst, dstServer := tcpPair(t)
+	defer dstServer.Close()
+
+	header := HeaderProxyFromAddrs(2,
+		&net.TCPAddr{IP: net.ParseIP("10.1.1.1"), Port: 1000},
+		&net.TCPAddr{IP: net.ParseIP("20.2.2.2"), Port: 2000},
+	)
+	if _, err := header.WriteTo(srcClient); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	proxiedSrc := NewConn(src, WithPolicy(REQUIRE))
+	// Nothing is buffered past the header, so that the whole payload goes
+	// through the backend
+	if proxiedSrc.ProxyHeader() == nil {
+		t.Fatal("bad: no header")
+	}
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	go func() {
+		srcClient.Write(data)
+		srcClient.Close()
+	}()
+
+	type result struct {
+		header  *Header
+		payload []byte
+		err     error
+	}
+	received := make(chan result, 1)
+	go func() {
+		conn := NewConn(dstServer, WithPolicy(REQUIRE))
+		payload, err := io.ReadAll(conn)
+		received <- result{conn.ProxyHeader(), payload, err}
+	}()
+
+	lazyDst := NewConn(dst, WithLazyHeader(header), WithPolicy(SKIP))
+	if n, err := io.Copy(lazyDst, proxiedSrc); err != nil || n != int64(len(data)) {
+		t.Fatalf("bad: %d, %v", n, err)
+	}
+	dst.Close()
+
+	got := <-received
+	if got.err != nil {
+		t.Fatalf("err: %v", got.err)
+	}
+	if !got.header.EqualsTo(header) {
+		t.Fatalf("bad: header %+v", got.header)
+	}
+	if !bytes.Equal(got.payload, data) {
+		t.Fatalf("bad: received %d bytes, want %d", len(got.payload), len(data))
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"io"
 	"net"
 	"syscall"
-
-	"golang.org/x/sys/unix"
 )
 
 // NetpollZeroCopy indicates that the netpoll-based zero-copy optimization is enabled
@@ -19,117 +17,82 @@ const NetpollZeroCopy = true
 func init() {
 	zeroCopyImpl = netpollZeroCopy
 	zeroCopyAvailable = true
+	zeroCopyBackend = "netpoll"
+	// Nothing to probe, the raw connections only use read and write
 }
 
 // netpollZeroCopy implements zero-copy data transfer using Go's underlying netpoll functionality
-// which is built on top of epoll/kqueue but managed by Go's runtime
+// which is built on top of epoll/kqueue but managed by Go's runtime: the
+// syscalls are made on the raw connections, which park the goroutine in the
+// poller when the sockets aren't ready, and honour the deadlines.
 func netpollZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
-	// Get file descriptors for the connections
-	srcTCP, srcOK := src.(*net.TCPConn)
-	dstTCP, dstOK := dst.(*net.TCPConn)
+	_, srcOK := src.(*net.TCPConn)
+	_, dstOK := dst.(*net.TCPConn)
 
 	if !srcOK || !dstOK {
 		// Fall back to standard copy if not TCP connections
 		return io.CopyBuffer(dst, src, buf)
 	}
 
-	// Extract file descriptors using the internal method
-	srcFile, err := srcTCP.File()
-	if err != nil {
-		return 0, err
-	}
-	defer srcFile.Close()
-
-	dstFile, err := dstTCP.File()
+	srcRaw, dstRaw, err := rawConns(src, dst)
 	if err != nil {
-		srcFile.Close()
-		return 0, err
-	}
-	defer dstFile.Close()
-
-	srcFd := int(srcFile.Fd())
-	dstFd := int(dstFile.Fd())
-
-	// Make sure we're dealing with non-blocking sockets
-	if err := syscall.SetNonblock(srcFd, true); err != nil {
-		return 0, err
-	}
-	if err := syscall.SetNonblock(dstFd, true); err != nil {
 		return 0, err
 	}
 
-	// Set TCP_NODELAY to optimize for latency
-	if err := setTCPNoDelay(srcFd, true); err != nil {
+	// Set TCP_NODELAY to optimize for latency, and TCP_CORK to optimize
+	// for throughput (coalesce packets)
+	if err := controlBoth(srcRaw, dstRaw, func(srcFd, dstFd int) {
+		setTCPNoDelay(srcFd, true)
+		setTCPNoDelay(dstFd, true)
+		setTCPCork(dstFd, true)
+	}); err != nil {
 		return 0, err
 	}
-	if err := setTCPNoDelay(dstFd, true); err != nil {
-		return 0, err
-	}
-
-	// Set TCP_CORK to optimize for throughput (coalesce packets)
-	if err := setTCPCork(dstFd, true); err != nil {
-		// Not critical, just continue if we can't set it
-	}
-
-	// Variables to track progress
-	var total int64
-	var n int
-	var rerr error
+	// Flush any remaining data by turning off TCP_CORK
+	defer dstRaw.Control(func(fd uintptr) {
+		setTCPCork(int(fd), false)
+	})
 
 	// Buffer to use for transfers - use pre-allocated buffer if provided
-	bufSize := 64 * 1024 // 64KB chunks for optimal performance
-	if len(buf) > 0 {
-		bufSize = len(buf)
-	} else {
-		buf = make([]byte, bufSize)
+	if len(buf) == 0 {
+		buf = make([]byte, 64*1024) // 64KB chunks for optimal performance
 	}
 
-	// Copy data in a loop, handling EAGAIN properly with netpoll-like approach
+	var total int64
 	for {
 		// Read phase
-		n, rerr = syscall.Read(srcFd, buf)
+		var n int
+		var rerr error
+		if err := srcRaw.Read(func(fd uintptr) bool {
+			n, rerr = syscall.Read(int(fd), buf)
+			// Socket not ready, wait for read readiness
+			return !errors.Is(rerr, syscall.EAGAIN)
+		}); err != nil {
+			return total, err
+		}
 		if rerr != nil {
-			if errors.Is(rerr, syscall.EAGAIN) || errors.Is(rerr, syscall.EWOULDBLOCK) {
-				// Socket not ready, wait for read readiness
-				readReady, err := waitReadReady(srcFd)
-				if err != nil {
-					return total, err
-				}
-				if !readReady {
-					// EOF or other condition
-					if total > 0 {
-						return total, nil
-					}
-					return 0, io.EOF
-				}
-				continue
+			if errors.Is(rerr, syscall.ECONNRESET) {
+				return total, nil
 			}
-			// Real error
-			break
+			return total, rerr
 		}
-
-		if n == 0 {
+		if n <= 0 {
 			// End of file
-			break
+			return total, nil
 		}
 
 		// Write phase - write complete buffer contents
-		writeOffset := 0
-		for writeOffset < n {
-			written, werr := syscall.Write(dstFd, buf[writeOffset:n])
+		for writeOffset := 0; writeOffset < n; {
+			var written int
+			var werr error
+			if err := dstRaw.Write(func(fd uintptr) bool {
+				written, werr = syscall.Write(int(fd), buf[writeOffset:n])
+				// Socket not ready, wait for write readiness
+				return !errors.Is(werr, syscall.EAGAIN)
+			}); err != nil {
+				return total, err
+			}
 			if werr != nil {
-				if errors.Is(werr, syscall.EAGAIN) || errors.Is(werr, syscall.EWOULDBLOCK) {
-					// Socket not ready, wait for write readiness
-					writeReady, err := waitWriteReady(dstFd)
-					if err != nil {
-						return total, err
-					}
-					if !writeReady {
-						return total, errors.New("write timeout")
-					}
-					continue
-				}
-				// Real error
 				return total, werr
 			}
 
@@ -137,51 +100,6 @@ func netpollZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
 			total += int64(written)
 		}
 	}
-
-	// Flush any remaining data by turning off TCP_CORK
-	if err := setTCPCork(dstFd, false); err != nil {
-		// Not critical, just continue
-	}
-
-	if rerr != nil && rerr != io.EOF && !errors.Is(rerr, syscall.ECONNRESET) {
-		return total, rerr
-	}
-
-	return total, nil
-}
-
-// waitReadReady waits for a file descriptor to be ready for reading
-func waitReadReady(fd int) (bool, error) {
-	// Create pollfd structure
-	pfd := unix.PollFd{
-		Fd:     int32(fd),
-		Events: unix.POLLIN,
-	}
-
-	// Wait for read readiness with a timeout
-	n, err := unix.Poll([]unix.PollFd{pfd}, 1000) // 1 second timeout
-	if err != nil {
-		return false, err
-	}
-
-	return n > 0, nil
-}
-
-// waitWriteReady waits for a file descriptor to be ready for writing
-func waitWriteReady(fd int) (bool, error) {
-	// Create pollfd structure
-	pfd := unix.PollFd{
-		Fd:     int32(fd),
-		Events: unix.POLLOUT,
-	}
-
-	// Wait for write readiness with a timeout
-	n, err := unix.Poll([]unix.PollFd{pfd}, 1000) // 1 second timeout
-	if err != nil {
-		return false, err
-	}
-
-	return n > 0, nil
 }
 
 // setTCPNoDelay sets the TCP_NODELAY socket option
@@ -0,0 +1,38 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"os"
+)
+
+// isRegularFile reports whether r reads from a regular file, possibly
+// behind an io.LimitedReader.
+func isRegularFile(r io.Reader) bool {
+	if lr, ok := r.(*io.LimitedReader); ok {
+		r = lr.R
+	}
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// readFromFile transfers r to the connection when r is a regular file,
+// possibly behind an io.LimitedReader, e.g. a cached file served behind a
+// PROXY front-end. The TCP connection sends it with sendfile(2) where the
+// net package does, on Linux among others. It reports whether it handled
+// the transfer: otherwise nothing was sent and the caller must copy r.
+func (p *Conn) readFromFile(r io.Reader) (int64, bool, error) {
+	if !isRegularFile(r) {
+		return 0, false, nil
+	}
+	tcpConn, ok := p.conn.(*net.TCPConn)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := recordZeroCopy(io.Copy(tcpConn, r))
+	return n, true, err
+}
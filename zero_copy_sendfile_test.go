@@ -0,0 +1,75 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadFromFile(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn := NewConn(raw)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	path := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer f.Close()
+
+	before := GetZeroCopyStats()
+	// Skip the first byte and leave the last one out
+	if _, err := f.Seek(1, io.SeekStart); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lr := &io.LimitedReader{R: f, N: int64(len(content) - 2)}
+	n, err := conn.ReadFrom(lr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn.Close()
+
+	want := content[1 : len(content)-1]
+	if n != int64(len(want)) || lr.N != 0 {
+		t.Fatalf("bad: %d bytes sent, %d left", n, lr.N)
+	}
+	if got := <-received; !bytes.Equal(got, want) {
+		t.Fatalf("bad: received %d bytes", len(got))
+	}
+
+	if runtime.GOOS == "linux" {
+		stats := GetZeroCopyStats()
+		if sent := stats.ZeroCopyBytes - before.ZeroCopyBytes; sent != uint64(len(want)) {
+			t.Fatalf("bad: %d bytes reported as zero-copied", sent)
+		}
+	}
+}
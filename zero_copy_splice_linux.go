@@ -8,8 +8,6 @@ import (
 	"io"
 	"net"
 	"syscall"
-
-	"golang.org/x/sys/unix"
 )
 
 // SpliceZeroCopy indicates that the splice-based zero-copy optimization is enabled
@@ -19,6 +17,31 @@ const SpliceZeroCopy = true
 func init() {
 	zeroCopyImpl = spliceZeroCopy
 	zeroCopyAvailable = true
+	zeroCopyBackend = "splice"
+	zeroCopyProbe = probeSplice
+}
+
+// probeSplice checks that a byte can be spliced from a socket into a pipe
+func probeSplice() error {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+
+	pipeFds := make([]int, 2)
+	if err := syscall.Pipe(pipeFds); err != nil {
+		return err
+	}
+	defer syscall.Close(pipeFds[0])
+	defer syscall.Close(pipeFds[1])
+
+	if _, err := syscall.Write(fds[1], []byte{0}); err != nil {
+		return err
+	}
+	_, err = syscallSplice(fds[0], nil, pipeFds[1], nil, 1, SPLICE_F_NONBLOCK)
+	return err
 }
 
 // splice syscall parameters
@@ -33,42 +56,39 @@ const (
 
 // spliceZeroCopy implements zero-copy data transfer using Linux's splice syscall
 // Splice is a true zero-copy mechanism that moves data between file descriptors
-// within the kernel, avoiding copying between kernel and user space
+// within the kernel, avoiding copying between kernel and user space. The
+// syscalls are made on the raw connections, which park the goroutine in the
+// runtime poller when the sockets aren't ready, and honour the deadlines.
 func spliceZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
-	// Get file descriptors for the connections
-	srcTCP, srcOK := src.(*net.TCPConn)
-	dstTCP, dstOK := dst.(*net.TCPConn)
+	_, srcOK := src.(*net.TCPConn)
+	_, dstOK := dst.(*net.TCPConn)
 
 	if !srcOK || !dstOK {
 		// Fall back to standard copy if not TCP connections
 		return io.CopyBuffer(dst, src, buf)
 	}
 
-	// Extract file descriptors
-	srcFile, err := srcTCP.File()
+	srcRaw, dstRaw, err := rawConns(src, dst)
 	if err != nil {
 		return 0, err
 	}
-	defer srcFile.Close()
 
-	dstFile, err := dstTCP.File()
-	if err != nil {
-		srcFile.Close()
+	// Set optimal socket options for performance
+	if err := controlBoth(srcRaw, dstRaw, func(srcFd, dstFd int) {
+		syscall.SetsockoptInt(srcFd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
+		syscall.SetsockoptInt(dstFd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
+		syscall.SetsockoptInt(dstFd, syscall.IPPROTO_TCP, 3 /* TCP_CORK */, 1)
+	}); err != nil {
 		return 0, err
 	}
-	defer dstFile.Close()
-
-	srcFd := int(srcFile.Fd())
-	dstFd := int(dstFile.Fd())
-
-	// Set optimal socket options for performance
-	syscall.SetsockoptInt(srcFd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
-	syscall.SetsockoptInt(dstFd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY, 1)
-	syscall.SetsockoptInt(dstFd, syscall.IPPROTO_TCP, 3 /* TCP_CORK */, 1)
+	// Disable TCP_CORK to flush any remaining data
+	defer dstRaw.Control(func(fd uintptr) {
+		syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, 3 /* TCP_CORK */, 0)
+	})
 
 	// Create pipe for splice operations
 	pipeFds := make([]int, 2)
-	if err := syscall.Pipe(pipeFds); err != nil {
+	if err := syscall.Pipe2(pipeFds, syscall.O_CLOEXEC|syscall.O_NONBLOCK); err != nil {
 		return 0, err
 	}
 	pipeR, pipeW := pipeFds[0], pipeFds[1]
@@ -80,69 +100,53 @@ func spliceZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
 	spliceBufSize := 64 * 1024 // 64KB is generally optimal for most systems
 
 	for {
-		// First splice: read from source into the pipe
-		n, err := syscallSplice(srcFd, nil, pipeW, nil, spliceBufSize,
-			SPLICE_F_MOVE|SPLICE_F_NONBLOCK|SPLICE_F_MORE)
-
-		if err != nil {
-			if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
-				// Socket not ready, wait for readiness
-				readReady, err := waitForIO(srcFd, true, 1000)
-				if err != nil {
-					return total, err
-				}
-				if !readReady {
-					// Socket not ready after timeout
-					if total > 0 {
-						return total, nil
-					}
-					continue
-				}
-				continue
-			}
+		// First splice: read from source into the pipe, which is empty
+		var n int64
+		var serr error
+		if err := srcRaw.Read(func(fd uintptr) bool {
+			n, serr = syscallSplice(int(fd), nil, pipeW, nil, spliceBufSize,
+				SPLICE_F_MOVE|SPLICE_F_NONBLOCK|SPLICE_F_MORE)
+			// Socket not ready, wait for readiness
+			return serr != syscall.EAGAIN
+		}); err != nil {
+			return total, err
+		}
 
-			if errors.Is(err, syscall.EINVAL) {
+		if serr != nil {
+			if errors.Is(serr, syscall.EINVAL) && total == 0 {
 				// Some network interfaces don't support splice
 				// Fall back to standard copy
 				return io.CopyBuffer(dst, src, buf)
 			}
 
 			// Handle errors
-			if err == io.EOF || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+			if errors.Is(serr, syscall.ECONNRESET) || errors.Is(serr, syscall.EPIPE) {
 				return total, nil
 			}
 
-			return total, err
+			return total, serr
 		}
 
 		if n == 0 {
 			// End of data
-			break
+			return total, nil
 		}
 
 		// Second splice: write from the pipe to destination
-		written := int64(0)
-		for written < n {
-			w, err := syscallSplice(pipeR, nil, dstFd, nil, int(n-written),
-				SPLICE_F_MOVE|SPLICE_F_NONBLOCK)
-
-			if err != nil {
-				if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
-					// Socket not ready, wait for writability
-					writeReady, err := waitForIO(dstFd, false, 1000)
-					if err != nil {
-						return total, err
-					}
-					if !writeReady {
-						// Write timeout or error
-						return total, errors.New("write timeout")
-					}
-					continue
-				}
-
+		for written := int64(0); written < n; {
+			var w int64
+			var werr error
+			if err := dstRaw.Write(func(fd uintptr) bool {
+				w, werr = syscallSplice(pipeR, nil, int(fd), nil, int(n-written),
+					SPLICE_F_MOVE|SPLICE_F_NONBLOCK)
+				// Socket not ready, wait for writability
+				return werr != syscall.EAGAIN
+			}); err != nil {
 				return total, err
 			}
-
+			if werr != nil {
+				return total, werr
+			}
 			if w == 0 {
 				return total, errors.New("zero bytes written during splice")
 			}
@@ -151,33 +155,9 @@ func spliceZeroCopy(src, dst net.Conn, buf []byte) (int64, error) {
 			total += w
 		}
 	}
-
-	// Disable TCP_CORK to flush any remaining data
-	syscall.SetsockoptInt(dstFd, syscall.IPPROTO_TCP, 3 /* TCP_CORK */, 0)
-
-	return total, nil
 }
 
 // syscallSplice makes the actual splice syscall
 func syscallSplice(rfd int, roff *int64, wfd int, woff *int64, len int, flags int) (int64, error) {
-	return unix.Splice(rfd, roff, wfd, woff, len, flags)
-}
-
-// waitForIO waits for a file descriptor to be ready for I/O operations
-func waitForIO(fd int, isRead bool, timeoutMs int) (bool, error) {
-	var pfd unix.PollFd
-	pfd.Fd = int32(fd)
-
-	if isRead {
-		pfd.Events = unix.POLLIN
-	} else {
-		pfd.Events = unix.POLLOUT
-	}
-
-	n, err := unix.Poll([]unix.PollFd{pfd}, timeoutMs)
-	if err != nil {
-		return false, err
-	}
-
-	return n > 0, nil
+	return syscall.Splice(rfd, roff, wfd, woff, len, flags)
 }
@@ -0,0 +1,91 @@
+package proxyproto
+
+import "sync/atomic"
+
+// fallbackReason is why the zero-copy implementation wasn't used, indexing
+// zeroCopyFallbacks
+type fallbackReason int
+
+const (
+	fallbackUnavailable fallbackReason = iota
+	fallbackUnsupportedConn
+	fallbackRefused
+	fallbackConnError
+	fallbackReasons
+)
+
+// Process wide counters of the transfers made by ZeroCopy, Conn.WriteTo and
+// Conn.ReadFrom
+var (
+	zeroCopyBytes     atomic.Uint64
+	zeroCopyTransfers atomic.Uint64
+	fallbackBytes     atomic.Uint64
+	bufferedBytes     atomic.Uint64
+	zeroCopyFallbacks [fallbackReasons]atomic.Uint64
+	msgZeroCopyBytes  atomic.Uint64
+	msgZeroCopyCopied atomic.Uint64
+)
+
+// ZeroCopyStats describes how data was transferred by ZeroCopy,
+// ZeroCopyWithBuffer, Conn.WriteTo and Conn.ReadFrom.
+type ZeroCopyStats struct {
+	// ZeroCopyBytes is the number of bytes moved by the zero-copy
+	// implementation, over ZeroCopyTransfers transfers.
+	ZeroCopyBytes     uint64
+	ZeroCopyTransfers uint64
+	// FallbackBytes is the number of bytes moved by a regular copy, over
+	// as many transfers as the sum of the Fallbacks counters.
+	FallbackBytes uint64
+	// BufferedBytes is the number of bytes buffered while reading the
+	// proxy header, which are always copied from userspace.
+	BufferedBytes uint64
+
+	// FallbacksUnavailable counts transfers made while no zero-copy
+	// implementation is compiled in, or it has been disabled.
+	FallbacksUnavailable uint64
+	// FallbacksUnsupportedConn counts transfers between connections the
+	// implementation can't handle, e.g. anything but TCP.
+	FallbacksUnsupportedConn uint64
+	// FallbacksRefused counts transfers where the environment refused the
+	// syscalls, which disables the implementation.
+	FallbacksRefused uint64
+	// FallbacksConnError counts transfers where the syscalls failed for the
+	// connections at hand.
+	FallbacksConnError uint64
+
+	// MsgZeroCopyBytes is the number of bytes written with MSG_ZEROCOPY,
+	// see WithMsgZeroCopy. MsgZeroCopyCopied counts the completions for
+	// which the kernel copied the data anyway, e.g. over loopback.
+	MsgZeroCopyBytes  uint64
+	MsgZeroCopyCopied uint64
+}
+
+// GetZeroCopyStats returns a snapshot of the transfer counters.
+func GetZeroCopyStats() ZeroCopyStats {
+	return ZeroCopyStats{
+		ZeroCopyBytes:            zeroCopyBytes.Load(),
+		ZeroCopyTransfers:        zeroCopyTransfers.Load(),
+		FallbackBytes:            fallbackBytes.Load(),
+		BufferedBytes:            bufferedBytes.Load(),
+		FallbacksUnavailable:     zeroCopyFallbacks[fallbackUnavailable].Load(),
+		FallbacksUnsupportedConn: zeroCopyFallbacks[fallbackUnsupportedConn].Load(),
+		FallbacksRefused:         zeroCopyFallbacks[fallbackRefused].Load(),
+		FallbacksConnError:       zeroCopyFallbacks[fallbackConnError].Load(),
+		MsgZeroCopyBytes:         msgZeroCopyBytes.Load(),
+		MsgZeroCopyCopied:        msgZeroCopyCopied.Load(),
+	}
+}
+
+// recordZeroCopy counts a transfer made by the zero-copy implementation.
+func recordZeroCopy(n int64, err error) (int64, error) {
+	zeroCopyTransfers.Add(1)
+	zeroCopyBytes.Add(uint64(n))
+	return n, err
+}
+
+// record counts a transfer made by a regular copy for this reason.
+func (reason fallbackReason) record(n int64, err error) (int64, error) {
+	zeroCopyFallbacks[reason].Add(1)
+	fallbackBytes.Add(uint64(n))
+	return n, err
+}